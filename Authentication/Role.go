@@ -2,6 +2,7 @@ package Authentication
 
 import (
 	"Api/Models"
+	"errors"
 	"os"
 	"strings"
 	"time"
@@ -57,10 +58,12 @@ func Login(c *fiber.Ctx) error {
 	// สร้าง JWT Token
 	expirationTime := time.Now().Add(30 * time.Minute)
 	claims := jwt.MapClaims{
-		"role":     employee.Role,
-		"username": employee.Username,
-		"exp":      expirationTime.Unix(),
-		"iat":      time.Now().Unix(),
+		"role":         employee.Role,
+		"username":     employee.Username,
+		"employees_id": employee.EmployeesID.String(),
+		"branch_id":    employee.BranchID.String(),
+		"exp":          expirationTime.Unix(),
+		"iat":          time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -85,6 +88,34 @@ func Login(c *fiber.Ctx) error {
 
 }
 
+// ตรวจสอบลายเซ็นของ Token และคืน claims (ยังไม่ตรวจวันหมดอายุและ Role)
+func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return JwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return claims, nil
+}
+
+// ตรวจสอบ Token ครบทั้งลายเซ็นและวันหมดอายุ (Token ที่ไม่มี exp ถือว่าใช้ไม่ได้)
+func ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Unix(int64(exp), 0).Before(time.Now()) {
+		return nil, jwt.ErrTokenExpired
+	}
+	return claims, nil
+}
+
 // Middleware สำหรับตรวจสอบ Token
 func AuthMiddleware(c *fiber.Ctx) error {
 	// ดึงค่า Authorization Header
@@ -98,40 +129,32 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid Token Format"})
 	}
 
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims := jwt.MapClaims{}
-
-	// ตรวจสอบความถูกต้องของ Token
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return JwtKey, nil
-	})
-
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
-	}
-
-	// ตรวจสอบวันหมดอายุของ Token
-	exp, ok := claims["exp"].(float64)
-	if !ok || time.Unix(int64(exp), 0).Before(time.Now()) {
+	// ตรวจสอบความถูกต้องและวันหมดอายุของ Token
+	claims, err := ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+	if errors.Is(err, jwt.ErrTokenExpired) {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Token expired"})
+	} else if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
 	}
 
 	// ตรวจสอบสิทธิ์ (Role)
 	role, ok := claims["role"].(string)
-	if !ok || !isValidRole(role) {
+	if !ok || !IsValidRole(role) {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Permission Denied"})
 	}
 
 	// เก็บข้อมูลลงใน Context
 	c.Locals("username", claims["username"])
 	c.Locals("role", claims["role"])
+	c.Locals("employees_id", claims["employees_id"])
+	c.Locals("branch_id", claims["branch_id"])
 
 	// ดำเนินการต่อ
 	return c.Next()
 }
 
 // ฟังก์ชันตรวจสอบ Role
-func isValidRole(role string) bool {
+func IsValidRole(role string) bool {
 	validRoles := map[string]bool{
 		"Stock":   true,
 		"Account": true,
@@ -141,3 +164,16 @@ func isValidRole(role string) bool {
 	}
 	return validRoles[role]
 }
+
+// Middleware สำหรับจำกัดสิทธิ์ตาม Role (ใช้หลัง AuthMiddleware)
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Permission Denied"})
+	}
+}
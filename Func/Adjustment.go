@@ -0,0 +1,534 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// เหตุผลการปรับปรุงสต็อกที่รองรับ
+var adjustmentReasonCodes = map[string]bool{
+	"Damage": true,
+	"Theft":  true,
+	"Expiry": true,
+	"Found":  true,
+	"Other":  true,
+}
+
+// มูลค่าสูงสุดที่อนุมัติได้โดยไม่ต้องเป็น Manager (ตั้งค่าผ่าน ADJUSTMENT_APPROVAL_THRESHOLD)
+func adjustmentApprovalThreshold() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("ADJUSTMENT_APPROVAL_THRESHOLD"), 64); err == nil {
+		return value
+	}
+	return 10000
+}
+
+// สร้างเอกสารปรับปรุงสต็อก (สถานะ Draft)
+func AddAdjustment(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		BranchID string `json:"branch_id"`
+		Note     string `json:"note"`
+		Lines    []struct {
+			InventoryID   string   `json:"inventory_id"`
+			Delta         int      `json:"delta"`
+			ReasonCode    string   `json:"reason_code"`
+			Note          string   `json:"note"`
+			LotID         string   `json:"lot_id"`         // ลดสต็อก: ล็อตที่ตัด (ไม่ระบุ = FEFO)
+			LotNumber     string   `json:"lot_number"`     // เพิ่มสต็อก: เลขล็อตที่สร้าง (ไม่ระบุ = เลขที่เอกสาร)
+			ExpiryDate    string   `json:"expiry_date"`    // เพิ่มสต็อก: วันหมดอายุของล็อต
+			SerialNumbers []string `json:"serial_numbers"` // สินค้า serialized ต้องระบุให้ครบตามจำนวน
+		} `json:"lines"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.BranchID == "" || len(req.Lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id and lines are required"})
+	}
+
+	var adjustment Models.AdjustmentDocument
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		adjustmentNumber, err := NextDocumentNumber(tx, "Adjustment", req.BranchID, now)
+		if err != nil {
+			return err
+		}
+		adjustment = Models.AdjustmentDocument{
+			AdjustmentNumber: adjustmentNumber,
+			BranchID:         req.BranchID,
+			Status:           "Draft",
+			Note:             req.Note,
+			CreatedBy:        currentUsername(c),
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+
+		total := 0.0
+		for _, line := range req.Lines {
+			if line.Delta == 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "delta cannot be 0")
+			}
+			if !adjustmentReasonCodes[line.ReasonCode] {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid reason_code: "+line.ReasonCode)
+			}
+
+			var inventory Models.Inventory
+			if err := tx.Where("inventory_id = ? AND branch_id = ?", line.InventoryID, req.BranchID).First(&inventory).Error; err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "inventory not found in this branch: "+line.InventoryID)
+			}
+
+			unitCost := snapshotUnitCost(tx, inventory)
+			record := Models.AdjustmentLine{
+				AdjustmentID: adjustment.AdjustmentID,
+				InventoryID:  inventory.InventoryID,
+				ProductID:    inventory.ProductID,
+				Delta:        line.Delta,
+				ReasonCode:   line.ReasonCode,
+				Note:         line.Note,
+				UnitCost:     unitCost,
+				Value:        float64(line.Delta) * unitCost,
+				LotID:        optionalString(line.LotID),
+				LotNumber:    line.LotNumber,
+				CreatedAt:    now,
+			}
+			if line.ExpiryDate != "" {
+				expiry, err := parseDate(line.ExpiryDate)
+				if err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "invalid expiry_date: "+line.ExpiryDate)
+				}
+				record.ExpiryDate = &expiry
+			}
+			if record.SerialNumbers, err = validateAdjustmentSerials(tx, inventory, line.Delta, line.SerialNumbers); err != nil {
+				return err
+			}
+			if err := validateAdjustmentLot(tx, inventory, record); err != nil {
+				return err
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			total += math.Abs(record.Value)
+		}
+
+		adjustment.TotalValue = total
+		return tx.Model(&adjustment).Update("total_value", total).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to create adjustment: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Adjustment created successfully", "adjustment_id": adjustment.AdjustmentID, "adjustment_number": adjustment.AdjustmentNumber})
+}
+
+// ตรวจหมายเลขซีเรียลของรายการปรับปรุง: สินค้า serialized ต้องระบุครบตามจำนวน
+// ลดสต็อก = ซีเรียลต้องอยู่ในสต็อกของสาขา, เพิ่มสต็อก = ซีเรียลต้องยังไม่มีในระบบ
+func validateAdjustmentSerials(tx *gorm.DB, inventory Models.Inventory, delta int, serials []string) ([]string, error) {
+	var product Models.Product
+	if err := tx.Where("product_id = ?", inventory.ProductID).First(&product).Error; err != nil || !product.Serialized {
+		if len(serials) > 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "serial_numbers are only allowed for serialized products")
+		}
+		return nil, nil
+	}
+
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return nil, err
+	}
+	quantity := delta
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	if len(serials) != quantity {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("product %s is serialized: expected %d serial numbers, got %d", inventory.ProductID, quantity, len(serials)))
+	}
+
+	for _, serialNo := range serials {
+		var count int64
+		query := tx.Model(&Models.SerialNumber{}).Where("serial_no = ?", serialNo)
+		if delta < 0 {
+			query = query.Where("product_id = ? AND branch_id = ? AND status = ?", inventory.ProductID, inventory.BranchID, "InStock")
+		}
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if delta < 0 && count == 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "serial number is not in stock at this branch: "+serialNo)
+		}
+		if delta > 0 && count > 0 {
+			return nil, fiber.NewError(fiber.StatusConflict, "serial number already exists: "+serialNo)
+		}
+	}
+	return serials, nil
+}
+
+// ตรวจล็อตที่ระบุ: ใช้ได้เฉพาะการลดสต็อก และต้องเป็นล็อตของ Inventory นี้ที่มีจำนวนพอ
+func validateAdjustmentLot(tx *gorm.DB, inventory Models.Inventory, line Models.AdjustmentLine) error {
+	if line.LotID == nil {
+		return nil
+	}
+	if line.Delta > 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "lot_id can only be used to reduce stock; use lot_number for stock found")
+	}
+
+	var lot Models.InventoryLot
+	if err := tx.Where("lot_id = ? AND inventory_id = ?", *line.LotID, inventory.InventoryID).First(&lot).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "lot not found in this inventory: "+*line.LotID)
+	}
+	if lot.Quantity < -line.Delta {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("lot %s has only %d left", lot.LotNumber, lot.Quantity))
+	}
+	return nil
+}
+
+// ลงบัญชีล็อตของรายการปรับปรุง: ลดสต็อกตัดล็อตที่ระบุ (หรือ FEFO), เพิ่มสต็อกสร้างล็อตใหม่
+func postAdjustmentLots(tx *gorm.DB, adjustment Models.AdjustmentDocument, line *Models.AdjustmentLine, inventory Models.Inventory) error {
+	if line.Delta < 0 {
+		if line.LotID == nil {
+			return reduceLotsFEFO(tx, inventory.InventoryID, -line.Delta)
+		}
+		if err := validateAdjustmentLot(tx, inventory, *line); err != nil {
+			return err
+		}
+		// ตัดแบบมีเงื่อนไข กันล็อตถูกใช้ไปก่อนระหว่างรออนุมัติ
+		result := tx.Model(&Models.InventoryLot{}).
+			Where("lot_id = ? AND quantity >= ?", *line.LotID, -line.Delta).
+			Updates(map[string]interface{}{"quantity": gorm.Expr("quantity - ?", -line.Delta), "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusConflict, "lot no longer has enough quantity: "+*line.LotID)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	lot := Models.InventoryLot{
+		InventoryID:  inventory.InventoryID,
+		ProductID:    inventory.ProductID,
+		BranchID:     inventory.BranchID,
+		LotNumber:    line.LotNumber,
+		ReceivedDate: now,
+		ExpiryDate:   line.ExpiryDate,
+		Quantity:     line.Delta,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if lot.LotNumber == "" {
+		lot.LotNumber = adjustment.AdjustmentNumber
+	}
+	if err := tx.Create(&lot).Error; err != nil {
+		return err
+	}
+	line.LotID = &lot.LotID
+	return tx.Model(&Models.AdjustmentLine{}).Where("line_id = ?", line.LineID).Update("lot_id", lot.LotID).Error
+}
+
+// ลงบัญชีซีเรียลของรายการปรับปรุง: ลดสต็อก = ตัดซีเรียลออกจากสต็อก (WrittenOff), เพิ่มสต็อก = รับซีเรียลใหม่เข้าสาขา
+func postAdjustmentSerials(tx *gorm.DB, adjustment Models.AdjustmentDocument, line Models.AdjustmentLine, inventory Models.Inventory) error {
+	if _, err := validateAdjustmentSerials(tx, inventory, line.Delta, line.SerialNumbers); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, serialNo := range line.SerialNumbers {
+		if line.Delta > 0 {
+			serial := Models.SerialNumber{
+				SerialNo:    serialNo,
+				ProductID:   inventory.ProductID,
+				BranchID:    inventory.BranchID,
+				InventoryID: inventory.InventoryID,
+				Status:      "InStock",
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			if err := tx.Create(&serial).Error; err != nil {
+				return err
+			}
+			if err := recordSerialMovement(tx, serial, "", inventory.BranchID, "Adjustment", adjustment.AdjustmentID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var serial Models.SerialNumber
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("serial_no = ? AND product_id = ? AND branch_id = ? AND status = ?", serialNo, inventory.ProductID, inventory.BranchID, "InStock").
+			First(&serial).Error; err != nil {
+			return fiber.NewError(fiber.StatusConflict, "serial number is no longer in stock at this branch: "+serialNo)
+		}
+		serial.Status = "WrittenOff"
+		serial.UpdatedAt = now
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, inventory.BranchID, "", "Adjustment", adjustment.AdjustmentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ดูเอกสารปรับปรุงสต็อกทั้งหมด (กรองตามสาขา/สถานะได้)
+func LookAdjustments(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.AdjustmentDocument{})
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var adjustments []Models.AdjustmentDocument
+	if err := query.Order("created_at DESC").Find(&adjustments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch adjustments"})
+	}
+	return c.JSON(fiber.Map{"data": adjustments})
+}
+
+// ดูเอกสารปรับปรุงสต็อกพร้อมรายการ
+func FindAdjustment(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var adjustment Models.AdjustmentDocument
+	if err := db.Preload("Lines").Where("adjustment_id = ?", id).First(&adjustment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Adjustment not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":               adjustment,
+		"requires_manager":   adjustment.TotalValue > adjustmentApprovalThreshold(),
+		"approval_threshold": adjustmentApprovalThreshold(),
+		"approval":           approvalHistory(db, "Adjustment", adjustment.AdjustmentID),
+	})
+}
+
+// ขนาดรูปประกอบสูงสุด และชนิดรูปที่รับ
+const maxAdjustmentPhotoSize = 2 << 20
+
+var adjustmentPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// แนบรูปประกอบให้รายการปรับปรุงสต็อก (multipart field: photo)
+func UploadAdjustmentPhoto(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+	lineID := c.Params("lineId")
+
+	var adjustment Models.AdjustmentDocument
+	if err := db.Where("adjustment_id = ?", id).First(&adjustment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Adjustment not found"})
+	}
+	if adjustment.Status != "Draft" && adjustment.Status != "Submitted" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Photos can only be attached before the adjustment is posted"})
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "photo file is required"})
+	}
+	if file.Size > maxAdjustmentPhotoSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": fmt.Sprintf("photo must not exceed %d MB", maxAdjustmentPhotoSize>>20)})
+	}
+	fileContent, err := file.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open uploaded file"})
+	}
+	defer fileContent.Close()
+
+	photo, err := io.ReadAll(io.LimitReader(fileContent, maxAdjustmentPhotoSize+1))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read uploaded file"})
+	}
+	if len(photo) > maxAdjustmentPhotoSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": fmt.Sprintf("photo must not exceed %d MB", maxAdjustmentPhotoSize>>20)})
+	}
+
+	// ตรวจชนิดไฟล์จากเนื้อหาจริง ไม่เชื่อ Content-Type ที่ client ส่งมา
+	photoType := http.DetectContentType(photo)
+	if !adjustmentPhotoTypes[photoType] {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "photo must be a JPEG, PNG, GIF or WebP image"})
+	}
+
+	result := db.Model(&Models.AdjustmentLine{}).
+		Where("line_id = ? AND adjustment_id = ?", lineID, id).
+		Updates(map[string]interface{}{"photo": photo, "photo_type": photoType})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save photo"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Adjustment line not found"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Photo attached"})
+}
+
+// ดูรูปประกอบของรายการปรับปรุงสต็อก
+func GetAdjustmentPhoto(db *gorm.DB, c *fiber.Ctx) error {
+	var line Models.AdjustmentLine
+	if err := db.Where("line_id = ? AND adjustment_id = ?", c.Params("lineId"), c.Params("id")).First(&line).Error; err != nil || len(line.Photo) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Photo not found"})
+	}
+
+	// รูปที่บันทึกไว้ก่อนมีการตรวจชนิดไฟล์ ให้ตรวจซ้ำจากเนื้อหาก่อนส่งกลับ
+	photoType := http.DetectContentType(line.Photo)
+	if !adjustmentPhotoTypes[photoType] {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Photo not found"})
+	}
+	c.Set(fiber.HeaderContentType, photoType)
+	c.Set("X-Content-Type-Options", "nosniff")
+	return c.Send(line.Photo)
+}
+
+// เปลี่ยนสถานะเอกสารปรับปรุงสต็อก (ตรวจสอบสถานะเดิมก่อน)
+func transitionAdjustment(tx *gorm.DB, id, from string) (Models.AdjustmentDocument, error) {
+	var adjustment Models.AdjustmentDocument
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("adjustment_id = ?", id).First(&adjustment).Error; err != nil {
+		return adjustment, fiber.NewError(fiber.StatusNotFound, "adjustment not found")
+	}
+	if adjustment.Status != from {
+		return adjustment, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("only %s adjustments can do this (current status: %s)", from, adjustment.Status))
+	}
+	return adjustment, nil
+}
+
+// ส่งเอกสารปรับปรุงสต็อกเพื่อขออนุมัติ (ขั้นการอนุมัติตาม ApprovalRule หรือ ADJUSTMENT_APPROVAL_THRESHOLD)
+func SubmitAdjustment(db *gorm.DB, c *fiber.Ctx) error {
+	var request Models.ApprovalRequest
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := transitionAdjustment(tx, c.Params("id"), "Draft")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		adjustment.Status = "Submitted"
+		adjustment.SubmittedAt = &now
+		adjustment.UpdatedAt = now
+		if err := tx.Omit("Lines").Save(&adjustment).Error; err != nil {
+			return err
+		}
+		request, _, err = startApproval(tx, "Adjustment", adjustment.AdjustmentID, adjustment.BranchID, decimal.NewFromFloat(adjustment.TotalValue), currentUsername(c))
+		return err
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to submit adjustment: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Adjustment submitted", "approval": request})
+}
+
+// ลงบัญชีเอกสารปรับปรุงสต็อกที่ผ่านการอนุมัติครบแล้ว (เรียกจาก approval engine)
+func postAdjustment(tx *gorm.DB, id, username string) error {
+	adjustment, err := transitionAdjustment(tx, id, "Submitted")
+	if err != nil {
+		return err
+	}
+
+	var lines []Models.AdjustmentLine
+	if err := tx.Where("adjustment_id = ?", adjustment.AdjustmentID).Find(&lines).Error; err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		inventory, err := ApplyStockChange(tx, StockChange{
+			InventoryID:  line.InventoryID,
+			Delta:        line.Delta,
+			MovementType: "Adjustment",
+			DocumentType: "Adjustment",
+			DocumentID:   adjustment.AdjustmentID,
+			Note:         line.ReasonCode,
+			CreatedBy:    username,
+		})
+		if err != nil {
+			return err
+		}
+		if line.Delta > 0 {
+			err = receiveCost(tx, inventory, line.Delta, line.UnitCost, "Adjustment", adjustment.AdjustmentID)
+		} else {
+			_, err = consumeCost(tx, inventory, -line.Delta, "Adjustment", adjustment.AdjustmentID)
+		}
+		if err != nil {
+			return err
+		}
+		if err := postAdjustmentLots(tx, adjustment, &line, inventory); err != nil {
+			return err
+		}
+		if err := postAdjustmentSerials(tx, adjustment, line, inventory); err != nil {
+			return err
+		}
+		// ของที่ตัดออกต้องออกจาก Bin ด้วย ไม่ให้ Bin มีมากกว่ายอดคงเหลือ
+		if line.Delta < 0 {
+			if err := releaseLocations(tx, inventory, adjustment.AdjustmentID, username); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	adjustment.Status = "Posted"
+	adjustment.ApprovedBy = username
+	adjustment.PostedAt = &now
+	adjustment.UpdatedAt = now
+	return tx.Omit("Lines").Save(&adjustment).Error
+}
+
+// อนุมัติขั้นปัจจุบันของเอกสารปรับปรุงสต็อก (ลงบัญชีเมื่ออนุมัติครบทุกขั้น)
+func ApproveAdjustment(db *gorm.DB, c *fiber.Ctx) error {
+	return respondDocumentApproval(db, c, "Adjustment", c.Params("id"), true)
+}
+
+// ปฏิเสธเอกสารปรับปรุงสต็อก (ต้องระบุ comment)
+func RejectAdjustment(db *gorm.DB, c *fiber.Ctx) error {
+	return respondDocumentApproval(db, c, "Adjustment", c.Params("id"), false)
+}
+
+func AdjustmentRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Adjustments", func(c *fiber.Ctx) error {
+		return LookAdjustments(db, c)
+	})
+
+	app.Get("/Adjustments/:id", func(c *fiber.Ctx) error {
+		return FindAdjustment(db, c)
+	})
+
+	app.Get("/Adjustments/:id/lines/:lineId/photo", func(c *fiber.Ctx) error {
+		return GetAdjustmentPhoto(db, c)
+	})
+
+	app.Post("/Adjustments", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AddAdjustment(auditDB(db, c), c)
+	})
+
+	app.Post("/Adjustments/:id/lines/:lineId/photo", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return UploadAdjustmentPhoto(auditDB(db, c), c)
+	})
+
+	app.Post("/Adjustments/:id/submit", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return SubmitAdjustment(auditDB(db, c), c)
+	})
+
+	app.Post("/Adjustments/:id/approve", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return ApproveAdjustment(auditDB(db, c), c)
+	})
+
+	app.Post("/Adjustments/:id/reject", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RejectAdjustment(auditDB(db, c), c)
+	})
+}
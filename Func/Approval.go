@@ -0,0 +1,543 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ประเภทเอกสารที่ต้องผ่านการอนุมัติ
+var approvalDocumentTypes = map[string]bool{
+	"PurchaseOrder":  true,
+	"Shipment":       true,
+	"Adjustment":     true,
+	"ReturnToVendor": true,
+}
+
+// ApprovalDecision ความเห็นของผู้อนุมัติ (serials ใช้กับ Shipment ที่มีสินค้า serialized ในขั้นสุดท้าย)
+type ApprovalDecision struct {
+	Comment string                  `json:"comment"`
+	Serials []SerialDispatchRequest `json:"serials"`
+}
+
+// แยกขั้นการอนุมัติจากข้อความ เช่น "Account,Manager" หรือ "Stock|Manager,God"
+func parseApprovalSteps(steps string) []string {
+	var result []string
+	for _, step := range strings.Split(steps, ",") {
+		var roles []string
+		for _, role := range strings.Split(step, "|") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+		if len(roles) > 0 {
+			result = append(result, strings.Join(roles, "|"))
+		}
+	}
+	return result
+}
+
+// Role ของผู้ใช้อนุมัติขั้นนี้ได้หรือไม่ (God อนุมัติได้ทุกขั้น)
+func roleCanApprove(roles, role string) bool {
+	if role == "God" {
+		return true
+	}
+	for _, allowed := range strings.Split(roles, "|") {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ขั้นการอนุมัติเมื่อไม่มีกฎที่ตรง (เอกสารปรับปรุงสต็อกยังใช้ ADJUSTMENT_APPROVAL_THRESHOLD)
+func defaultApprovalSteps(documentType string, amount decimal.Decimal) []string {
+	if documentType == "Adjustment" && !amount.GreaterThan(decimal.NewFromFloat(adjustmentApprovalThreshold())) {
+		return []string{"Stock|Account|Manager"}
+	}
+	return []string{"Manager"}
+}
+
+// เลือกกฎการอนุมัติ: กฎของสาขาก่อนกฎทุกสาขา และยอดขั้นต่ำที่สูงที่สุดที่ไม่เกินยอดของเอกสาร
+func matchApprovalRule(tx *gorm.DB, documentType, branchID string, amount decimal.Decimal) (*Models.ApprovalRule, error) {
+	var rule Models.ApprovalRule
+	err := tx.Where("document_type = ? AND active = ? AND min_amount <= ?", documentType, true, amount).
+		Where("branch_id IS NULL OR branch_id::text = ?", branchID).
+		Order("branch_id IS NULL, min_amount DESC").
+		First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ยกเลิกคำขออนุมัติที่ค้างอยู่ของเอกสาร (เช่น ถอนกลับเป็น Draft หรือยกเลิกเอกสาร)
+func cancelApproval(tx *gorm.DB, documentType, documentID string) error {
+	var requestIDs []string
+	if err := tx.Model(&Models.ApprovalRequest{}).
+		Where("document_type = ? AND document_id = ? AND status = ?", documentType, documentID, "Pending").
+		Pluck("request_id", &requestIDs).Error; err != nil || len(requestIDs) == 0 {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&Models.ApprovalTask{}).
+		Where("request_id IN ? AND status IN ?", requestIDs, []string{"Waiting", "Pending"}).
+		Update("status", "Cancelled").Error; err != nil {
+		return err
+	}
+	return tx.Model(&Models.ApprovalRequest{}).
+		Where("request_id IN ?", requestIDs).
+		Updates(map[string]interface{}{"status": "Cancelled", "completed_at": now}).Error
+}
+
+// เริ่มคำขออนุมัติของเอกสาร (ต้องเรียกใน transaction เดียวกับที่เปลี่ยนสถานะเอกสาร)
+// ถ้ากฎไม่มีขั้นการอนุมัติ เอกสารจะถูกอนุมัติทันที และคืนผลของการอนุมัติ
+func startApproval(tx *gorm.DB, documentType, documentID, branchID string, amount decimal.Decimal, requestedBy string) (Models.ApprovalRequest, fiber.Map, error) {
+	if err := cancelApproval(tx, documentType, documentID); err != nil {
+		return Models.ApprovalRequest{}, nil, err
+	}
+
+	rule, err := matchApprovalRule(tx, documentType, branchID, amount)
+	if err != nil {
+		return Models.ApprovalRequest{}, nil, err
+	}
+	steps := defaultApprovalSteps(documentType, amount)
+	var ruleID *string
+	if rule != nil {
+		steps = parseApprovalSteps(rule.Steps)
+		ruleID = &rule.RuleID
+	}
+
+	now := time.Now()
+	request := Models.ApprovalRequest{
+		DocumentType: documentType,
+		DocumentID:   documentID,
+		BranchID:     branchID,
+		Amount:       amount,
+		RuleID:       ruleID,
+		Status:       "Pending",
+		CurrentStep:  1,
+		RequestedBy:  requestedBy,
+		CreatedAt:    now,
+	}
+	if err := tx.Omit("Tasks").Create(&request).Error; err != nil {
+		return request, nil, err
+	}
+
+	for i, roles := range steps {
+		task := Models.ApprovalTask{
+			RequestID: request.RequestID,
+			Step:      i + 1,
+			Roles:     roles,
+			Status:    "Waiting",
+			CreatedAt: now,
+		}
+		if i == 0 {
+			task.Status = "Pending"
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return request, nil, err
+		}
+		request.Tasks = append(request.Tasks, task)
+	}
+
+	if len(steps) > 0 {
+		return request, nil, nil
+	}
+
+	// ไม่มีขั้นการอนุมัติ = อนุมัติอัตโนมัติ
+	result, err := approveDocument(tx, request, requestedBy, ApprovalDecision{})
+	if err != nil {
+		return request, nil, err
+	}
+	request.Status = "Approved"
+	request.CompletedAt = &now
+	return request, result, tx.Model(&request).Updates(map[string]interface{}{"status": request.Status, "completed_at": now}).Error
+}
+
+// ทำให้เอกสารเป็นสถานะอนุมัติ เมื่อผ่านการอนุมัติครบทุกขั้น
+func approveDocument(tx *gorm.DB, request Models.ApprovalRequest, username string, decision ApprovalDecision) (fiber.Map, error) {
+	switch request.DocumentType {
+	case "PurchaseOrder":
+		return nil, transitionApprovedDocument(tx, &Models.Order{}, "order_id", request.DocumentID, "Submitted", "Approved")
+	case "Shipment":
+		return approveShipment(tx, request.DocumentID, username, decision.Serials)
+	case "Adjustment":
+		return nil, postAdjustment(tx, request.DocumentID, username)
+	case "ReturnToVendor":
+		now := time.Now()
+		return nil, tx.Model(&Models.ReturnToVendor{}).Where("return_id = ? AND status = ?", request.DocumentID, "Submitted").
+			Updates(map[string]interface{}{"status": "Approved", "approved_by": username, "approved_at": now, "updated_at": now}).Error
+	}
+	return nil, fmt.Errorf("unknown document type: %s", request.DocumentType)
+}
+
+// เปลี่ยนเอกสารกลับเมื่อถูกปฏิเสธ (Order กลับเป็น Draft เพื่อแก้ไขแล้วส่งใหม่ได้)
+func rejectDocument(tx *gorm.DB, request Models.ApprovalRequest, username string) error {
+	switch request.DocumentType {
+	case "PurchaseOrder":
+		return transitionApprovedDocument(tx, &Models.Order{}, "order_id", request.DocumentID, "Submitted", "Draft")
+	case "Shipment":
+		return rejectShipment(tx, request.DocumentID)
+	case "Adjustment":
+		return tx.Model(&Models.AdjustmentDocument{}).Where("adjustment_id = ? AND status = ?", request.DocumentID, "Submitted").
+			Updates(map[string]interface{}{"status": "Rejected", "approved_by": username, "updated_at": time.Now()}).Error
+	case "ReturnToVendor":
+		return tx.Model(&Models.ReturnToVendor{}).Where("return_id = ? AND status = ?", request.DocumentID, "Submitted").
+			Updates(map[string]interface{}{"status": "Rejected", "approved_by": username, "updated_at": time.Now()}).Error
+	}
+	return fmt.Errorf("unknown document type: %s", request.DocumentType)
+}
+
+// เปลี่ยนสถานะเอกสาร from → to (เอกสารต้องยังอยู่ในสถานะ from)
+func transitionApprovedDocument(tx *gorm.DB, model interface{}, idColumn, id, from, to string) error {
+	result := tx.Model(model).Where(idColumn+" = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(fiber.StatusConflict, "document is no longer "+from)
+	}
+	return nil
+}
+
+// ตัดสินงานอนุมัติขั้นปัจจุบันของคำขอ (อนุมัติ = ไปขั้นถัดไป หรือจบเมื่อเป็นขั้นสุดท้าย, ปฏิเสธ = จบทันที)
+func decideApproval(tx *gorm.DB, c *fiber.Ctx, requestID string, approve bool, decision ApprovalDecision) (Models.ApprovalRequest, fiber.Map, error) {
+	var request Models.ApprovalRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("request_id = ?", requestID).First(&request).Error; err != nil {
+		return request, nil, fiber.NewError(fiber.StatusNotFound, "Approval request not found")
+	}
+	if request.Status != "Pending" {
+		return request, nil, fiber.NewError(fiber.StatusBadRequest, "Approval request is already "+request.Status)
+	}
+
+	var tasks []Models.ApprovalTask
+	if err := tx.Where("request_id = ?", request.RequestID).Order("step").Find(&tasks).Error; err != nil {
+		return request, nil, err
+	}
+
+	role, _ := c.Locals("role").(string)
+	username := currentUsername(c)
+	var current *Models.ApprovalTask
+	for i := range tasks {
+		if tasks[i].Status == "Pending" {
+			current = &tasks[i]
+			break
+		}
+	}
+	if current == nil {
+		return request, nil, fiber.NewError(fiber.StatusConflict, "Approval request has no pending task")
+	}
+	if !roleCanApprove(current.Roles, role) {
+		return request, nil, fiber.NewError(fiber.StatusForbidden, "This approval step requires role: "+current.Roles)
+	}
+	// คนเดียวกันอนุมัติหลายขั้นของคำขอเดียวกันไม่ได้
+	for _, task := range tasks {
+		if task.Status == "Approved" && task.DecidedBy == username {
+			return request, nil, fiber.NewError(fiber.StatusForbidden, "You already approved an earlier step of this request")
+		}
+	}
+	if !approve && strings.TrimSpace(decision.Comment) == "" {
+		return request, nil, fiber.NewError(fiber.StatusBadRequest, "A comment is required when rejecting")
+	}
+
+	now := time.Now()
+	current.Status = "Rejected"
+	if approve {
+		current.Status = "Approved"
+	}
+	current.DecidedBy = username
+	current.DecidedByID = currentEmployeeID(c)
+	current.Comment = decision.Comment
+	current.DecidedAt = &now
+	if err := tx.Save(current).Error; err != nil {
+		return request, nil, err
+	}
+
+	var result fiber.Map
+	if !approve {
+		if err := tx.Model(&Models.ApprovalTask{}).Where("request_id = ? AND status = ?", request.RequestID, "Waiting").
+			Update("status", "Cancelled").Error; err != nil {
+			return request, nil, err
+		}
+		if err := rejectDocument(tx, request, username); err != nil {
+			return request, nil, err
+		}
+		request.Status = "Rejected"
+		request.CompletedAt = &now
+	} else if current.Step < len(tasks) {
+		request.CurrentStep = current.Step + 1
+		if err := tx.Model(&Models.ApprovalTask{}).Where("request_id = ? AND step = ?", request.RequestID, request.CurrentStep).
+			Update("status", "Pending").Error; err != nil {
+			return request, nil, err
+		}
+	} else {
+		var err error
+		if result, err = approveDocument(tx, request, username, decision); err != nil {
+			return request, nil, err
+		}
+		request.Status = "Approved"
+		request.CompletedAt = &now
+	}
+
+	if err := tx.Model(&request).Updates(map[string]interface{}{
+		"status":       request.Status,
+		"current_step": request.CurrentStep,
+		"completed_at": request.CompletedAt,
+	}).Error; err != nil {
+		return request, nil, err
+	}
+	return request, result, nil
+}
+
+// คำขออนุมัติที่ค้างอยู่ของเอกสาร
+func pendingApprovalID(tx *gorm.DB, documentType, documentID string) (string, error) {
+	var request Models.ApprovalRequest
+	if err := tx.Where("document_type = ? AND document_id = ? AND status = ?", documentType, documentID, "Pending").
+		Order("created_at DESC").First(&request).Error; err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Document has no pending approval request")
+	}
+	return request.RequestID, nil
+}
+
+// ประวัติการอนุมัติของเอกสาร (ทุกคำขอพร้อมงานอนุมัติแต่ละขั้น)
+func approvalHistory(db *gorm.DB, documentType, documentID string) []Models.ApprovalRequest {
+	var requests []Models.ApprovalRequest
+	db.Preload("Tasks", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("step")
+	}).Where("document_type = ? AND document_id = ?", documentType, documentID).
+		Order("created_at").Find(&requests)
+	return requests
+}
+
+// ตอบผลการตัดสินอนุมัติ
+func respondApproval(db *gorm.DB, c *fiber.Ctx, requestID string, approve bool) error {
+	var decision ApprovalDecision
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&decision); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+		}
+	}
+
+	var request Models.ApprovalRequest
+	var result fiber.Map
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, result, err = decideApproval(tx, c, requestID, approve, decision)
+		return err
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to record approval: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Approval recorded",
+		"status":   request.Status,
+		"step":     request.CurrentStep,
+		"result":   result,
+		"approval": approvalHistory(db, request.DocumentType, request.DocumentID),
+	})
+}
+
+// อนุมัติ/ปฏิเสธคำขอที่ค้างอยู่ของเอกสาร (ใช้กับ endpoint เดิมของแต่ละเอกสาร)
+func respondDocumentApproval(db *gorm.DB, c *fiber.Ctx, documentType, documentID string, approve bool) error {
+	requestID, err := pendingApprovalID(db, documentType, documentID)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return respondApproval(db, c, requestID, approve)
+}
+
+// งานอนุมัติที่รอผู้ใช้ที่ล็อกอินอยู่ (ตาม Role และไม่รวมคำขอที่ตัวเองอนุมัติขั้นก่อนไปแล้ว)
+func LookMyApprovals(db *gorm.DB, c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	username := currentUsername(c)
+
+	query := db.Table(`"ApprovalTask" t`).
+		Select("t.task_id, t.step, t.roles, r.request_id, r.document_type, r.document_id, r.branch_id, r.amount, r.requested_by, r.created_at").
+		Joins(`JOIN "ApprovalRequest" r ON r.request_id = t.request_id`).
+		Where("t.status = ? AND r.status = ?", "Pending", "Pending").
+		Where(`NOT EXISTS (SELECT 1 FROM "ApprovalTask" d WHERE d.request_id = t.request_id AND d.status = ? AND d.decided_by = ?)`, "Approved", username)
+	if role != "God" {
+		query = query.Where("? = ANY(string_to_array(t.roles, '|'))", role)
+	}
+	for _, filter := range []string{"document_type", "branch_id"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where("r."+filter+" = ?", value)
+		}
+	}
+
+	var tasks []struct {
+		TaskID       string          `json:"task_id"`
+		Step         int             `json:"step"`
+		Roles        string          `json:"roles"`
+		RequestID    string          `json:"request_id"`
+		DocumentType string          `json:"document_type"`
+		DocumentID   string          `json:"document_id"`
+		BranchID     string          `json:"branch_id"`
+		Amount       decimal.Decimal `json:"amount"`
+		RequestedBy  string          `json:"requested_by"`
+		CreatedAt    time.Time       `json:"created_at"`
+	}
+	if err := query.Order("r.created_at").Scan(&tasks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch pending approvals: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": tasks})
+}
+
+// ดูประวัติการอนุมัติของเอกสาร
+func GetDocumentApprovals(db *gorm.DB, c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"data": approvalHistory(db, c.Params("type"), c.Params("id"))})
+}
+
+// ApprovalRuleRequest ข้อมูลกฎการอนุมัติ
+type ApprovalRuleRequest struct {
+	DocumentType string          `json:"document_type"`
+	BranchID     *string         `json:"branch_id"`
+	MinAmount    decimal.Decimal `json:"min_amount"`
+	Steps        string          `json:"steps"`
+	Active       *bool           `json:"active"`
+}
+
+// ตรวจสอบกฎการอนุมัติและแปลงเป็น model
+func applyApprovalRuleRequest(db *gorm.DB, rule *Models.ApprovalRule, req ApprovalRuleRequest) error {
+	if !approvalDocumentTypes[req.DocumentType] {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid document_type: "+req.DocumentType)
+	}
+	if req.MinAmount.IsNegative() {
+		return fiber.NewError(fiber.StatusBadRequest, "min_amount must be greater or equal to 0")
+	}
+	if req.BranchID != nil && *req.BranchID == "" {
+		req.BranchID = nil
+	}
+	if req.BranchID != nil {
+		if err := db.Where("branch_id = ?", *req.BranchID).First(&Models.Branches{}).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Branch not found: "+*req.BranchID)
+		}
+	}
+	steps := parseApprovalSteps(req.Steps)
+	for _, step := range steps {
+		for _, role := range strings.Split(step, "|") {
+			if !Authentication.IsValidRole(role) {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid role in steps: "+role)
+			}
+		}
+	}
+
+	rule.DocumentType = req.DocumentType
+	rule.BranchID = req.BranchID
+	rule.MinAmount = req.MinAmount
+	rule.Steps = strings.Join(steps, ",")
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+	rule.UpdatedAt = time.Now()
+	return nil
+}
+
+// ดูกฎการอนุมัติ
+func LookApprovalRules(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.ApprovalRule{})
+	if documentType := c.Query("document_type"); documentType != "" {
+		query = query.Where("document_type = ?", documentType)
+	}
+	var rules []Models.ApprovalRule
+	if err := query.Order("document_type, branch_id NULLS FIRST, min_amount").Find(&rules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch approval rules"})
+	}
+	return c.JSON(fiber.Map{"data": rules})
+}
+
+// เพิ่มกฎการอนุมัติ
+func AddApprovalRule(db *gorm.DB, c *fiber.Ctx) error {
+	var req ApprovalRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	rule := Models.ApprovalRule{Active: true, CreatedAt: time.Now()}
+	if err := applyApprovalRuleRequest(db, &rule, req); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := db.Create(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create approval rule: " + err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Approval rule created successfully", "data": rule})
+}
+
+// แก้ไขกฎการอนุมัติ (คำขอที่เริ่มไปแล้วใช้ขั้นตอนเดิม)
+func UpdateApprovalRule(db *gorm.DB, c *fiber.Ctx) error {
+	var rule Models.ApprovalRule
+	if err := db.Where("rule_id = ?", c.Params("id")).First(&rule).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Approval rule not found"})
+	}
+
+	var req ApprovalRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if err := applyApprovalRuleRequest(db, &rule, req); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := db.Save(&rule).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update approval rule: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Approval rule updated successfully", "data": rule})
+}
+
+// ลบกฎการอนุมัติ
+func DeleteApprovalRule(db *gorm.DB, c *fiber.Ctx) error {
+	result := db.Where("rule_id = ?", c.Params("id")).Delete(&Models.ApprovalRule{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete approval rule: " + result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Approval rule not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Approval rule deleted successfully"})
+}
+
+func ApprovalRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Approvals/pending", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return LookMyApprovals(db, c)
+	})
+
+	app.Get("/Approvals/:type/:id", func(c *fiber.Ctx) error {
+		return GetDocumentApprovals(db, c)
+	})
+
+	app.Post("/Approvals/:id/approve", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondApproval(auditDB(db, c), c, c.Params("id"), true)
+	})
+
+	app.Post("/Approvals/:id/reject", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondApproval(auditDB(db, c), c, c.Params("id"), false)
+	})
+
+	app.Get("/ApprovalRules", func(c *fiber.Ctx) error {
+		return LookApprovalRules(db, c)
+	})
+
+	app.Post("/ApprovalRules", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return AddApprovalRule(auditDB(db, c), c)
+	})
+
+	app.Put("/ApprovalRules/:id", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return UpdateApprovalRule(auditDB(db, c), c)
+	})
+
+	app.Delete("/ApprovalRules/:id", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return DeleteApprovalRule(auditDB(db, c), c)
+	})
+}
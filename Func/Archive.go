@@ -0,0 +1,135 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ถ้าส่ง include_archived=true มา ให้รวมข้อมูลที่ถูกเก็บถาวร (soft delete) ด้วย
+func withArchived(db *gorm.DB, c *fiber.Ctx) *gorm.DB {
+	if c.QueryBool("include_archived") {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// เก็บถาวร (soft delete) พร้อมบันทึกผู้ลบ
+func archiveRecord(db *gorm.DB, c *fiber.Ctx, model interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(model).Update("deleted_by", currentEmployeeID(c)).Error; err != nil {
+			return err
+		}
+		return tx.Delete(model).Error
+	})
+}
+
+// กู้คืนข้อมูลที่ถูกเก็บถาวร คืนค่า false ถ้าไม่พบข้อมูลที่ถูกเก็บถาวร
+func restoreRecord(db *gorm.DB, model interface{}, idColumn string, id string) (bool, error) {
+	result := db.Unscoped().Model(model).
+		Where(idColumn+" = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": nil})
+	return result.RowsAffected > 0, result.Error
+}
+
+// นับข้อมูลที่อ้างอิงอยู่ คืนเฉพาะรายการที่มีจำนวนมากกว่า 0
+func countDependencies(checks map[string]*gorm.DB) (map[string]int64, error) {
+	dependencies := map[string]int64{}
+	for name, query := range checks {
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			dependencies[name] = count
+		}
+	}
+	return dependencies, nil
+}
+
+// ข้อมูลที่อ้างอิง Product (สต็อกคงเหลือ, เอกสาร, ประวัติการเคลื่อนไหว, lot, serial, ต้นทุน)
+// Inventory ลบตาม Product แบบ cascade จึงต้องกันไว้ถ้ายังมีประวัติอ้างอิง Inventory เหล่านั้นอยู่
+func productDependencies(db *gorm.DB, productID string) (map[string]int64, error) {
+	return countDependencies(map[string]*gorm.DB{
+		"inventory_with_stock": db.Model(&Models.Inventory{}).Where("product_id = ? AND quantity <> 0", productID),
+		"order_items":          db.Model(&Models.OrderItem{}).Where("product_id = ?", productID),
+		"shipment_items": db.Model(&Models.ShipmentItem{}).
+			Where(`warehouse_inventory_id IN (SELECT inventory_id FROM "Inventory" WHERE product_id = ?)`, productID),
+		"inventory_movements":    db.Model(&Models.InventoryMovement{}).Where("product_id = ?", productID),
+		"inventory_lots":         db.Model(&Models.InventoryLot{}).Where("product_id = ?", productID),
+		"serial_numbers":         db.Model(&Models.SerialNumber{}).Where("product_id = ?", productID),
+		"cost_layers":            db.Model(&Models.CostLayer{}).Where("product_id = ?", productID),
+		"goods_receipt_lines":    db.Model(&Models.GoodsReceiptLine{}).Where("product_id = ?", productID),
+		"adjustment_lines":       db.Model(&Models.AdjustmentLine{}).Where("product_id = ?", productID),
+		"stock_count_lines":      db.Model(&Models.StockCountLine{}).Where("product_id = ?", productID),
+		"return_to_vendor_lines": db.Model(&Models.ReturnToVendorLine{}).Where("product_id = ?", productID),
+	})
+}
+
+// ข้อมูลที่อ้างอิง Supplier (ใบสั่งซื้อ, แคตตาล็อกสินค้า, ใบคืนสินค้า)
+func supplierDependencies(db *gorm.DB, supplierID string) (map[string]int64, error) {
+	return countDependencies(map[string]*gorm.DB{
+		"orders":            db.Model(&Models.Order{}).Where("supplier_id = ?", supplierID),
+		"supplier_products": db.Model(&Models.SupplierProduct{}).Where("supplier_id = ?", supplierID),
+		"returns_to_vendor": db.Model(&Models.ReturnToVendor{}).Where("supplier_id = ?", supplierID),
+	})
+}
+
+// ข้อมูลที่อ้างอิง Branches (Inventory, เอกสาร, ตำแหน่งจัดเก็บ, พนักงาน)
+func branchDependencies(db *gorm.DB, branchID string) (map[string]int64, error) {
+	return countDependencies(map[string]*gorm.DB{
+		"inventory":           db.Model(&Models.Inventory{}).Where("branch_id = ?", branchID),
+		"orders":              db.Model(&Models.Order{}).Where("branch_id = ?", branchID),
+		"shipments":           db.Model(&Models.Shipment{}).Where("from_branch_id = ? OR to_branch_id = ?", branchID, branchID),
+		"employees":           db.Unscoped().Model(&Models.Employees{}).Where("branch_id = ?", branchID),
+		"returns_to_vendor":   db.Model(&Models.ReturnToVendor{}).Where("branch_id = ?", branchID),
+		"locations":           db.Model(&Models.Location{}).Where("branch_id = ?", branchID),
+		"location_stock":      db.Model(&Models.LocationStock{}).Where("branch_id = ?", branchID),
+		"goods_receipts":      db.Model(&Models.GoodsReceipt{}).Where("branch_id = ?", branchID),
+		"adjustments":         db.Model(&Models.AdjustmentDocument{}).Where("branch_id = ?", branchID),
+		"stock_counts":        db.Model(&Models.StockCount{}).Where("branch_id = ?", branchID),
+		"inventory_movements": db.Model(&Models.InventoryMovement{}).Where("branch_id = ?", branchID),
+	})
+}
+
+// ข้อมูลที่อ้างอิง Employees (ใบสั่งซื้อ)
+func employeeDependencies(db *gorm.DB, employeeID string) (map[string]int64, error) {
+	return countDependencies(map[string]*gorm.DB{
+		"orders": db.Model(&Models.Order{}).Where("employees_id = ?", employeeID),
+	})
+}
+
+// ลบถาวร (hard delete) เมื่อไม่มีข้อมูลอ้างอิงเท่านั้น
+// ล็อกแถวที่จะลบแล้วตรวจและลบใน transaction เดียวกัน กันเอกสารใหม่มาอ้างอิงระหว่างตรวจ
+func hardDeleteRecord(db *gorm.DB, c *fiber.Ctx, model interface{}, dependencies func(tx *gorm.DB) (map[string]int64, error)) error {
+	var found map[string]int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(model).Error; err != nil {
+			return err
+		}
+
+		var err error
+		found, err = dependencies(tx)
+		if err != nil {
+			return fmt.Errorf("failed to check dependencies: %w", err)
+		}
+		if len(found) > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Cannot permanently delete a record that is still referenced")
+		}
+
+		return tx.Unscoped().Delete(model).Error
+	})
+	if len(found) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":        "Cannot permanently delete a record that is still referenced",
+			"dependencies": found,
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Deleted permanently"})
+}
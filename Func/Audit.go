@@ -0,0 +1,365 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ผู้กระทำของ request ที่ผูกไว้กับ db (ดู auditDB)
+type auditActor struct {
+	EmployeeID *string
+	Username   string
+	Role       string
+	Method     string
+	Path       string
+	IP         string
+}
+
+type auditActorKey struct{}
+
+// ตารางที่ไม่บันทึก AuditLog
+var auditSkipTables = map[string]bool{
+	"AuditLog":         true,
+	"DocumentSequence": true,
+}
+
+// จำนวนแถวสูงสุดที่บันทึกต่อคำสั่ง (update/delete แบบหลายแถวเกินนี้บันทึกเฉพาะแถวแรก ๆ)
+const auditRowLimit = 200
+
+// ผูกผู้ใช้งานของ request เข้ากับ db เพื่อให้การสร้าง/แก้ไข/ลบข้อมูลถูกบันทึกใน AuditLog
+// route ที่ไม่ผ่าน AuthMiddleware จะอ่าน Token จาก header ถ้ามีส่งมา
+func auditDB(db *gorm.DB, c *fiber.Ctx) *gorm.DB {
+	actor := auditActor{
+		EmployeeID: currentEmployeeID(c),
+		Username:   currentUsername(c),
+		Method:     c.Method(),
+		Path:       string([]byte(c.Path())),
+		IP:         c.IP(),
+	}
+	actor.Role, _ = c.Locals("role").(string)
+
+	if actor.Username == "" {
+		if claims, err := Authentication.ValidateToken(strings.TrimPrefix(c.Get("Authorization"), "Bearer ")); err == nil {
+			actor.Username, _ = claims["username"].(string)
+			actor.Role, _ = claims["role"].(string)
+			if employeeID, ok := claims["employees_id"].(string); ok && employeeID != "" {
+				actor.EmployeeID = &employeeID
+			}
+		}
+	}
+	return db.WithContext(context.WithValue(c.UserContext(), auditActorKey{}, actor))
+}
+
+// ลงทะเบียน callback ของ GORM ที่บันทึก AuditLog (บันทึกเฉพาะคำสั่งที่มาจาก auditDB)
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("audit:after_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("audit:before_update", auditBefore); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("audit:after_update", auditAfter("Update")); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", auditBefore); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("audit:after_delete", auditAfter("Delete"))
+}
+
+// ผู้กระทำของคำสั่ง (false = ไม่ต้องบันทึก)
+func auditActorOf(db *gorm.DB) (auditActor, bool) {
+	stmt := db.Statement
+	if db.Error != nil || db.DryRun || stmt.Context == nil || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return auditActor{}, false
+	}
+	if auditSkipTables[auditEntityType(stmt)] {
+		return auditActor{}, false
+	}
+	actor, ok := stmt.Context.Value(auditActorKey{}).(auditActor)
+	return actor, ok
+}
+
+func auditEntityType(stmt *gorm.Statement) string {
+	return strings.Trim(stmt.Table, `"`)
+}
+
+// primary key ของ record ที่ส่งให้คำสั่ง (struct หรือ slice)
+func auditPrimaryKeys(stmt *gorm.Statement) []interface{} {
+	field := stmt.Schema.PrioritizedPrimaryField
+	var keys []interface{}
+	add := func(value reflect.Value) {
+		if key, zero := field.ValueOf(stmt.Context, value); !zero {
+			keys = append(keys, key)
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if value := reflect.Indirect(stmt.ReflectValue.Index(i)); value.Kind() == reflect.Struct {
+				add(value)
+			}
+		}
+	}
+	return keys
+}
+
+// อ่านข้อมูลแถวปัจจุบันจากตาราง ตามเงื่อนไข WHERE ของคำสั่ง และ/หรือ primary key
+func auditRows(db *gorm.DB, where *clause.Where, keys []interface{}) ([]map[string]interface{}, error) {
+	stmt := db.Statement
+	if where == nil && len(keys) == 0 {
+		return nil, nil
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(stmt.Table)
+	if where != nil {
+		query = query.Clauses(*where)
+	}
+	if len(keys) > 0 {
+		query = query.Clauses(clause.Where{Exprs: []clause.Expression{
+			clause.IN{Column: clause.Column{Name: stmt.Schema.PrioritizedPrimaryField.DBName}, Values: keys},
+		}})
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Limit(auditRowLimit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for column, value := range row {
+			row[column] = auditValue(column, value)
+		}
+	}
+	return rows, nil
+}
+
+// แปลงค่าจากฐานข้อมูลให้อ่านได้ใน JSON (ซ่อนรหัสผ่าน และไม่เก็บข้อมูลไบนารี เช่นรูปภาพ)
+func auditValue(column string, value interface{}) interface{} {
+	if strings.Contains(strings.ToLower(column), "password") && value != nil {
+		return "***"
+	}
+	switch v := value.(type) {
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case []byte:
+		if json.Valid(v) {
+			return json.RawMessage(append([]byte(nil), v...))
+		}
+		return fmt.Sprintf("<binary %d bytes>", len(v))
+	}
+	return value
+}
+
+// snapshot ก่อน update/delete
+func auditBefore(db *gorm.DB) {
+	if _, ok := auditActorOf(db); !ok {
+		return
+	}
+
+	var where *clause.Where
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			where = &w
+		}
+	}
+	rows, err := auditRows(db, where, auditPrimaryKeys(db.Statement))
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet("audit:before", rows)
+}
+
+// บันทึก AuditLog หลัง update/delete (หนึ่งรายการต่อแถวที่เปลี่ยน)
+func auditAfter(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		actor, ok := auditActorOf(db)
+		if !ok || db.RowsAffected == 0 {
+			return
+		}
+		value, _ := db.InstanceGet("audit:before")
+		before, _ := value.([]map[string]interface{})
+		if len(before) == 0 {
+			return
+		}
+
+		primaryKey := db.Statement.Schema.PrioritizedPrimaryField.DBName
+		after := map[string]map[string]interface{}{}
+		if action == "Update" {
+			keys := make([]interface{}, 0, len(before))
+			for _, row := range before {
+				keys = append(keys, row[primaryKey])
+			}
+			rows, err := auditRows(db, nil, keys)
+			if err != nil {
+				db.AddError(err)
+				return
+			}
+			for _, row := range rows {
+				after[fmt.Sprint(row[primaryKey])] = row
+			}
+		}
+
+		entries := make([]Models.AuditLog, 0, len(before))
+		for _, row := range before {
+			entry := newAuditLog(actor, action, auditEntityType(db.Statement), fmt.Sprint(row[primaryKey]))
+			entry.Before = auditJSON(row)
+			if action == "Update" {
+				changes := auditChanges(row, after[entry.EntityID])
+				if len(changes) == 0 {
+					continue
+				}
+				entry.After = auditJSON(after[entry.EntityID])
+				entry.Changes = auditJSON(changes)
+			}
+			entries = append(entries, entry)
+		}
+		saveAuditLogs(db, entries)
+	}
+}
+
+// บันทึก AuditLog หลัง create
+func auditAfterCreate(db *gorm.DB) {
+	actor, ok := auditActorOf(db)
+	if !ok || db.RowsAffected == 0 {
+		return
+	}
+	rows, err := auditRows(db, nil, auditPrimaryKeys(db.Statement))
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	primaryKey := db.Statement.Schema.PrioritizedPrimaryField.DBName
+	entries := make([]Models.AuditLog, 0, len(rows))
+	for _, row := range rows {
+		entry := newAuditLog(actor, "Create", auditEntityType(db.Statement), fmt.Sprint(row[primaryKey]))
+		entry.After = auditJSON(row)
+		entries = append(entries, entry)
+	}
+	saveAuditLogs(db, entries)
+}
+
+func newAuditLog(actor auditActor, action, entityType, entityID string) Models.AuditLog {
+	return Models.AuditLog{
+		EmployeeID: actor.EmployeeID,
+		Username:   actor.Username,
+		Role:       actor.Role,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Method:     actor.Method,
+		Path:       actor.Path,
+		IP:         actor.IP,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// บันทึกใน transaction เดียวกับคำสั่งที่ถูกบันทึก (rollback พร้อมกัน)
+func saveAuditLogs(db *gorm.DB, entries []Models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+// คอลัมน์ที่ค่าเปลี่ยน {"column": {"from": ..., "to": ...}} (ไม่นับ updated_at)
+func auditChanges(before, after map[string]interface{}) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if after == nil {
+		return changes
+	}
+	for column, to := range after {
+		if column == "updated_at" {
+			continue
+		}
+		from := before[column]
+		fromJSON, _ := json.Marshal(from)
+		toJSON, _ := json.Marshal(to)
+		if !bytes.Equal(fromJSON, toJSON) {
+			changes[column] = fiber.Map{"from": from, "to": to}
+		}
+	}
+	return changes
+}
+
+func auditJSON(value interface{}) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ดูประวัติการเปลี่ยนแปลงข้อมูล กรองตาม entity_type, entity_id, employee_id, username, action และช่วงวันที่ from/to
+func LookAuditLogs(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.AuditLog{})
+	for _, filter := range []string{"entity_type", "entity_id", "employee_id", "username", "action"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := parseDate(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDate(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		// วันที่อย่างเดียวนับถึงสิ้นวัน
+		if len(value) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", to)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit logs: " + err.Error()})
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var logs []Models.AuditLog
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch audit logs: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": logs, "total": total, "limit": limit, "offset": offset})
+}
+
+func AuditRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/audit", Authentication.AuthMiddleware, Authentication.RequireRole("Audit", "God"), func(c *fiber.Ctx) error {
+		return LookAuditLogs(db, c)
+	})
+}
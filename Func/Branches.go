@@ -1,7 +1,9 @@
 package Func
 
 import (
+	"Api/Authentication"
 	"Api/Models"
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,6 +15,7 @@ func AddBranches(db *gorm.DB, c *fiber.Ctx) error {
 	type Request struct {
 		BName    string `json:"b_name" validate:"required"`
 		Location string `json:"location" validate:"required"`
+		Code     string `json:"code"`
 	}
 
 	var req Request
@@ -24,9 +27,15 @@ func AddBranches(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "BName and Location are required"})
 	}
 
+	code, err := normalizeBranchCode(req.Code)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	branch := Models.Branches{
 		BName:    req.BName,
 		Location: req.Location,
+		Code:     code,
 	}
 
 	if err := db.Create(&branch).Error; err != nil {
@@ -49,22 +58,66 @@ func UpdateBranches(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Branch not found"})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if branch.Version != version {
+		return preconditionFailed(c, "branch", branch, branch.Version)
+	}
+
 	var body map[string]interface{}
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format"})
 	}
+	// แก้ได้เฉพาะฟิลด์ที่กำหนด (version/branch_id/deleted_* ระบบจัดการเอง)
+	updates := map[string]interface{}{}
+	for _, field := range []string{"b_name", "location"} {
+		if value, ok := body[field]; ok {
+			text, ok := value.(string)
+			if !ok {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": field + " must be a string"})
+			}
+			updates[field] = text
+		}
+	}
+	if value, ok := body["code"]; ok {
+		code, _ := value.(string)
+		if updates["code"], err = normalizeBranchCode(code); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	// การอนุญาตให้สต็อกติดลบเป็นนโยบายของสาขา เปลี่ยนได้เฉพาะ Manager/God
+	if value, ok := body["allow_negative_stock"]; ok {
+		if role, _ := c.Locals("role").(string); role != "Manager" && role != "God" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Only Manager or God can change allow_negative_stock"})
+		}
+		allow, ok := value.(bool)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "allow_negative_stock must be a boolean"})
+		}
+		updates["allow_negative_stock"] = allow
+	}
+	if len(updates) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No updatable fields provided (b_name, location, code, allow_negative_stock)"})
+	}
 
-	if err := db.Model(&branch).Updates(body).Error; err != nil {
+	err = updateVersioned(db, &Models.Branches{}, "branch_id", id, version, updates)
+	db.Where("branch_id = ?", id).First(&branch)
+	if errors.Is(err, errStaleVersion) {
+		return preconditionFailed(c, "branch", branch, branch.Version)
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update branch"})
 	}
 
+	setETag(c, branch.Version)
 	return c.JSON(fiber.Map{"message": "Branch updated successfully", "branch": branch})
 }
 
 // ดู สาขา
 func LookBranch(db *gorm.DB, c *fiber.Ctx) error {
 	var branches []Models.Branches
-	if err := db.Find(&branches).Error; err != nil {
+	if err := withArchived(db, c).Find(&branches).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch branches"})
 	}
 	return c.JSON(fiber.Map{"branches": branches})
@@ -77,24 +130,50 @@ func FindBranches(db *gorm.DB, c *fiber.Ctx) error {
 	if err := db.Where("branch_id = ?", id).First(&branch).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Branch not found"})
 	}
+	setETag(c, branch.Version)
 	return c.JSON(fiber.Map{"branch": branch})
 }
 
-// ลบ สาขา
+// ลบ สาขา (เก็บถาวร หรือ ลบถาวรเมื่อส่ง hard=true และไม่มีข้อมูลอ้างอิง)
 func DeleteBranches(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
+	hard := c.QueryBool("hard")
+
+	query := db
+	if hard {
+		query = db.Unscoped()
+	}
+
 	var branch Models.Branches
-	if err := db.Where("branch_id = ?", id).First(&branch).Error; err != nil {
+	if err := query.Where("branch_id = ?", id).First(&branch).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Branch not found"})
 	}
 
-	if err := db.Delete(&branch).Error; err != nil {
+	if hard {
+		return hardDeleteRecord(db, c, &branch, func(tx *gorm.DB) (map[string]int64, error) {
+			return branchDependencies(tx, branch.BranchID.String())
+		})
+	}
+
+	if err := archiveRecord(db, c, &branch); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete branch: " + err.Error()})
 	}
 
 	return c.JSON(fiber.Map{"message": "Branch deleted successfully"})
 }
 
+// กู้คืน สาขา ที่ถูกเก็บถาวร
+func RestoreBranches(db *gorm.DB, c *fiber.Ctx) error {
+	restored, err := restoreRecord(db, &Models.Branches{}, "branch_id", c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore branch: " + err.Error()})
+	}
+	if !restored {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Archived branch not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Branch restored successfully"})
+}
+
 // ดึงข้อมูลสาขาใน Inventory เพื่ออ้างอิงข้อมูลสินค้าในสาขา Warehouse
 func GetWarehouseInventory(db *gorm.DB, c *fiber.Ctx) error {
 	branchID := c.Query("branchId")
@@ -163,7 +242,7 @@ func GetWarehouseBranches(db *gorm.DB, c *fiber.Ctx) error {
 		Location string `json:"location"`
 	}
 
-	if err := db.Table("Branches").Select("branch_id, b_name, location").Find(&branches).Error; err != nil {
+	if err := db.Table("Branches").Select("branch_id, b_name, location").Where("deleted_at IS NULL").Find(&branches).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch Warehouse branches", "details": err.Error()})
 	}
 
@@ -192,7 +271,7 @@ func BranchRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
 	})
 
 	app.Post("/Branches", func(c *fiber.Ctx) error {
-		return AddBranches(db, c)
+		return AddBranches(auditDB(db, c), c)
 	})
 
 	app.Get("/Branches", func(c *fiber.Ctx) error {
@@ -203,11 +282,15 @@ func BranchRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
 		return FindBranches(db, c)
 	})
 
-	app.Delete("/Branches/:id", func(c *fiber.Ctx) error {
-		return DeleteBranches(db, c)
+	app.Delete("/Branches/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return DeleteBranches(auditDB(db, c), c)
+	})
+
+	app.Put("/Branches/:id/restore", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RestoreBranches(auditDB(db, c), c)
 	})
 
-	app.Put("/Branches/:id", func(c *fiber.Ctx) error {
-		return UpdateBranches(db, c)
+	app.Put("/Branches/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return UpdateBranches(auditDB(db, c), c)
 	})
 }
@@ -0,0 +1,323 @@
+package Func
+
+import (
+	"Api/Models"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// วิธีคิดต้นทุน ตั้งค่าผ่าน COSTING_METHOD (FIFO หรือ AVERAGE ค่าเริ่มต้น FIFO)
+func costingMethod() string {
+	if strings.ToUpper(os.Getenv("COSTING_METHOD")) == "AVERAGE" {
+		return "AVERAGE"
+	}
+	return "FIFO"
+}
+
+// ต้นทุนต่อหน่วยย่อยของรายการสั่งซื้อ (ยอดหลังหักส่วนลด ก่อนภาษี ถ้าไม่มีราคา ใช้ต้นทุนปัจจุบันของสินค้า)
+func orderItemUnitCost(tx *gorm.DB, item Models.OrderItem, inventory Models.Inventory) float64 {
+	if item.LineTotal.IsPositive() && item.Quantity > 0 {
+		return item.LineTotal.Div(decimal.NewFromInt(int64(item.Quantity))).InexactFloat64()
+	}
+	if item.UnitPrice.IsPositive() && item.ConversRate > 0 {
+		return item.UnitPrice.Div(decimal.NewFromFloat(item.ConversRate)).InexactFloat64()
+	}
+	return currentUnitCost(tx, inventory.ProductID, inventory.BranchID)
+}
+
+// บันทึกชั้นต้นทุนของสินค้าที่รับเข้า และคำนวณต้นทุนถัวเฉลี่ยเคลื่อนที่ใหม่
+func receiveCost(tx *gorm.DB, inventory Models.Inventory, quantity int, unitCost float64, sourceType, sourceID string) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	var cost Models.InventoryCost
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ?", inventory.InventoryID).
+		Attrs(Models.InventoryCost{InventoryID: inventory.InventoryID}).
+		FirstOrInit(&cost).Error; err != nil {
+		return err
+	}
+
+	var costedQty int
+	if err := tx.Model(&Models.CostLayer{}).
+		Select("COALESCE(SUM(remaining), 0)").
+		Where("inventory_id = ?", inventory.InventoryID).
+		Scan(&costedQty).Error; err != nil {
+		return err
+	}
+
+	cost.AverageCost = (float64(costedQty)*cost.AverageCost + float64(quantity)*unitCost) / float64(costedQty+quantity)
+	cost.UpdatedAt = time.Now()
+	if err := tx.Save(&cost).Error; err != nil {
+		return err
+	}
+
+	layer := Models.CostLayer{
+		InventoryID:      inventory.InventoryID,
+		ProductID:        inventory.ProductID,
+		BranchID:         inventory.BranchID,
+		SourceType:       sourceType,
+		SourceID:         sourceID,
+		Quantity:         quantity,
+		Remaining:        quantity,
+		UnitCost:         unitCost,
+		AverageCostAfter: cost.AverageCost,
+		ReceivedAt:       time.Now(),
+		CreatedAt:        time.Now(),
+	}
+	return tx.Create(&layer).Error
+}
+
+// ตัดต้นทุนของสินค้าที่จ่ายออก คืนต้นทุนรวม
+// ชั้นต้นทุนถูกตัดแบบ FIFO เสมอ ส่วนราคาที่ใช้ขึ้นกับวิธีคิดต้นทุน
+// สต็อกที่ไม่มีชั้นต้นทุน (รับเข้าก่อนมีระบบต้นทุน) ใช้ต้นทุนถัวเฉลี่ยหรือราคาทุนปัจจุบัน
+func consumeCost(tx *gorm.DB, inventory Models.Inventory, quantity int, documentType, documentID string) (float64, error) {
+	if quantity <= 0 {
+		return 0, nil
+	}
+
+	method := costingMethod()
+
+	var cost Models.InventoryCost
+	hasAverage := tx.Where("inventory_id = ?", inventory.InventoryID).First(&cost).Error == nil
+	fallbackCost := cost.AverageCost
+	if !hasAverage {
+		fallbackCost = currentUnitCost(tx, inventory.ProductID, inventory.BranchID)
+	}
+
+	var layers []Models.CostLayer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ? AND remaining > 0", inventory.InventoryID).
+		Order("received_at ASC").
+		Find(&layers).Error; err != nil {
+		return 0, err
+	}
+
+	consume := func(layerID *string, take int, unitCost float64) error {
+		consumption := Models.CostConsumption{
+			LayerID:      layerID,
+			InventoryID:  inventory.InventoryID,
+			ProductID:    inventory.ProductID,
+			BranchID:     inventory.BranchID,
+			DocumentType: documentType,
+			DocumentID:   documentID,
+			Method:       method,
+			Quantity:     take,
+			UnitCost:     unitCost,
+			TotalCost:    float64(take) * unitCost,
+			CreatedAt:    time.Now(),
+		}
+		return tx.Create(&consumption).Error
+	}
+
+	remaining := quantity
+	total := 0.0
+	for _, layer := range layers {
+		if remaining == 0 {
+			break
+		}
+
+		take := layer.Remaining
+		if take > remaining {
+			take = remaining
+		}
+
+		if err := tx.Model(&Models.CostLayer{}).
+			Where("layer_id = ?", layer.LayerID).
+			UpdateColumn("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return 0, err
+		}
+
+		unitCost := layer.UnitCost
+		if method == "AVERAGE" {
+			unitCost = cost.AverageCost
+		}
+		layerID := layer.LayerID
+		if err := consume(&layerID, take, unitCost); err != nil {
+			return 0, err
+		}
+
+		total += float64(take) * unitCost
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		if err := consume(nil, remaining, fallbackCost); err != nil {
+			return 0, err
+		}
+		total += float64(remaining) * fallbackCost
+	}
+
+	return total, nil
+}
+
+// ValuationLine มูลค่าสต็อกของ Inventory หนึ่งรายการ
+type ValuationLine struct {
+	InventoryID string  `json:"inventory_id"`
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Category    string  `json:"category"`
+	BranchID    string  `json:"branch_id"`
+	BranchName  string  `json:"branch_name"`
+	Quantity    int     `json:"quantity"`
+	UnitCost    float64 `json:"unit_cost"`
+	Value       float64 `json:"value"`
+}
+
+// รายงานมูลค่าสต็อกแยกตามสาขาและหมวดหมู่ ณ วันที่ที่ต้องการ
+func GetInventoryValuation(db *gorm.DB, c *fiber.Ctx) error {
+	method := strings.ToUpper(c.Query("method", costingMethod()))
+	if method != "FIFO" && method != "AVERAGE" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "method must be FIFO or AVERAGE"})
+	}
+
+	asOf := time.Now()
+	if value := c.Query("as_of"); value != "" {
+		date, err := parseDate(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid as_of date"})
+		}
+		asOf = date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	var layers []struct {
+		InventoryID      string
+		ProductID        string
+		ProductName      string
+		Category         string
+		BranchID         string
+		BranchName       string
+		UnitCost         float64
+		AverageCostAfter float64
+		Remaining        int
+	}
+
+	query := db.Table(`"CostLayer" l`).
+		Select(`l.inventory_id, l.product_id, p.product_name, p.description AS category,
+			l.branch_id, b.b_name AS branch_name, l.unit_cost, l.average_cost_after,
+			l.quantity - COALESCE((
+				SELECT SUM(cc.quantity) FROM "CostConsumption" cc
+				WHERE cc.layer_id = l.layer_id AND cc.created_at <= ?
+			), 0) AS remaining`, asOf).
+		Joins(`JOIN "Product" p ON p.product_id = l.product_id::uuid`).
+		Joins(`LEFT JOIN "Branches" b ON b.branch_id = l.branch_id::uuid`).
+		Where("l.received_at <= ?", asOf)
+
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("l.branch_id = ?", branchID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("LOWER(p.description) = LOWER(?)", category)
+	}
+
+	if err := query.Order("l.inventory_id, l.received_at").Scan(&layers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch cost layers: " + err.Error()})
+	}
+
+	// รวมชั้นต้นทุนเป็นรายการต่อ Inventory
+	var lines []*ValuationLine
+	byInventory := map[string]*ValuationLine{}
+	averageCost := map[string]float64{}
+	for _, layer := range layers {
+		line, ok := byInventory[layer.InventoryID]
+		if !ok {
+			line = &ValuationLine{
+				InventoryID: layer.InventoryID,
+				ProductID:   layer.ProductID,
+				ProductName: layer.ProductName,
+				Category:    layer.Category,
+				BranchID:    layer.BranchID,
+				BranchName:  layer.BranchName,
+			}
+			byInventory[layer.InventoryID] = line
+			lines = append(lines, line)
+		}
+		line.Quantity += layer.Remaining
+		line.Value += float64(layer.Remaining) * layer.UnitCost
+		averageCost[layer.InventoryID] = layer.AverageCostAfter
+	}
+
+	totalValue := 0.0
+	byBranch := map[string]float64{}
+	byCategory := map[string]float64{}
+	result := make([]ValuationLine, 0, len(lines))
+	for _, line := range lines {
+		if method == "AVERAGE" {
+			line.Value = float64(line.Quantity) * averageCost[line.InventoryID]
+		}
+		if line.Quantity > 0 {
+			line.UnitCost = line.Value / float64(line.Quantity)
+		}
+		totalValue += line.Value
+		byBranch[line.BranchName] += line.Value
+		byCategory[line.Category] += line.Value
+		result = append(result, *line)
+	}
+
+	return c.JSON(fiber.Map{
+		"method":      method,
+		"as_of":       asOf,
+		"lines":       result,
+		"by_branch":   byBranch,
+		"by_category": byCategory,
+		"total_value": totalValue,
+	})
+}
+
+// รายงานต้นทุนสินค้าที่โอนออกผ่าน Shipment แยกตามสาขาต้นทาง/ปลายทาง
+func GetTransferCost(db *gorm.DB, c *fiber.Ctx) error {
+	var rows []struct {
+		FromBranchID string  `json:"from_branch_id"`
+		ToBranchID   string  `json:"to_branch_id"`
+		Shipments    int     `json:"shipments"`
+		Quantity     int     `json:"quantity"`
+		TotalCost    float64 `json:"total_cost"`
+	}
+
+	query := db.Table(`"CostConsumption" cc`).
+		Select(`s.from_branch_id, s.to_branch_id, COUNT(DISTINCT s.shipment_id) AS shipments,
+			SUM(cc.quantity) AS quantity, SUM(cc.total_cost) AS total_cost`).
+		Joins(`JOIN "Shipment" s ON s.shipment_id = cc.document_id::uuid`).
+		Where("cc.document_type = ?", "Shipment")
+
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("s.from_branch_id = ?", branchID)
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := parseDate(from)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from date"})
+		}
+		query = query.Where("cc.created_at >= ?", date)
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := parseDate(to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to date"})
+		}
+		query = query.Where("cc.created_at < ?", date.AddDate(0, 0, 1))
+	}
+
+	if err := query.Group("s.from_branch_id, s.to_branch_id").Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch transfer cost: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"transfers": rows})
+}
+
+func CostingRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/reports/inventory-valuation", func(c *fiber.Ctx) error {
+		return GetInventoryValuation(db, c)
+	})
+
+	app.Get("/reports/transfer-cost", func(c *fiber.Ctx) error {
+		return GetTransferCost(db, c)
+	})
+}
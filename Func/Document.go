@@ -0,0 +1,381 @@
+package Func
+
+import (
+	"Api/Models"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// ชื่อฟอนต์ภาษาไทยที่ลงทะเบียนใน PDF (ใช้ได้เมื่อกำหนด PDF_FONT_PATH)
+const documentFont = "THSarabun"
+
+// ข้อมูลหัวกระดาษของบริษัท (ตั้งค่าผ่าน COMPANY_NAME, COMPANY_ADDRESS, COMPANY_TAX_ID, COMPANY_PHONE)
+type companyHeader struct {
+	Name    string
+	Address string
+	TaxID   string
+	Phone   string
+}
+
+func loadCompanyHeader() companyHeader {
+	header := companyHeader{
+		Name:    os.Getenv("COMPANY_NAME"),
+		Address: os.Getenv("COMPANY_ADDRESS"),
+		TaxID:   os.Getenv("COMPANY_TAX_ID"),
+		Phone:   os.Getenv("COMPANY_PHONE"),
+	}
+	if header.Name == "" {
+		header.Name = "Warehouse"
+	}
+	return header
+}
+
+// documentPDF เอกสาร PDF พร้อมฟอนต์ที่ใช้
+type documentPDF struct {
+	*gofpdf.Fpdf
+	font string
+	size float64 // ขนาดตัวอักษรปกติ (ฟอนต์ไทยตัวเล็กกว่าฟอนต์มาตรฐาน จึงต้องขยาย)
+}
+
+// สร้าง PDF ขนาด A4 พร้อมฟอนต์ภาษาไทยจาก PDF_FONT_PATH / PDF_FONT_BOLD_PATH
+// ถ้าไม่ได้ตั้งค่าหรือโหลดฟอนต์ไม่ได้ จะใช้ Helvetica (แสดงภาษาไทยไม่ได้)
+func newDocumentPDF() *documentPDF {
+	pdf := &documentPDF{Fpdf: gofpdf.New("P", "mm", "A4", ""), font: "Helvetica", size: 10}
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+
+	if pdf.loadThaiFont() {
+		pdf.font = documentFont
+		pdf.size = 14
+	}
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.text("", -2)
+		pdf.CellFormat(0, 8, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	return pdf
+}
+
+// โหลดฟอนต์ภาษาไทย คืน false ถ้าไม่ได้ตั้งค่าหรือไฟล์ฟอนต์ใช้ไม่ได้
+// gofpdf เก็บ error ค้างไว้ ถ้าไม่ล้างจะทำให้ทั้งเอกสารสร้างไม่ได้
+func (pdf *documentPDF) loadThaiFont() bool {
+	regular, err := os.ReadFile(os.Getenv("PDF_FONT_PATH"))
+	if err != nil {
+		return false
+	}
+	if pdf.AddUTF8FontFromBytes(documentFont, "", regular); pdf.Err() {
+		log.Println("Cannot load PDF_FONT_PATH, falling back to Helvetica:", pdf.Error())
+		pdf.ClearError()
+		return false
+	}
+
+	if bold, err := os.ReadFile(os.Getenv("PDF_FONT_BOLD_PATH")); err == nil {
+		if pdf.AddUTF8FontFromBytes(documentFont, "B", bold); !pdf.Err() {
+			return true
+		}
+		log.Println("Cannot load PDF_FONT_BOLD_PATH, using the regular font for bold:", pdf.Error())
+		pdf.ClearError()
+	}
+	if pdf.AddUTF8FontFromBytes(documentFont, "B", regular); pdf.Err() {
+		log.Println("Cannot load bold font, falling back to Helvetica:", pdf.Error())
+		pdf.ClearError()
+		return false
+	}
+	return true
+}
+
+// ตั้งฟอนต์ตามสไตล์ และปรับขนาดจากขนาดปกติ
+func (pdf *documentPDF) text(style string, delta float64) {
+	pdf.SetFont(pdf.font, style, pdf.size+delta)
+}
+
+// หัวกระดาษ: ข้อมูลบริษัททางซ้าย ชื่อเอกสารและเลขที่ทางขวา
+func (pdf *documentPDF) header(title, number string, date time.Time) {
+	company := loadCompanyHeader()
+	pdf.AddPage()
+
+	pdf.text("B", 4)
+	pdf.CellFormat(110, 8, company.Name, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 8, title, "", 1, "R", false, 0, "")
+
+	pdf.text("", -1)
+	y := pdf.GetY()
+	pdf.MultiCell(110, 5, company.Address, "", "L", false)
+	if company.TaxID != "" {
+		pdf.CellFormat(110, 5, "Tax ID: "+company.TaxID, "", 1, "L", false, 0, "")
+	}
+	if company.Phone != "" {
+		pdf.CellFormat(110, 5, "Tel: "+company.Phone, "", 1, "L", false, 0, "")
+	}
+	bottom := pdf.GetY()
+
+	pdf.SetXY(125, y)
+	pdf.CellFormat(0, 5, "No. "+number, "", 2, "R", false, 0, "")
+	pdf.CellFormat(0, 5, "Date "+date.Format("02/01/2006"), "", 2, "R", false, 0, "")
+	if pdf.GetY() > bottom {
+		bottom = pdf.GetY()
+	}
+
+	pdf.SetY(bottom + 3)
+	pdf.Line(15, pdf.GetY(), 195, pdf.GetY())
+	pdf.Ln(4)
+}
+
+// กล่องข้อมูลสองคอลัมน์ (เช่น ผู้ขาย/ผู้รับ หรือ สาขาต้นทาง/ปลายทาง)
+func (pdf *documentPDF) parties(leftTitle string, left []string, rightTitle string, right []string) {
+	y := pdf.GetY()
+	pdf.text("B", 0)
+	pdf.CellFormat(90, 6, leftTitle, "", 2, "L", false, 0, "")
+	pdf.text("", 0)
+	for _, line := range left {
+		if line != "" {
+			pdf.MultiCell(90, 5, line, "", "L", false)
+		}
+	}
+	bottom := pdf.GetY()
+
+	pdf.SetXY(105, y)
+	pdf.text("B", 0)
+	pdf.CellFormat(90, 6, rightTitle, "", 2, "L", false, 0, "")
+	pdf.text("", 0)
+	for _, line := range right {
+		if line != "" {
+			pdf.SetX(105)
+			pdf.MultiCell(90, 5, line, "", "L", false)
+		}
+	}
+	if pdf.GetY() > bottom {
+		bottom = pdf.GetY()
+	}
+	pdf.SetY(bottom + 4)
+}
+
+// ตารางรายการ (aligns ต่อคอลัมน์ L/C/R)
+func (pdf *documentPDF) table(headers []string, widths []float64, aligns []string, rows [][]string) {
+	pdf.text("B", 0)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		pdf.CellFormat(widths[i], 7, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.text("", 0)
+	for _, row := range rows {
+		for i, value := range row {
+			pdf.CellFormat(widths[i], 7, value, "1", 0, aligns[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+// ช่องลงลายมือชื่อ
+func (pdf *documentPDF) signatures(titles ...string) {
+	if pdf.GetY() > 240 {
+		pdf.AddPage()
+	}
+	pdf.SetY(pdf.GetY() + 20)
+	width := 180 / float64(len(titles))
+	pdf.text("", 0)
+	for range titles {
+		pdf.CellFormat(width, 6, "______________________________", "", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	for _, title := range titles {
+		pdf.CellFormat(width, 6, title, "", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	for range titles {
+		pdf.CellFormat(width, 6, "Date ____/____/______", "", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// ส่ง PDF กลับเป็นไฟล์ดาวน์โหลด
+func sendPDF(c *fiber.Ctx, pdf *documentPDF, filename string) error {
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate PDF: " + err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return c.Send(buffer.Bytes())
+}
+
+// ชื่อสาขา (รวมสาขาที่ถูกลบแล้ว)
+func branchName(db *gorm.DB, branchID string) string {
+	var branch Models.Branches
+	if err := db.Unscoped().Where("branch_id = ?", branchID).First(&branch).Error; err != nil {
+		return branchID
+	}
+	return branch.BName
+}
+
+// ใบสั่งซื้อ (Purchase Order) สำหรับส่งให้ Supplier
+func GetOrderPDF(db *gorm.DB, c *fiber.Ctx) error {
+	var order Models.Order
+	if err := db.Where("order_id = ?", c.Params("id")).First(&order).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	var supplier Models.Supplier
+	if err := db.Unscoped().Where("supplier_id = ?", order.SupplierID).First(&supplier).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+
+	var lines []struct {
+		Models.OrderItem
+		ProductName string
+		SKU         string
+	}
+	if err := db.Table(`"OrderItem" oi`).
+		Select("oi.*, p.product_name, p.sku").
+		Joins(`LEFT JOIN "Product" p ON p.product_id = oi.product_id`).
+		Where("oi.order_id = ?", order.OrderID).
+		Order("oi.created_at").
+		Scan(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch order items: " + err.Error()})
+	}
+
+	currency := defaultCurrency
+	rows := make([][]string, 0, len(lines))
+	for i, line := range lines {
+		currency = line.Currency
+		units := float64(line.Quantity)
+		if line.ConversRate > 0 {
+			units /= line.ConversRate
+		}
+		rows = append(rows, []string{
+			fmt.Sprint(i + 1),
+			line.SKU,
+			line.ProductName,
+			fmt.Sprintf("%.2f", units),
+			line.UnitPrice.StringFixed(2),
+			line.Discount.StringFixed(2),
+			line.TaxRate.StringFixed(2) + "%",
+			line.LineTotal.StringFixed(2),
+		})
+	}
+
+	pdf := newDocumentPDF()
+	pdf.header("PURCHASE ORDER", order.OrderNumber, order.CreatedAt)
+
+	deliverTo := []string{}
+	if order.BranchID != nil {
+		deliverTo = append(deliverTo, branchName(db, *order.BranchID))
+	}
+	if order.PromisedDate != nil {
+		deliverTo = append(deliverTo, "Delivery date: "+order.PromisedDate.Format("02/01/2006"))
+	}
+	deliverTo = append(deliverTo, "Status: "+order.Status)
+	terms := supplier.PaymentTerms
+	if supplier.PaymentTermDays > 0 {
+		terms = fmt.Sprintf("%s (%d days)", terms, supplier.PaymentTermDays)
+	}
+	pdf.parties("Supplier", []string{
+		supplier.Name,
+		supplier.Address,
+		supplier.ContactName,
+		supplier.Phone,
+		supplier.Email,
+		taxIDLine(supplier.TaxID),
+	}, "Deliver to", append(deliverTo, paymentTermsLine(terms)))
+
+	pdf.table(
+		[]string{"#", "SKU", "Product", "Qty", "Unit price", "Discount", "Tax", "Amount"},
+		[]float64{8, 25, 52, 15, 22, 20, 14, 24},
+		[]string{"C", "L", "L", "R", "R", "R", "R", "R"},
+		rows,
+	)
+
+	pdf.Ln(2)
+	for _, total := range []struct {
+		label string
+		value string
+	}{
+		{"Subtotal", order.Subtotal.StringFixed(2)},
+		{"Tax", order.Tax.StringFixed(2)},
+		{"Grand total (" + currency + ")", order.GrandTotal.StringFixed(2)},
+	} {
+		pdf.text("B", 0)
+		pdf.CellFormat(156, 7, total.label, "", 0, "R", false, 0, "")
+		pdf.text("", 0)
+		pdf.CellFormat(24, 7, total.value, "1", 1, "R", false, 0, "")
+	}
+
+	pdf.signatures("Prepared by", "Approved by", "Supplier acknowledgement")
+	return sendPDF(c, pdf, order.OrderNumber+".pdf")
+}
+
+// ใบส่งสินค้า / รายการบรรจุ (Delivery note) สำหรับแนบไปกับรถขนส่ง
+func GetShipmentPDF(db *gorm.DB, c *fiber.Ctx) error {
+	var shipment Models.Shipment
+	if err := db.Where("shipment_id = ?", c.Params("id")).First(&shipment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shipment not found"})
+	}
+
+	var lines []struct {
+		ProductName string
+		SKU         string
+		Unit        string
+		Quantity    int
+		Status      string
+	}
+	if err := db.Table(`"ShipmentItem" si`).
+		Select("p.product_name, p.sku, pu.type AS unit, si.quantity, si.status").
+		Joins(`LEFT JOIN "Inventory" i ON i.inventory_id = si.warehouse_inventory_id`).
+		Joins(`LEFT JOIN "Product" p ON p.product_id = i.product_id`).
+		Joins(`LEFT JOIN "ProductUnit" pu ON pu.product_unit_id = si.product_unit_id`).
+		Where("si.shipment_id = ?", shipment.ShipmentID).
+		Order("si.created_at").
+		Scan(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch shipment items: " + err.Error()})
+	}
+
+	rows := make([][]string, 0, len(lines))
+	total := 0
+	for i, line := range lines {
+		total += line.Quantity
+		rows = append(rows, []string{fmt.Sprint(i + 1), line.SKU, line.ProductName, line.Unit, fmt.Sprint(line.Quantity), ""})
+	}
+
+	pdf := newDocumentPDF()
+	pdf.header("DELIVERY NOTE", shipment.ShipmentNumber, shipment.ShipmentDate)
+	pdf.parties("From", []string{branchName(db, shipment.FromBranchID)},
+		"To", []string{branchName(db, shipment.ToBranchID), "Status: " + shipment.Status})
+
+	pdf.table(
+		[]string{"#", "SKU", "Product", "Unit", "Qty", "Received"},
+		[]float64{10, 30, 70, 25, 20, 25},
+		[]string{"C", "L", "L", "L", "R", "R"},
+		rows,
+	)
+	pdf.text("B", 0)
+	pdf.CellFormat(135, 7, "Total quantity", "", 0, "R", false, 0, "")
+	pdf.CellFormat(20, 7, fmt.Sprint(total), "1", 1, "R", false, 0, "")
+
+	pdf.signatures("Dispatched by", "Driver", "Received by")
+	return sendPDF(c, pdf, "DN-"+shipment.ShipmentNumber+".pdf")
+}
+
+func taxIDLine(taxID string) string {
+	if taxID == "" {
+		return ""
+	}
+	return "Tax ID: " + taxID
+}
+
+func paymentTermsLine(terms string) string {
+	if terms == "" {
+		return ""
+	}
+	return "Payment terms: " + terms
+}
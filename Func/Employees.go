@@ -75,7 +75,7 @@ func AddEmployees(db *gorm.DB, c *fiber.Ctx) error {
 func LookEmployees(db *gorm.DB, c *fiber.Ctx) error {
 	var employees []Models.Employees
 
-	if err := db.Preload("Branch").Find(&employees).Error; err != nil {
+	if err := withArchived(db, c).Preload("Branch").Find(&employees).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to find employees: " + err.Error(),
 		})
@@ -142,19 +142,45 @@ func UpdateEmployees(db *gorm.DB, c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Employee updated successfully", "user": user})
 }
 
-// ลบพนักงาน
+// ลบพนักงาน (เก็บถาวร หรือ ลบถาวรเมื่อส่ง hard=true และไม่มีข้อมูลอ้างอิง)
 func DeleteEmployees(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
+	hard := c.QueryBool("hard")
+
+	query := db
+	if hard {
+		query = db.Unscoped()
+	}
+
 	var user Models.Employees
-	if err := db.Where("employees_id = ?", id).First(&user).Error; err != nil {
+	if err := query.Where("employees_id = ?", id).First(&user).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
-	if err := db.Delete(&user).Error; err != nil {
+
+	if hard {
+		return hardDeleteRecord(db, c, &user, func(tx *gorm.DB) (map[string]int64, error) {
+			return employeeDependencies(tx, user.EmployeesID.String())
+		})
+	}
+
+	if err := archiveRecord(db, c, &user); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete user: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Deleted": "Succeed"})
 }
 
+// กู้คืนพนักงานที่ถูกเก็บถาวร
+func RestoreEmployees(db *gorm.DB, c *fiber.Ctx) error {
+	restored, err := restoreRecord(db, &Models.Employees{}, "employees_id", c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore user: " + err.Error()})
+	}
+	if !restored {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Archived user not found"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "User restored successfully"})
+}
+
 // Routes สำหรับพนักงาน
 func EmployeesRoutes(app *fiber.App, db *gorm.DB) {
 	app.Get("/Employees", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
@@ -162,14 +188,18 @@ func EmployeesRoutes(app *fiber.App, db *gorm.DB) {
 	})
 
 	app.Post("/Employees", func(c *fiber.Ctx) error {
-		return AddEmployees(db, c)
+		return AddEmployees(auditDB(db, c), c)
 	})
 
 	app.Put("/Employees/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
-		return UpdateEmployees(db, c)
+		return UpdateEmployees(auditDB(db, c), c)
 	})
 
 	app.Delete("/Employees/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
-		return DeleteEmployees(db, c)
+		return DeleteEmployees(auditDB(db, c), c)
+	})
+
+	app.Put("/Employees/:id/restore", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RestoreEmployees(auditDB(db, c), c)
 	})
 }
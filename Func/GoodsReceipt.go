@@ -0,0 +1,233 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GoodsReceiptLineRequest จำนวนที่รับของแต่ละรายการใน Order (หน่วยย่อย เช่นเดียวกับ OrderItem.Quantity)
+type GoodsReceiptLineRequest struct {
+	OrderItemID string   `json:"order_item_id"`
+	ReceivedQty int      `json:"received_qty"`
+	DamagedQty  int      `json:"damaged_qty"`
+	UnitCost    *float64 `json:"unit_cost"`
+}
+
+// Inventory ของสินค้าในสาขาปลายทาง (ถ้ายังไม่มีจะสร้างใหม่ด้วยยอด 0)
+func receivingInventory(tx *gorm.DB, productID, branchID string) (Models.Inventory, error) {
+	var inventory Models.Inventory
+	err := tx.Where("product_id = ? AND branch_id = ?", productID, branchID).Order("created_at").First(&inventory).Error
+	if err == nil {
+		return inventory, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return inventory, err
+	}
+
+	inventory = Models.Inventory{ProductID: productID, BranchID: branchID}
+	if price, err := CurrentProductPrice(tx, productID, branchID, "", time.Now()); err == nil {
+		inventory.Price = price.SellingPrice.InexactFloat64()
+	}
+	if err := tx.Create(&inventory).Error; err != nil {
+		return inventory, err
+	}
+	return inventory, nil
+}
+
+// รับสินค้าตาม Order เข้าสาขาปลายทาง (รับบางส่วนได้ จำนวนชำรุดไม่เข้าสต็อก)
+func AddGoodsReceipt(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req struct {
+		Note    string                    `json:"note"`
+		Lines   []GoodsReceiptLineRequest `json:"lines"`
+		Lots    []LotRequest              `json:"lots"`
+		Serials []SerialReceiptRequest    `json:"serials"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.Lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one receipt line is required"})
+	}
+	for _, line := range req.Lines {
+		if line.ReceivedQty < 0 || line.DamagedQty < 0 || line.ReceivedQty+line.DamagedQty == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Received and damaged quantities must be non-negative and not both zero"})
+		}
+		if line.UnitCost != nil && *line.UnitCost < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unit_cost must be greater or equal to 0"})
+		}
+	}
+
+	lotsByProduct, err := groupLotsByProduct(req.Lots)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	serialsByProduct := map[string][]SerialReceiptRequest{}
+	for _, serial := range req.Serials {
+		serialsByProduct[serial.ProductID] = append(serialsByProduct[serial.ProductID], serial)
+	}
+
+	username := currentUsername(c)
+	var order Models.Order
+	var receipt Models.GoodsReceipt
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", id).First(&order).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Order not found")
+		}
+		if order.Status != "Approved" && order.Status != "PartiallyReceived" {
+			return fiber.NewError(fiber.StatusBadRequest, "Only Approved or PartiallyReceived orders can be received")
+		}
+		if order.BranchID == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Order has no destination branch")
+		}
+
+		var orderItems []Models.OrderItem
+		if err := tx.Where("order_id = ?", order.OrderID).Find(&orderItems).Error; err != nil {
+			return err
+		}
+		items := map[string]*Models.OrderItem{}
+		for i := range orderItems {
+			items[orderItems[i].OrderItemID] = &orderItems[i]
+		}
+
+		now := time.Now()
+		receiptNumber, err := NextDocumentNumber(tx, "GoodsReceipt", *order.BranchID, now)
+		if err != nil {
+			return err
+		}
+		receipt = Models.GoodsReceipt{
+			ReceiptNumber: receiptNumber,
+			OrderID:       order.OrderID,
+			BranchID:      *order.BranchID,
+			Note:          req.Note,
+			ReceivedBy:    username,
+			ReceivedAt:    now,
+			CreatedAt:     now,
+		}
+		if err := tx.Omit("Lines").Create(&receipt).Error; err != nil {
+			return err
+		}
+
+		for _, line := range req.Lines {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				return fiber.NewError(fiber.StatusBadRequest, "Order item not found in this order: "+line.OrderItemID)
+			}
+			outstanding := item.Quantity - item.ReceivedQty - item.DamagedQty
+			if line.ReceivedQty+line.DamagedQty > outstanding {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Receipt for order item %s exceeds outstanding quantity (%d)", item.OrderItemID, outstanding))
+			}
+
+			record := Models.GoodsReceiptLine{
+				ReceiptID:   receipt.ReceiptID,
+				OrderItemID: item.OrderItemID,
+				ProductID:   item.ProductID,
+				ReceivedQty: line.ReceivedQty,
+				DamagedQty:  line.DamagedQty,
+				CreatedAt:   now,
+			}
+
+			if line.ReceivedQty > 0 {
+				inventory, err := receivingInventory(tx, item.ProductID, *order.BranchID)
+				if err != nil {
+					return err
+				}
+				inventory, err = ApplyStockChange(tx, StockChange{
+					InventoryID:  inventory.InventoryID,
+					Delta:        line.ReceivedQty,
+					MovementType: "Receipt",
+					DocumentType: "GoodsReceipt",
+					DocumentID:   receipt.ReceiptID,
+					Note:         order.OrderNumber,
+					CreatedBy:    username,
+				})
+				if err != nil {
+					return err
+				}
+
+				// ✅ บันทึกล็อตที่รับเข้า
+				if err := receiveLots(tx, inventory, order, line.ReceivedQty, lotsByProduct[item.ProductID]); err != nil {
+					return err
+				}
+
+				// ✅ บันทึกต้นทุนของสินค้าที่รับเข้า (ใช้ต้นทุนที่ระบุในใบรับ ถ้ามี)
+				unitCost := orderItemUnitCost(tx, *item, inventory)
+				if line.UnitCost != nil {
+					unitCost = *line.UnitCost
+				}
+				if err := receiveCost(tx, inventory, line.ReceivedQty, unitCost, "GoodsReceipt", receipt.ReceiptID); err != nil {
+					return err
+				}
+
+				// ✅ บันทึกหมายเลขซีเรียลเข้าสาขาปลายทาง (เฉพาะสินค้าที่เป็น serialized)
+				serials := serialsByProduct[item.ProductID]
+				for i := range serials {
+					serials[i].BranchID = *order.BranchID
+				}
+				receivedItem := *item
+				receivedItem.Quantity = line.ReceivedQty
+				if err := receiveSerials(tx, order, receivedItem, serials); err != nil {
+					return err
+				}
+
+				record.InventoryID = inventory.InventoryID
+				record.UnitCost = unitCost
+			}
+
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			receipt.Lines = append(receipt.Lines, record)
+
+			item.ReceivedQty += line.ReceivedQty
+			item.DamagedQty += line.DamagedQty
+			if err := tx.Model(&Models.OrderItem{}).Where("order_item_id = ?", item.OrderItemID).
+				Updates(map[string]interface{}{"received_qty": item.ReceivedQty, "damaged_qty": item.DamagedQty, "updated_at": now}).Error; err != nil {
+				return err
+			}
+		}
+
+		// ✅ รับครบทุกรายการ (รวมจำนวนชำรุด) = Received ไม่เช่นนั้น PartiallyReceived
+		order.Status = "Received"
+		for _, item := range orderItems {
+			if item.ReceivedQty+item.DamagedQty < item.Quantity {
+				order.Status = "PartiallyReceived"
+				break
+			}
+		}
+		order.UpdatedAt = now
+		return tx.Save(&order).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to receive order: " + err.Error()})
+	}
+
+	// ✅ แนะนำตำแหน่งจัดเก็บสำหรับสินค้าที่รับเข้า
+	suggestions, err := receiptPutawaySuggestions(db, receipt.Lines)
+	if err != nil {
+		log.Println("Failed to build putaway suggestions:", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":             "Goods received successfully",
+		"receipt":             receipt,
+		"order_status":        order.Status,
+		"putaway_suggestions": suggestions,
+	})
+}
+
+// ดูใบรับสินค้าทั้งหมดของ Order
+func LookGoodsReceipts(db *gorm.DB, c *fiber.Ctx) error {
+	var receipts []Models.GoodsReceipt
+	if err := db.Preload("Lines").Where("order_id = ?", c.Params("id")).Order("received_at").Find(&receipts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch goods receipts"})
+	}
+	return c.JSON(fiber.Map{"data": receipts})
+}
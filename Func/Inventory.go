@@ -3,11 +3,13 @@ package Func
 import (
 	"Api/Models"
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // เพิ่มข้อมูล Inventory
@@ -35,7 +37,12 @@ func AddInventory(db *gorm.DB, c *fiber.Ctx) error {
 		Price:     req.Price,
 	}
 
-	if err := db.Create(&inventory).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inventory).Error; err != nil {
+			return err
+		}
+		return recordMovement(tx, inventory, inventory.Quantity, "Opening", "Inventory", inventory.InventoryID, "", currentUsername(c))
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create inventory: " + err.Error()})
 	}
 
@@ -50,6 +57,11 @@ func UpdateInventory(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Inventory not found"})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	type InventoryRequest struct {
 		ProductID string  `json:"product_id"`
 		Quantity  int     `json:"quantity"`
@@ -66,15 +78,41 @@ func UpdateInventory(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Quantity must be greater or equal to 0"})
 	}
 
-	inventory.ProductID = req.ProductID
-	inventory.BranchID = req.BranchID
-	inventory.Quantity = req.Quantity
-	inventory.Price = req.Price
-
-	if err := db.Save(&inventory).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update inventory: " + err.Error()})
-	}
-
+	// ล็อกแถวก่อนคำนวณส่วนต่าง เพื่อไม่ให้ทับยอดที่ request อื่นเพิ่งแก้
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("inventory_id = ?", id).First(&inventory).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Inventory not found")
+		}
+		if inventory.Version != version {
+			return errStaleVersion
+		}
+
+		updated, err := ApplyStockChange(tx, StockChange{
+			InventoryID:  inventory.InventoryID,
+			Delta:        req.Quantity - inventory.Quantity,
+			MovementType: "ManualEdit",
+			DocumentType: "Inventory",
+			DocumentID:   inventory.InventoryID,
+			CreatedBy:    currentUsername(c),
+		})
+		if err != nil {
+			return err
+		}
+
+		inventory = updated
+		inventory.ProductID = req.ProductID
+		inventory.BranchID = req.BranchID
+		inventory.Price = req.Price
+		inventory.Version = version + 1
+		return tx.Model(&Models.Inventory{}).Where("inventory_id = ?", inventory.InventoryID).
+			Updates(map[string]interface{}{"product_id": inventory.ProductID, "branch_id": inventory.BranchID, "price": inventory.Price, "version": inventory.Version}).Error
+	}); errors.Is(err, errStaleVersion) {
+		return preconditionFailed(c, "data", inventory, inventory.Version)
+	} else if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to update inventory: " + err.Error()})
+	}
+
+	setETag(c, inventory.Version)
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Inventory updated successfully", "data": inventory})
 }
 
@@ -85,7 +123,12 @@ func LookInventory(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot fetch inventory data: " + err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"data": inventories})
+	result, err := withAvailability(db, inventories)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot fetch reserved quantities: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": result})
 }
 
 // ค้นหาข้อมูล Inventory
@@ -96,18 +139,30 @@ func FindInventory(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Inventory not found"})
 	}
 
-	return c.JSON(fiber.Map{"data": inventory})
+	result, err := withAvailability(db, []Models.Inventory{inventory})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot fetch reserved quantities: " + err.Error()})
+	}
+
+	setETag(c, inventory.Version)
+	return c.JSON(fiber.Map{"data": result[0]})
 }
 
 // ลบข้อมูล Inventory
 func DeleteInventory(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
-	var inventory Models.Inventory
-	if err := db.Where("inventory_id = ?", id).First(&inventory).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Inventory not found"})
-	}
-	if err := db.Delete(&inventory).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete inventory: " + err.Error()})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var inventory Models.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("inventory_id = ?", id).First(&inventory).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Inventory not found")
+		}
+		// ลบได้เฉพาะรายการที่ยอดเป็นศูนย์ ของที่ยังเหลือต้องตัดออกผ่านเอกสารปรับปรุงสต็อกก่อน
+		if inventory.Quantity != 0 {
+			return fiber.NewError(fiber.StatusConflict, "Inventory still has stock on hand; write it off with a stock adjustment before deleting")
+		}
+		return tx.Delete(&inventory).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to delete inventory: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Inventory deleted successfully"})
 }
@@ -178,6 +233,14 @@ func GetBranchesWithInventory(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 }
 
 func GetInventorySummary(db *gorm.DB, c *fiber.Ctx) error {
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid as_of date"})
+	}
+	if asOf != nil {
+		return inventorySummaryAsOf(db, c, *asOf)
+	}
+
 	var inventoryData []struct {
 		ProductID   string `json:"product_id"`
 		ProductName string `json:"product_name"`
@@ -186,7 +249,7 @@ func GetInventorySummary(db *gorm.DB, c *fiber.Ctx) error {
 	}
 
 	// Query รวมข้อมูล
-	err := db.Raw(`
+	err = db.Raw(`
 		SELECT p.product_id, p.product_name, p.description, SUM(i.quantity) as quantity
 		FROM public."Inventory" i
 		JOIN public."Product" p ON i.product_id = p.product_id
@@ -205,17 +268,26 @@ func GetInventorySummary(db *gorm.DB, c *fiber.Ctx) error {
 type InventoryCategory struct {
 	Category      string          `json:"category"`
 	TotalQuantity int             `json:"total_quantity"`
+	TotalValue    float64         `json:"total_value"`
 	Details       json.RawMessage `json:"details"` // ใช้ json.RawMessage เพื่อเก็บ JSON ดิบ
 }
 
 func GetInventoryByCategory(db *gorm.DB, c *fiber.Ctx) error {
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid as_of date"})
+	}
+	if asOf != nil {
+		return inventoryByCategoryAsOf(db, c, *asOf)
+	}
+
 	var categories []struct {
 		Category      string          `json:"category"`
 		TotalQuantity int             `json:"total_quantity"`
 		Details       json.RawMessage `json:"details"`
 	}
 
-	err := db.Raw(`
+	err = db.Raw(`
     SELECT 
         p.description AS category,
         COALESCE(SUM(i.quantity), 0) AS total_quantity,
@@ -236,7 +308,8 @@ func GetInventoryByCategory(db *gorm.DB, c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"categories": categories})
 }
 
-var lowStockCache []struct {
+// LowStockItem สินค้าใน POS ที่ต่ำกว่าจุดสั่งซื้อ
+type LowStockItem struct {
 	ProductID   string    `json:"product_id"`
 	ProductName string    `json:"product_name"`
 	Category    string    `json:"category"`
@@ -245,6 +318,8 @@ var lowStockCache []struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+var lowStockCache []LowStockItem
+
 var lastCacheTime time.Time
 
 func GetPosLowStock(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
@@ -255,21 +330,25 @@ func GetPosLowStock(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 
 	log.Println("Fetching POS Low Stock Items from Database...")
 
+	var items []struct {
+		LowStockItem
+		BranchID string
+	}
 	err := posDB.Raw(`
         SELECT 
             p.product_id, 
             p.product_name, 
             c.category_name AS category, 
             i.quantity, 
+            i.branch_id,
             b.b_name AS branch_name,
 			i.updated_at AT TIME ZONE 'UTC' AS updated_at
         FROM public."Inventory" i
         JOIN public."Products" p ON i.product_id = p.product_id
         JOIN public."Category" c ON p.category_id = c.category_id  
         JOIN public."Branches" b ON i.branch_id = b.branch_id  
-        WHERE i.quantity < 1000
         ORDER BY i.updated_at DESC 
-    `).Scan(&lowStockCache).Error
+    `).Scan(&items).Error
 
 	if err != nil {
 		log.Println("❌ Error fetching POS low stock items:", err)
@@ -278,6 +357,19 @@ func GetPosLowStock(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 		})
 	}
 
+	// ✅ เทียบกับจุดสั่งซื้อของสินค้าแต่ละสาขา (ถ้าไม่ได้ตั้งค่าใช้ค่าเริ่มต้น)
+	reorderPoints := posReorderPoints(db)
+	lowStockCache = []LowStockItem{}
+	for _, item := range items {
+		reorderPoint, ok := reorderPoints[item.ProductID+"|"+item.BranchID]
+		if !ok {
+			reorderPoint = defaultReorderPoint
+		}
+		if item.Quantity < reorderPoint {
+			lowStockCache = append(lowStockCache, item.LowStockItem)
+		}
+	}
+
 	lastCacheTime = time.Now() // ✅ อัปเดต cache time
 	return c.JSON(fiber.Map{"low_stock_items": lowStockCache})
 }
@@ -406,14 +498,14 @@ func InventoryRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
 	})
 
 	app.Post("/Inventory", func(c *fiber.Ctx) error {
-		return AddInventory(db, c)
+		return AddInventory(auditDB(db, c), c)
 	})
 
 	app.Put("/Inventory/:id", func(c *fiber.Ctx) error {
-		return UpdateInventory(db, c)
+		return UpdateInventory(auditDB(db, c), c)
 	})
 
 	app.Delete("/Inventory/:id", func(c *fiber.Ctx) error {
-		return DeleteInventory(db, c)
+		return DeleteInventory(auditDB(db, c), c)
 	})
 }
@@ -0,0 +1,571 @@
+package Func
+
+import (
+	"Api/Models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ลำดับชั้นของตำแหน่งจัดเก็บ (ชนิดลูกที่อนุญาตของแต่ละชนิด)
+var locationChildType = map[string]string{
+	"":      "Zone",
+	"Zone":  "Aisle",
+	"Aisle": "Rack",
+	"Rack":  "Bin",
+}
+
+// PutawaySuggestion ตำแหน่งที่แนะนำให้จัดเก็บสินค้า
+type PutawaySuggestion struct {
+	InventoryID  string `json:"inventory_id"`
+	ProductID    string `json:"product_id"`
+	BranchID     string `json:"branch_id"`
+	Quantity     int    `json:"quantity"`
+	LocationID   string `json:"location_id"`
+	LocationCode string `json:"location_code"`
+	Reason       string `json:"reason"`
+}
+
+// PickLine รายการหยิบสินค้าจาก Bin สำหรับ Shipment
+type PickLine struct {
+	InventoryID  string  `json:"inventory_id"`
+	ProductID    string  `json:"product_id"`
+	LocationID   *string `json:"location_id"`
+	LocationCode string  `json:"location_code"`
+	Quantity     int     `json:"quantity"`
+}
+
+// จำนวนสินค้าใน Inventory ที่ยังไม่ได้จัดเก็บเข้า Bin
+func unlocatedQuantity(tx *gorm.DB, inventory Models.Inventory) (int, error) {
+	var located int
+	if err := tx.Model(&Models.LocationStock{}).
+		Where("inventory_id = ?", inventory.InventoryID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&located).Error; err != nil {
+		return 0, err
+	}
+	return inventory.Quantity - located, nil
+}
+
+// เพิ่ม/ลดจำนวนสินค้าใน Bin
+func changeLocationStock(tx *gorm.DB, locationID string, inventory Models.Inventory, delta int) error {
+	var stock Models.LocationStock
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("location_id = ? AND inventory_id = ?", locationID, inventory.InventoryID).
+		First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if delta < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "not enough stock in location")
+		}
+		stock = Models.LocationStock{
+			LocationID:  locationID,
+			InventoryID: inventory.InventoryID,
+			ProductID:   inventory.ProductID,
+			BranchID:    inventory.BranchID,
+			Quantity:    delta,
+			UpdatedAt:   time.Now(),
+		}
+		return tx.Create(&stock).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if stock.Quantity+delta < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "not enough stock in location")
+	}
+	if stock.Quantity+delta == 0 {
+		return tx.Delete(&stock).Error
+	}
+	return tx.Model(&stock).Updates(map[string]interface{}{"quantity": stock.Quantity + delta, "updated_at": time.Now()}).Error
+}
+
+// แนะนำ Bin สำหรับจัดเก็บสินค้า: Bin ที่มีสินค้าเดียวกันอยู่แล้วก่อน ถ้าไม่มีใช้ Bin ว่าง
+func suggestPutaway(db *gorm.DB, inventory Models.Inventory, quantity int) (*PutawaySuggestion, error) {
+	suggestion := PutawaySuggestion{
+		InventoryID: inventory.InventoryID,
+		ProductID:   inventory.ProductID,
+		BranchID:    inventory.BranchID,
+		Quantity:    quantity,
+	}
+
+	var location Models.Location
+	err := db.Table(`"Location" l`).
+		Select("l.*").
+		Joins(`JOIN "LocationStock" s ON s.location_id = l.location_id`).
+		Where("s.inventory_id = ? AND l.type = ?", inventory.InventoryID, "Bin").
+		Order("s.quantity DESC").
+		Take(&location).Error
+	if err == nil {
+		suggestion.LocationID = location.LocationID
+		suggestion.LocationCode = location.Code
+		suggestion.Reason = "Same product already stored here"
+		return &suggestion, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = db.Where("branch_id = ? AND type = ?", inventory.BranchID, "Bin").
+		Where(`NOT EXISTS (SELECT 1 FROM "LocationStock" s WHERE s.location_id = "Location".location_id)`).
+		Order("code").
+		First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion.LocationID = location.LocationID
+	suggestion.LocationCode = location.Code
+	suggestion.Reason = "Empty bin"
+	return &suggestion, nil
+}
+
+// หยิบสินค้าจาก Bin ตามลำดับรหัสตำแหน่ง (เส้นทางเดินหยิบ) สำหรับ ShipmentItem
+// ส่วนที่ไม่ได้อยู่ใน Bin ใด ๆ จะถูกหยิบจากสต็อกที่ยังไม่จัดเก็บ
+func pickFromLocations(tx *gorm.DB, shipment Models.Shipment, item Models.ShipmentItem, movedBy string) ([]PickLine, error) {
+	var inventory Models.Inventory
+	if err := tx.Where("inventory_id = ?", item.WarehouseInventoryID).First(&inventory).Error; err != nil {
+		return nil, fmt.Errorf("failed to find inventory for item: %s", item.WarehouseInventoryID)
+	}
+
+	var stocks []struct {
+		Models.LocationStock
+		Code string
+	}
+	if err := tx.Table(`"LocationStock" s`).
+		Select("s.*, l.code").
+		Joins(`JOIN "Location" l ON l.location_id = s.location_id`).
+		Where("s.inventory_id = ? AND s.quantity > 0", item.WarehouseInventoryID).
+		Order("l.code").
+		Scan(&stocks).Error; err != nil {
+		return nil, err
+	}
+
+	remaining := item.Quantity
+	var lines []PickLine
+	for _, stock := range stocks {
+		if remaining == 0 {
+			break
+		}
+		take := stock.Quantity
+		if take > remaining {
+			take = remaining
+		}
+
+		if err := changeLocationStock(tx, stock.LocationID, inventory, -take); err != nil {
+			return nil, err
+		}
+
+		locationID := stock.LocationID
+		move := Models.LocationMove{
+			InventoryID:    inventory.InventoryID,
+			ProductID:      inventory.ProductID,
+			BranchID:       inventory.BranchID,
+			FromLocationID: &locationID,
+			Quantity:       take,
+			MoveType:       "Pick",
+			DocumentID:     &shipment.ShipmentID,
+			MovedBy:        movedBy,
+			CreatedAt:      time.Now(),
+		}
+		if err := tx.Create(&move).Error; err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, PickLine{
+			InventoryID:  inventory.InventoryID,
+			ProductID:    inventory.ProductID,
+			LocationID:   &locationID,
+			LocationCode: stock.Code,
+			Quantity:     take,
+		})
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		move := Models.LocationMove{
+			InventoryID: inventory.InventoryID,
+			ProductID:   inventory.ProductID,
+			BranchID:    inventory.BranchID,
+			Quantity:    remaining,
+			MoveType:    "Pick",
+			DocumentID:  &shipment.ShipmentID,
+			MovedBy:     movedBy,
+			CreatedAt:   time.Now(),
+		}
+		if err := tx.Create(&move).Error; err != nil {
+			return nil, err
+		}
+		lines = append(lines, PickLine{InventoryID: inventory.InventoryID, ProductID: inventory.ProductID, Quantity: remaining})
+	}
+
+	return lines, nil
+}
+
+// แนะนำตำแหน่งจัดเก็บสำหรับสินค้าที่รับเข้าตามใบรับสินค้า
+func receiptPutawaySuggestions(db *gorm.DB, lines []Models.GoodsReceiptLine) ([]PutawaySuggestion, error) {
+	suggestions := []PutawaySuggestion{}
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if line.InventoryID == "" || seen[line.InventoryID] {
+			continue
+		}
+		seen[line.InventoryID] = true
+
+		var inventory Models.Inventory
+		if err := db.Where("inventory_id = ?", line.InventoryID).First(&inventory).Error; err != nil {
+			return nil, err
+		}
+		unlocated, err := unlocatedQuantity(db, inventory)
+		if err != nil {
+			return nil, err
+		}
+		if unlocated <= 0 {
+			continue
+		}
+		suggestion, err := suggestPutaway(db, inventory, unlocated)
+		if err != nil {
+			return nil, err
+		}
+		if suggestion != nil {
+			suggestions = append(suggestions, *suggestion)
+		}
+	}
+	return suggestions, nil
+}
+
+// เพิ่มตำแหน่งจัดเก็บ
+func AddLocation(db *gorm.DB, c *fiber.Ctx) error {
+	type LocationRequest struct {
+		BranchID string `json:"branch_id" validate:"required"`
+		ParentID string `json:"parent_id"`
+		Type     string `json:"type" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+		Name     string `json:"name"`
+	}
+
+	var req LocationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.BranchID == "" || req.Type == "" || req.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id, type and code are required"})
+	}
+
+	var branch Models.Branches
+	if err := db.Where("branch_id = ?", req.BranchID).First(&branch).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Branch not found"})
+	}
+
+	parentType := ""
+	if req.ParentID != "" {
+		var parent Models.Location
+		if err := db.Where("location_id = ?", req.ParentID).First(&parent).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Parent location not found"})
+		}
+		if parent.BranchID != req.BranchID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Parent location belongs to another branch"})
+		}
+		parentType = parent.Type
+	}
+
+	if locationChildType[parentType] != req.Type {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Location type %s is not allowed here, expected %s", req.Type, locationChildType[parentType])})
+	}
+
+	location := Models.Location{
+		BranchID:  req.BranchID,
+		ParentID:  optionalString(req.ParentID),
+		Type:      req.Type,
+		Code:      req.Code,
+		Name:      req.Name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := db.Create(&location).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create location: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Location created successfully", "data": location})
+}
+
+// ดูโครงสร้างตำแหน่งจัดเก็บของสาขา
+func LookLocations(db *gorm.DB, c *fiber.Ctx) error {
+	branchID := c.Query("branch_id")
+	if branchID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id query parameter is required"})
+	}
+
+	var locations []Models.Location
+	if err := db.Where("branch_id = ? AND parent_id IS NULL", branchID).
+		Preload("Children.Children.Children").
+		Order("code").
+		Find(&locations).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch locations: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"locations": locations})
+}
+
+// ดูสินค้าใน Bin
+func GetLocationStock(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var stocks []Models.LocationStock
+	if err := db.Where("location_id = ?", id).Find(&stocks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch location stock: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": stocks})
+}
+
+// ดูจำนวนสินค้าของ Inventory แยกตาม Bin พร้อมจำนวนที่ยังไม่จัดเก็บ
+func GetInventoryLocations(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var inventory Models.Inventory
+	if err := db.Where("inventory_id = ?", id).First(&inventory).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Inventory not found"})
+	}
+
+	var stocks []struct {
+		LocationID   string `json:"location_id"`
+		LocationCode string `json:"location_code"`
+		Quantity     int    `json:"quantity"`
+	}
+	if err := db.Table(`"LocationStock" s`).
+		Select("s.location_id, l.code AS location_code, s.quantity").
+		Joins(`JOIN "Location" l ON l.location_id = s.location_id`).
+		Where("s.inventory_id = ?", id).
+		Order("l.code").
+		Scan(&stocks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory locations: " + err.Error()})
+	}
+
+	unlocated, err := unlocatedQuantity(db, inventory)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to calculate unlocated quantity: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"inventory": inventory, "locations": stocks, "unlocated": unlocated})
+}
+
+// ลบตำแหน่งจัดเก็บ (ต้องไม่มีสินค้าและไม่มีตำแหน่งย่อย)
+func DeleteLocation(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var location Models.Location
+	if err := db.Where("location_id = ?", id).First(&location).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Location not found"})
+	}
+
+	dependencies, err := countDependencies(map[string]*gorm.DB{
+		"children": db.Model(&Models.Location{}).Where("parent_id = ?", id),
+		"stock":    db.Model(&Models.LocationStock{}).Where("location_id = ? AND quantity > 0", id),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check dependencies: " + err.Error()})
+	}
+	if len(dependencies) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Location is not empty", "dependencies": dependencies})
+	}
+
+	if err := db.Delete(&location).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete location: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Location deleted successfully"})
+}
+
+// แนะนำตำแหน่งจัดเก็บสำหรับ Order ที่รับเข้าแล้ว (จากใบรับสินค้าทุกใบของ Order)
+func GetOrderPutaway(db *gorm.DB, c *fiber.Ctx) error {
+	var lines []Models.GoodsReceiptLine
+	if err := db.Joins(`JOIN "GoodsReceipt" r ON r.receipt_id = "GoodsReceiptLine".receipt_id`).
+		Where("r.order_id = ?", c.Params("id")).Find(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build putaway suggestions: " + err.Error()})
+	}
+
+	suggestions, err := receiptPutawaySuggestions(db, lines)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build putaway suggestions: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"putaway_suggestions": suggestions})
+}
+
+// จัดเก็บสินค้าที่ยังไม่มีตำแหน่งเข้า Bin
+func PutawayStock(db *gorm.DB, c *fiber.Ctx) error {
+	type PutawayRequest struct {
+		InventoryID string `json:"inventory_id" validate:"required"`
+		LocationID  string `json:"location_id" validate:"required"`
+		Quantity    int    `json:"quantity" validate:"required,min=1"`
+	}
+
+	var req PutawayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Quantity must be greater than 0"})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var inventory Models.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("inventory_id = ?", req.InventoryID).First(&inventory).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Inventory not found")
+		}
+
+		var location Models.Location
+		if err := tx.Where("location_id = ? AND type = ?", req.LocationID, "Bin").First(&location).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Bin location not found")
+		}
+		if location.BranchID != inventory.BranchID {
+			return fiber.NewError(fiber.StatusBadRequest, "Location belongs to another branch")
+		}
+
+		unlocated, err := unlocatedQuantity(tx, inventory)
+		if err != nil {
+			return err
+		}
+		if req.Quantity > unlocated {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Only %d units are waiting for putaway", unlocated))
+		}
+
+		if err := changeLocationStock(tx, location.LocationID, inventory, req.Quantity); err != nil {
+			return err
+		}
+
+		return tx.Create(&Models.LocationMove{
+			InventoryID:  inventory.InventoryID,
+			ProductID:    inventory.ProductID,
+			BranchID:     inventory.BranchID,
+			ToLocationID: &location.LocationID,
+			Quantity:     req.Quantity,
+			MoveType:     "Putaway",
+			MovedBy:      currentUsername(c),
+			CreatedAt:    time.Now(),
+		}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to put away stock: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Stock put away successfully"})
+}
+
+// ย้ายสินค้าระหว่าง Bin ภายในสาขาเดียวกัน
+func MoveLocationStock(db *gorm.DB, c *fiber.Ctx) error {
+	type MoveRequest struct {
+		InventoryID    string `json:"inventory_id" validate:"required"`
+		FromLocationID string `json:"from_location_id" validate:"required"`
+		ToLocationID   string `json:"to_location_id" validate:"required"`
+		Quantity       int    `json:"quantity" validate:"required,min=1"`
+	}
+
+	var req MoveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if req.Quantity <= 0 || req.FromLocationID == req.ToLocationID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Quantity must be greater than 0 and locations must differ"})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var inventory Models.Inventory
+		if err := tx.Where("inventory_id = ?", req.InventoryID).First(&inventory).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Inventory not found")
+		}
+
+		var target Models.Location
+		if err := tx.Where("location_id = ? AND type = ?", req.ToLocationID, "Bin").First(&target).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Target bin not found")
+		}
+		if target.BranchID != inventory.BranchID {
+			return fiber.NewError(fiber.StatusBadRequest, "Target bin belongs to another branch")
+		}
+
+		if err := changeLocationStock(tx, req.FromLocationID, inventory, -req.Quantity); err != nil {
+			return err
+		}
+		if err := changeLocationStock(tx, req.ToLocationID, inventory, req.Quantity); err != nil {
+			return err
+		}
+
+		return tx.Create(&Models.LocationMove{
+			InventoryID:    inventory.InventoryID,
+			ProductID:      inventory.ProductID,
+			BranchID:       inventory.BranchID,
+			FromLocationID: &req.FromLocationID,
+			ToLocationID:   &req.ToLocationID,
+			Quantity:       req.Quantity,
+			MoveType:       "Move",
+			MovedBy:        currentUsername(c),
+			CreatedAt:      time.Now(),
+		}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to move stock: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Stock moved successfully"})
+}
+
+// ดูรายการหยิบสินค้า (pick list) ของ Shipment
+func GetShipmentPickList(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var lines []PickLine
+	if err := db.Table(`"LocationMove" m`).
+		Select("m.inventory_id, m.product_id, m.from_location_id AS location_id, COALESCE(l.code, '') AS location_code, m.quantity").
+		Joins(`LEFT JOIN "Location" l ON l.location_id = m.from_location_id`).
+		Where("m.document_id = ? AND m.move_type = ?", id, "Pick").
+		Order("l.code NULLS LAST").
+		Scan(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch pick list: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"pick_list": lines})
+}
+
+func LocationRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Locations", func(c *fiber.Ctx) error {
+		return LookLocations(db, c)
+	})
+
+	app.Post("/Locations", func(c *fiber.Ctx) error {
+		return AddLocation(auditDB(db, c), c)
+	})
+
+	app.Post("/Locations/putaway", func(c *fiber.Ctx) error {
+		return PutawayStock(auditDB(db, c), c)
+	})
+
+	app.Post("/Locations/move", func(c *fiber.Ctx) error {
+		return MoveLocationStock(auditDB(db, c), c)
+	})
+
+	app.Get("/Locations/:id/stock", func(c *fiber.Ctx) error {
+		return GetLocationStock(db, c)
+	})
+
+	app.Delete("/Locations/:id", func(c *fiber.Ctx) error {
+		return DeleteLocation(auditDB(db, c), c)
+	})
+
+	app.Get("/Inventory/:id/locations", func(c *fiber.Ctx) error {
+		return GetInventoryLocations(db, c)
+	})
+
+	app.Get("/Orders/:id/putaway", func(c *fiber.Ctx) error {
+		return GetOrderPutaway(db, c)
+	})
+
+	app.Get("/Shipments/:id/picklist", func(c *fiber.Ctx) error {
+		return GetShipmentPickList(db, c)
+	})
+}
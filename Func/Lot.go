@@ -0,0 +1,222 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LotRequest ข้อมูลล็อตที่รับเข้าพร้อม Order
+type LotRequest struct {
+	ProductID  string `json:"product_id"`
+	LotNumber  string `json:"lot_number"`
+	ExpiryDate string `json:"expiry_date"`
+	Quantity   int    `json:"quantity"`
+}
+
+// จัดกลุ่มล็อตตาม ProductID และตรวจสอบข้อมูล
+func groupLotsByProduct(lots []LotRequest) (map[string][]LotRequest, error) {
+	grouped := map[string][]LotRequest{}
+	for _, lot := range lots {
+		if lot.ProductID == "" || lot.LotNumber == "" {
+			return nil, fmt.Errorf("product_id and lot_number are required for every lot")
+		}
+		if lot.Quantity <= 0 {
+			return nil, fmt.Errorf("lot quantity must be greater than 0 for lot %s", lot.LotNumber)
+		}
+		if lot.ExpiryDate != "" {
+			if _, err := parseDate(lot.ExpiryDate); err != nil {
+				return nil, fmt.Errorf("invalid expiry_date for lot %s", lot.LotNumber)
+			}
+		}
+		grouped[lot.ProductID] = append(grouped[lot.ProductID], lot)
+	}
+	return grouped, nil
+}
+
+// บันทึกล็อตที่รับเข้า Inventory (ถ้าไม่ระบุล็อต จะสร้างล็อตเดียวโดยใช้เลขที่ Order)
+func receiveLots(tx *gorm.DB, inventory Models.Inventory, order Models.Order, quantity int, lots []LotRequest) error {
+	now := time.Now()
+
+	if len(lots) == 0 {
+		lots = []LotRequest{{ProductID: inventory.ProductID, LotNumber: order.OrderNumber, Quantity: quantity}}
+	}
+
+	total := 0
+	for _, lot := range lots {
+		total += lot.Quantity
+	}
+	if total != quantity {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("lot quantities for product %s (%d) do not match received quantity (%d)", inventory.ProductID, total, quantity))
+	}
+
+	for _, lot := range lots {
+		record := Models.InventoryLot{
+			InventoryID:  inventory.InventoryID,
+			ProductID:    inventory.ProductID,
+			BranchID:     inventory.BranchID,
+			LotNumber:    lot.LotNumber,
+			OrderID:      &order.OrderID,
+			ReceivedDate: now,
+			Quantity:     lot.Quantity,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if lot.ExpiryDate != "" {
+			expiry, _ := parseDate(lot.ExpiryDate)
+			record.ExpiryDate = &expiry
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// หยิบล็อตแบบ FEFO (หมดอายุก่อน ออกก่อน) สำหรับ ShipmentItem
+// สต็อกที่ไม่มีล็อต (รับเข้าก่อนมีระบบล็อต) จะไม่ถูกบันทึกเป็น pick
+func consumeLotsFEFO(tx *gorm.DB, item Models.ShipmentItem) ([]Models.ShipmentLotPick, error) {
+	var lots []Models.InventoryLot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ? AND quantity > 0", item.WarehouseInventoryID).
+		Order("expiry_date ASC NULLS LAST, received_date ASC").
+		Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	remaining := item.Quantity
+	var picks []Models.ShipmentLotPick
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+
+		take := lot.Quantity
+		if take > remaining {
+			take = remaining
+		}
+
+		if err := tx.Model(&Models.InventoryLot{}).
+			Where("lot_id = ?", lot.LotID).
+			Updates(map[string]interface{}{"quantity": gorm.Expr("quantity - ?", take), "updated_at": time.Now()}).Error; err != nil {
+			return nil, err
+		}
+
+		pick := Models.ShipmentLotPick{
+			ShipmentID:     item.ShipmentID,
+			ShipmentListID: item.ShipmentListID,
+			LotID:          lot.LotID,
+			LotNumber:      lot.LotNumber,
+			ExpiryDate:     lot.ExpiryDate,
+			Quantity:       take,
+			CreatedAt:      time.Now(),
+		}
+		if err := tx.Create(&pick).Error; err != nil {
+			return nil, err
+		}
+
+		picks = append(picks, pick)
+		remaining -= take
+	}
+
+	return picks, nil
+}
+
+// ตัดล็อตของ Inventory แบบ FEFO สำหรับการลดสต็อกที่ไม่ได้ผูกกับ Shipment (เช่น ตรวจนับ, ปรับปรุงสต็อก)
+// สต็อกที่ไม่มีล็อตจะถูกตัดเท่าที่มีล็อตเหลืออยู่
+func reduceLotsFEFO(tx *gorm.DB, inventoryID string, quantity int) error {
+	var lots []Models.InventoryLot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ? AND quantity > 0", inventoryID).
+		Order("expiry_date ASC NULLS LAST, received_date ASC").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		take := lot.Quantity
+		if take > remaining {
+			take = remaining
+		}
+		if err := tx.Model(&Models.InventoryLot{}).
+			Where("lot_id = ?", lot.LotID).
+			Updates(map[string]interface{}{"quantity": gorm.Expr("quantity - ?", take), "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	return nil
+}
+
+// ดูล็อตของ Inventory
+func GetInventoryLots(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var lots []Models.InventoryLot
+	if err := db.Where("inventory_id = ?", id).
+		Order("expiry_date ASC NULLS LAST, received_date ASC").
+		Find(&lots).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch lots: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"data": lots})
+}
+
+// ดูล็อตที่จะหมดอายุภายใน N วัน แยกตามสาขา
+func GetExpiringLots(db *gorm.DB, c *fiber.Ctx) error {
+	days := c.QueryInt("days", 30)
+	if days < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "days must be greater or equal to 0"})
+	}
+
+	var lots []struct {
+		LotID        string    `json:"lot_id"`
+		InventoryID  string    `json:"inventory_id"`
+		ProductID    string    `json:"product_id"`
+		ProductName  string    `json:"product_name"`
+		BranchID     string    `json:"branch_id"`
+		BranchName   string    `json:"branch_name"`
+		LotNumber    string    `json:"lot_number"`
+		ReceivedDate time.Time `json:"received_date"`
+		ExpiryDate   time.Time `json:"expiry_date"`
+		Quantity     int       `json:"quantity"`
+		DaysLeft     int       `json:"days_left"`
+	}
+
+	query := db.Table(`"InventoryLot" l`).
+		Select(`l.lot_id, l.inventory_id, l.product_id, p.product_name, l.branch_id, b.b_name AS branch_name,
+			l.lot_number, l.received_date, l.expiry_date, l.quantity,
+			(l.expiry_date::date - CURRENT_DATE) AS days_left`).
+		Joins(`LEFT JOIN "Product" p ON l.product_id::uuid = p.product_id`).
+		Joins(`LEFT JOIN "Branches" b ON l.branch_id::uuid = b.branch_id`).
+		Where("l.quantity > 0 AND l.expiry_date IS NOT NULL").
+		Where("l.expiry_date::date <= CURRENT_DATE + ?::int", days)
+
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("l.branch_id = ?", branchID)
+	}
+
+	if err := query.Order("l.branch_id, l.expiry_date ASC").Scan(&lots).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch expiring lots: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"expiring_lots": lots})
+}
+
+func LotRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Lots/expiring", func(c *fiber.Ctx) error {
+		return GetExpiringLots(db, c)
+	})
+
+	app.Get("/Inventory/:id/lots", func(c *fiber.Ctx) error {
+		return GetInventoryLots(db, c)
+	})
+}
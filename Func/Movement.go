@@ -0,0 +1,50 @@
+package Func
+
+import (
+	"Api/Models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// บันทึกการเคลื่อนไหวของ Inventory (เรียกหลังจากอัปเดตจำนวนแล้ว เพื่อเก็บยอดคงเหลือหลังทำรายการ)
+func recordMovement(tx *gorm.DB, inventory Models.Inventory, quantity int, movementType, documentType, documentID, note, createdBy string) error {
+	var balance int
+	if err := tx.Model(&Models.Inventory{}).
+		Select("quantity").
+		Where("inventory_id = ?", inventory.InventoryID).
+		Scan(&balance).Error; err != nil {
+		return err
+	}
+
+	movement := Models.InventoryMovement{
+		InventoryID:  inventory.InventoryID,
+		ProductID:    inventory.ProductID,
+		BranchID:     inventory.BranchID,
+		Quantity:     quantity,
+		BalanceAfter: balance,
+		MovementType: movementType,
+		DocumentType: documentType,
+		DocumentID:   documentID,
+		Note:         note,
+		CreatedBy:    createdBy,
+		CreatedAt:    time.Now(),
+	}
+	return tx.Create(&movement).Error
+}
+
+// ห้ามเปลี่ยนจำนวน Inventory ที่อยู่ระหว่างการตรวจนับแบบ freeze
+func ensureNotFrozen(tx *gorm.DB, inventoryID string) error {
+	var count int64
+	if err := tx.Table(`"StockCountLine" l`).
+		Joins(`JOIN "StockCount" s ON s.count_id = l.count_id`).
+		Where("l.inventory_id = ? AND s.freeze = ? AND s.status = ?", inventoryID, true, "Open").
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, "inventory is frozen by an open stock count: "+inventoryID)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// รูปแบบเลขที่เอกสารเริ่มต้น (แก้ไขได้ผ่าน PUT /DocumentNumbering/:type)
+var defaultNumbering = map[string]Models.DocumentNumbering{
+	"PurchaseOrder":  {DocumentType: "PurchaseOrder", Prefix: "PO", Padding: 6, PerBranch: true, Yearly: true},
+	"Shipment":       {DocumentType: "Shipment", Prefix: "TR", Padding: 6, PerBranch: true, Yearly: true},
+	"GoodsReceipt":   {DocumentType: "GoodsReceipt", Prefix: "GR", Padding: 6, PerBranch: true, Yearly: true},
+	"StockCount":     {DocumentType: "StockCount", Prefix: "SC", Padding: 6, PerBranch: true, Yearly: true},
+	"Adjustment":     {DocumentType: "Adjustment", Prefix: "ADJ", Padding: 6, PerBranch: true, Yearly: true},
+	"ReturnToVendor": {DocumentType: "ReturnToVendor", Prefix: "RTV", Padding: 6, PerBranch: true, Yearly: true},
+}
+
+var branchCodePattern = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+
+// ตรวจและแปลงรหัสสาขาเป็นตัวพิมพ์ใหญ่ (ว่างได้)
+func normalizeBranchCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code != "" && !branchCodePattern.MatchString(code) {
+		return "", fmt.Errorf("code must be 1-10 letters or digits")
+	}
+	return code, nil
+}
+
+// รูปแบบเลขที่ของประเภทเอกสาร (ที่ตั้งค่าไว้ หรือค่าเริ่มต้น)
+func documentNumbering(tx *gorm.DB, documentType string) (Models.DocumentNumbering, error) {
+	var numbering Models.DocumentNumbering
+	if err := tx.Where("document_type = ?", documentType).First(&numbering).Error; err == nil {
+		return numbering, nil
+	}
+	numbering, ok := defaultNumbering[documentType]
+	if !ok {
+		return numbering, fmt.Errorf("unknown document type: %s", documentType)
+	}
+	return numbering, nil
+}
+
+// รหัสสาขาสำหรับเลขที่เอกสาร (สาขาที่ยังไม่ตั้งรหัสใช้ 4 ตัวแรกของ branch_id)
+func documentBranchCode(tx *gorm.DB, branchID string) string {
+	if branchID == "" {
+		return "HQ"
+	}
+	var branch Models.Branches
+	if err := tx.Unscoped().Select("branch_id, code").Where("branch_id = ?", branchID).First(&branch).Error; err == nil && branch.Code != "" {
+		return branch.Code
+	}
+	code := strings.ToUpper(strings.ReplaceAll(branchID, "-", ""))
+	if len(code) > 4 {
+		code = code[:4]
+	}
+	return code
+}
+
+// ออกเลขที่เอกสารถัดไป เช่น PO-BKK-2026-000123
+// ต้องเรียกภายใน transaction ที่สร้างเอกสาร: แถวลำดับถูกล็อกจนกว่าจะ commit และถ้า rollback เลขจะถูกคืน จึงไม่มีเลขขาดช่วง
+func NextDocumentNumber(tx *gorm.DB, documentType, branchID string, at time.Time) (string, error) {
+	numbering, err := documentNumbering(tx, documentType)
+	if err != nil {
+		return "", err
+	}
+
+	code, year := "", 0
+	if numbering.PerBranch {
+		code = documentBranchCode(tx, branchID)
+	}
+	if numbering.Yearly {
+		year = at.Year()
+	}
+
+	var value int64
+	if err := tx.Raw(`
+		INSERT INTO "DocumentSequence" (sequence_id, document_type, branch_code, year, last_value, updated_at)
+		VALUES (gen_random_uuid(), ?, ?, ?, 1, NOW())
+		ON CONFLICT (document_type, branch_code, year)
+		DO UPDATE SET last_value = "DocumentSequence".last_value + 1, updated_at = NOW()
+		RETURNING last_value`, documentType, code, year).Scan(&value).Error; err != nil {
+		return "", err
+	}
+
+	parts := []string{}
+	if numbering.Prefix != "" {
+		parts = append(parts, numbering.Prefix)
+	}
+	if code != "" {
+		parts = append(parts, code)
+	}
+	if year != 0 {
+		parts = append(parts, fmt.Sprint(year))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", numbering.Padding, value))
+	return strings.Join(parts, "-"), nil
+}
+
+// ดูรูปแบบเลขที่เอกสารทุกประเภท พร้อมเลขล่าสุดที่ออกไป
+func LookDocumentNumbering(db *gorm.DB, c *fiber.Ctx) error {
+	numberings := make([]Models.DocumentNumbering, 0, len(defaultNumbering))
+	for documentType := range defaultNumbering {
+		numbering, err := documentNumbering(db, documentType)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		numberings = append(numberings, numbering)
+	}
+	sort.Slice(numberings, func(i, j int) bool {
+		return numberings[i].DocumentType < numberings[j].DocumentType
+	})
+
+	var sequences []Models.DocumentSequence
+	if err := db.Order("document_type, branch_code, year").Find(&sequences).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch document sequences"})
+	}
+	return c.JSON(fiber.Map{"data": numberings, "sequences": sequences})
+}
+
+// แก้ไขรูปแบบเลขที่ของประเภทเอกสาร (เลขที่ออกไปแล้วไม่เปลี่ยน)
+func UpdateDocumentNumbering(db *gorm.DB, c *fiber.Ctx) error {
+	numbering, err := documentNumbering(db, c.Params("type"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// ค่าที่ไม่ได้ส่งมาคงค่าเดิม
+	req := struct {
+		Prefix    *string `json:"prefix"`
+		Padding   *int    `json:"padding"`
+		PerBranch *bool   `json:"per_branch"`
+		Yearly    *bool   `json:"yearly"`
+	}{}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if req.Prefix != nil {
+		numbering.Prefix = strings.ToUpper(strings.TrimSpace(*req.Prefix))
+		if numbering.Prefix != "" && !branchCodePattern.MatchString(numbering.Prefix) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "prefix must be 1-10 letters or digits"})
+		}
+	}
+	if req.Padding != nil {
+		if *req.Padding < 1 || *req.Padding > 12 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "padding must be between 1 and 12"})
+		}
+		numbering.Padding = *req.Padding
+	}
+	if req.PerBranch != nil {
+		numbering.PerBranch = *req.PerBranch
+	}
+	if req.Yearly != nil {
+		numbering.Yearly = *req.Yearly
+	}
+	numbering.UpdatedAt = time.Now()
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&numbering).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update document numbering: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Document numbering updated successfully", "data": numbering})
+}
+
+// หาเอกสารจากเลขที่ (ใช้ได้ทั้งเลขที่แบบใหม่และ ULID เดิม)
+func FindDocumentByNumber(db *gorm.DB, c *fiber.Ctx) error {
+	number := strings.TrimSpace(c.Params("number"))
+
+	var order Models.Order
+	if err := db.Preload("OrderItems").Where("order_number = ?", number).First(&order).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "PurchaseOrder", "data": order})
+	}
+	var shipment Models.Shipment
+	if err := db.Preload("ShipmentItems").Where("shipment_number = ?", number).First(&shipment).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "Shipment", "data": shipment})
+	}
+	var receipt Models.GoodsReceipt
+	if err := db.Preload("Lines").Where("receipt_number = ?", number).First(&receipt).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "GoodsReceipt", "data": receipt})
+	}
+	var count Models.StockCount
+	if err := db.Preload("Lines").Where("count_number = ?", number).First(&count).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "StockCount", "data": count})
+	}
+	var adjustment Models.AdjustmentDocument
+	if err := db.Preload("Lines").Where("adjustment_number = ?", number).First(&adjustment).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "Adjustment", "data": adjustment})
+	}
+	var vendorReturn Models.ReturnToVendor
+	if err := db.Preload("Lines").Preload("CreditNotes").Where("return_number = ?", number).First(&vendorReturn).Error; err == nil {
+		return c.JSON(fiber.Map{"document_type": "ReturnToVendor", "data": vendorReturn})
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+}
+
+func DocumentRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Documents/:number", func(c *fiber.Ctx) error {
+		return FindDocumentByNumber(db, c)
+	})
+
+	app.Get("/DocumentNumbering", func(c *fiber.Ctx) error {
+		return LookDocumentNumbering(db, c)
+	})
+
+	app.Put("/DocumentNumbering/:type", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return UpdateDocumentNumbering(auditDB(db, c), c)
+	})
+}
@@ -1,22 +1,26 @@
 package Func
 
 import (
+	"Api/Authentication"
 	"Api/Models"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/oklog/ulid/v2"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderItemRequest โครงสร้างข้อมูลสำหรับรับข้อมูลสินค้าใน Order
 type OrderItemRequest struct {
-	ProductID string  `json:"productid" validate:"required"`
-	Quantity  int     `json:"quantity" validate:"required,min=1"`
-	UnitPrice float64 `json:"unitprice" validate:"required,min=0"`
+	ProductID string `json:"productid" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+	OrderLinePricing
 }
 
 // สร้าง ULID สำหรับ OrderNumber
@@ -37,12 +41,27 @@ func parseUUIDPointer(id *string) *uuid.UUID {
 	return &u
 }
 
+// ดึง HTTP status จาก fiber.Error ที่ส่งออกมาจาก Transaction (ถ้าไม่มีใช้ค่า fallback)
+func errorStatus(err error, fallback int) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	var stockErr *InsufficientStockError
+	if errors.As(err, &stockErr) {
+		return fiber.StatusConflict
+	}
+	return fallback
+}
+
 // เพื่มข้อมูล Order
 func AddOrder(db *gorm.DB, c *fiber.Ctx) error {
 	type OrderRequest struct {
-		SupplierID  string             `json:"supplier_id" validate:"required"`
-		EmployeesID *string            `json:"employees_id"`
-		OrderItems  []OrderItemRequest `json:"order_items" validate:"required"`
+		SupplierID   string             `json:"supplier_id" validate:"required"`
+		BranchID     string             `json:"branch_id" validate:"required"`
+		EmployeesID  *string            `json:"employees_id"`
+		PromisedDate string             `json:"promised_date"`
+		OrderItems   []OrderItemRequest `json:"order_items" validate:"required"`
 	}
 
 	var req OrderRequest
@@ -55,15 +74,51 @@ func AddOrder(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "At least one product is required in the order"})
 	}
 
+	// ✅ ตรวจสอบสาขาปลายทางที่จะรับสินค้า
+	if req.BranchID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id is required"})
+	}
+	if err := db.Where("branch_id = ?", req.BranchID).First(&Models.Branches{}).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Branch not found: " + req.BranchID})
+	}
+
+	supplierID, err := uuid.Parse(req.SupplierID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier_id"})
+	}
+	if err := db.Where("supplier_id = ?", req.SupplierID).First(&Models.Supplier{}).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier not found: " + req.SupplierID})
+	}
+
+	var promisedDate *time.Time
+	if req.PromisedDate != "" {
+		date, err := parseDate(req.PromisedDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid promised_date"})
+		}
+		promisedDate = &date
+	}
+
 	// ✅ ใช้ Transaction เพื่อให้แน่ใจว่า Order และ OrderItems ถูกบันทึกพร้อมกัน
-	err := db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if promisedDate == nil {
+			promisedDate = defaultPromisedDate(tx, req.SupplierID, time.Now())
+		}
+
+		orderNumber, err := NextDocumentNumber(tx, "PurchaseOrder", req.BranchID, time.Now())
+		if err != nil {
+			return err
+		}
+
 		order := Models.Order{
-			OrderID:     uuid.New().String(),
-			OrderNumber: GenerateULID(),
-			Status:      "Pending",
-			SupplierID:  uuid.MustParse(req.SupplierID),
-			EmployeesID: parseUUIDPointer(req.EmployeesID),
-			CreatedAt:   time.Now(),
+			OrderID:      uuid.New().String(),
+			OrderNumber:  orderNumber,
+			Status:       "Draft",
+			SupplierID:   supplierID,
+			BranchID:     &req.BranchID,
+			PromisedDate: promisedDate,
+			EmployeesID:  parseUUIDPointer(req.EmployeesID),
+			CreatedAt:    time.Now(),
 		}
 
 		// ✅ บันทึก Order
@@ -75,33 +130,37 @@ func AddOrder(db *gorm.DB, c *fiber.Ctx) error {
 		var orderItems []Models.OrderItem
 		for _, item := range req.OrderItems {
 			if item.ProductID == "" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ProductID is required"})
+				return fiber.NewError(fiber.StatusBadRequest, "ProductID is required")
 			}
 
 			var product Models.Product
-			if err := db.Where("product_id = ?", item.ProductID).First(&product).Error; err != nil {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found: " + item.ProductID})
+			if err := tx.Where("product_id = ?", item.ProductID).First(&product).Error; err != nil {
+				return fiber.NewError(fiber.StatusNotFound, "Product not found: "+item.ProductID)
 			}
 
 			var productUnit Models.ProductUnit
-			if err := db.Where("product_id = ?", item.ProductID).First(&productUnit).Error; err != nil {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ProductUnit not found for product: " + item.ProductID})
+			if err := tx.Where("product_id = ?", item.ProductID).First(&productUnit).Error; err != nil {
+				return fiber.NewError(fiber.StatusNotFound, "ProductUnit not found for product: "+item.ProductID)
 			}
 
 			// ✅ แปลงจำนวนตามหน่วย
 			finalQuantity := item.Quantity * productUnit.ConversRate
 			if finalQuantity <= 0 {
-				return fmt.Errorf("Invalid final quantity for product: %s", item.ProductID)
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid final quantity for product: "+item.ProductID)
 			}
 
-			orderItems = append(orderItems, Models.OrderItem{
+			orderItem := Models.OrderItem{
 				OrderItemID: uuid.New().String(),
 				OrderID:     order.OrderID,
 				ProductID:   item.ProductID,
 				Quantity:    finalQuantity,
 				ConversRate: float64(productUnit.ConversRate),
 				CreatedAt:   time.Now(),
-			})
+			}
+			if err := applyOrderLinePricing(tx, req.SupplierID, &orderItem, item.OrderLinePricing); err != nil {
+				return err
+			}
+			orderItems = append(orderItems, orderItem)
 		}
 
 		// ✅ บันทึก OrderItems
@@ -118,78 +177,377 @@ func AddOrder(db *gorm.DB, c *fiber.Ctx) error {
 	})
 
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create order: " + err.Error()})
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to create order: " + err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Order created successfully"})
 }
 
-// อัปเดตค่า TotalAmount ของ Order
+// คำนวณยอดเงินของ Order ใหม่จาก OrderItem (Subtotal, Tax, GrandTotal และ TotalAmount)
+// ทุกรายการใน Order ต้องใช้สกุลเงินเดียวกัน
 func UpdateTotalAmount(db *gorm.DB, orderID string) error {
 	var orderItems []Models.OrderItem
 	if err := db.Where("order_id = ?", orderID).Find(&orderItems).Error; err != nil {
 		return err
 	}
 
-	var totalAmount float64
+	subtotal := decimal.Zero
+	tax := decimal.Zero
+	currency := ""
 	for _, item := range orderItems {
-		totalAmount += float64(item.Quantity) * item.ConversRate
+		if currency != "" && item.Currency != currency {
+			return fiber.NewError(fiber.StatusBadRequest, "all order items must use the same currency")
+		}
+		currency = item.Currency
+
+		lineTotal := item.LineTotal
+		computeOrderLine(&item)
+		if !item.LineTotal.Equal(lineTotal) {
+			if err := db.Model(&Models.OrderItem{}).Where("order_item_id = ?", item.OrderItemID).Update("line_total", item.LineTotal).Error; err != nil {
+				return err
+			}
+		}
+
+		subtotal = subtotal.Add(item.LineTotal)
+		tax = tax.Add(orderLineTax(item))
 	}
 
-	return db.Model(&Models.Order{}).Where("order_id = ?", orderID).Update("total_amount", totalAmount).Error
+	grandTotal := subtotal.Add(tax)
+	return db.Model(&Models.Order{}).Where("order_id = ?", orderID).Updates(map[string]interface{}{
+		"subtotal":     subtotal,
+		"tax":          tax,
+		"grand_total":  grandTotal,
+		"total_amount": grandTotal,
+	}).Error
 }
 
-// อัปเดตข้อมูล Order
-func UpdateOrder(db *gorm.DB, c *fiber.Ctx) error {
-	id := c.Params("id")
+// การเปลี่ยนสถานะ Order ที่ทำได้ผ่าน UpdateOrder
+// (Approved เกิดจาก approval workflow, PartiallyReceived และ Received เกิดจากการรับสินค้าด้วย GoodsReceipt เท่านั้น)
+var orderTransitions = map[string][]string{
+	"Draft":             {"Submitted", "Cancelled"},
+	"Submitted":         {"Draft", "Cancelled"},
+	"Approved":          {"Cancelled"},
+	"PartiallyReceived": {"Closed"},
+	"Received":          {"Closed"},
+}
 
-	var order Models.Order
-	if err := db.Where("order_id = ?", id).First(&order).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+func canTransitionOrder(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
 	}
+	return false
+}
 
-	if order.Status != "Pending" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Only orders with Pending status can be updated"})
-	}
+// อัปเดตข้อมูล Order (เปลี่ยนสถานะ และเปลี่ยนสาขาปลายทางได้ตอน Draft)
+// การส่งเป็น Submitted จะเริ่มคำขออนุมัติ ส่วน Approved ต้องผ่าน approval workflow เท่านั้น
+// สต็อกจะเพิ่มเมื่อรับสินค้าด้วย GoodsReceipt เท่านั้น ไม่ใช่ตอนอนุมัติ
+func UpdateOrder(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
 
 	var req struct {
-		Status string `json:"status"`
+		Status       string `json:"status"`
+		BranchID     string `json:"branch_id"`
+		PromisedDate string `json:"promised_date"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
 	}
+	if req.Status == "Approved" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Orders are approved through the approval workflow (POST /Orders/:id/approve)"})
+	}
+
+	var order Models.Order
+	var request *Models.ApprovalRequest
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", id).First(&order).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Order not found")
+		}
+
+		if req.BranchID != "" {
+			if order.Status != "Draft" {
+				return fiber.NewError(fiber.StatusBadRequest, "Branch can only be changed while the order is Draft")
+			}
+			if err := tx.Where("branch_id = ?", req.BranchID).First(&Models.Branches{}).Error; err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Branch not found: "+req.BranchID)
+			}
+			order.BranchID = &req.BranchID
+		}
+
+		if req.PromisedDate != "" {
+			if order.Status == "Received" || order.Status == "Closed" || order.Status == "Cancelled" {
+				return fiber.NewError(fiber.StatusBadRequest, "Promised date cannot be changed after the order is completed")
+			}
+			date, err := parseDate(req.PromisedDate)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "Invalid promised_date")
+			}
+			order.PromisedDate = &date
+		}
+
+		submitting := false
+		if req.Status != "" && req.Status != order.Status {
+			if !canTransitionOrder(order.Status, req.Status) {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Cannot change order status from %s to %s", order.Status, req.Status))
+			}
+			if req.Status == "Submitted" && order.BranchID == nil {
+				return fiber.NewError(fiber.StatusBadRequest, "A destination branch is required before submitting the order")
+			}
+			// ถอนกลับหรือยกเลิก: คำขออนุมัติที่ค้างอยู่ถูกยกเลิก
+			if err := cancelApproval(tx, "PurchaseOrder", order.OrderID); err != nil {
+				return err
+			}
+			order.Status = req.Status
+			submitting = req.Status == "Submitted"
+		}
+
+		order.UpdatedAt = time.Now()
+		if err := tx.Omit("OrderItems").Save(&order).Error; err != nil {
+			return err
+		}
+		if !submitting {
+			return nil
+		}
 
-	validStatuses := map[string]bool{"Pending": true, "Approved": true, "Rejected": true}
-	if !validStatuses[req.Status] {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
+		approval, _, err := startApproval(tx, "PurchaseOrder", order.OrderID, *order.BranchID, order.GrandTotal, currentUsername(c))
+		if err != nil {
+			return err
+		}
+		request = &approval
+		// กฎที่ไม่มีขั้นการอนุมัติจะอนุมัติทันที
+		return tx.Where("order_id = ?", order.OrderID).First(&order).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to update order: " + err.Error()})
 	}
 
-	if req.Status == "Approved" {
-		var orderItems []Models.OrderItem
-		if err := db.Where("order_id = ?", id).Find(&orderItems).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch order items"})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Order updated successfully", "data": order, "approval": request})
+}
+
+// แปลงสถานะ Order แบบเดิมเป็นสถานะของ PO lifecycle (รันครั้งเดียวตอนเพิ่มคอลัมน์ received_qty)
+// Order ที่อนุมัติแบบเดิมได้เพิ่มสต็อกไปแล้ว จึงถือว่ารับครบ
+func MigrateLegacyOrders(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Models.Order{}).Where("status = ?", "Pending").Update("status", "Submitted").Error; err != nil {
+			return err
 		}
+		if err := tx.Model(&Models.Order{}).Where("status = ?", "Rejected").Update("status", "Cancelled").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Models.OrderItem{}).
+			Where(`order_id IN (SELECT order_id FROM "Order" WHERE status = ?)`, "Approved").
+			Update("received_qty", gorm.Expr("quantity")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Models.Order{}).Where("status = ?", "Approved").Update("status", "Received").Error
+	})
+}
 
-		for _, item := range orderItems {
-			fmt.Printf("Product ID: %s, Quantity: %d\n", item.ProductID, item.Quantity)
+// ยกเลิกหมายเลขซีเรียลที่รับเข้าด้วย Order (ต้องยังอยู่ในสต็อก)
+func cancelOrderSerials(tx *gorm.DB, order Models.Order) error {
+	var serials []Models.SerialNumber
+	if err := tx.Where(`serial_id IN (SELECT serial_id FROM "SerialMovement" WHERE document_type = ? AND document_id = ?)`, "Order", order.OrderID).
+		Find(&serials).Error; err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		switch serial.Status {
+		case "ReturnedToVendor", "Cancelled":
+			continue
+		case "InStock":
+		default:
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("serial number %s has already left stock (%s)", serial.SerialNo, serial.Status))
+		}
 
-			if err := db.Model(&Models.Inventory{}).
-				Where("product_id = ?", item.ProductID).
-				UpdateColumn("quantity", gorm.Expr("quantity + ?", item.Quantity)).Error; err != nil {
-				fmt.Printf("Failed to update inventory for Product ID: %s\n", item.ProductID)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update inventory for product: " + item.ProductID})
+		branchID := serial.BranchID
+		serial.Status = "Cancelled"
+		serial.UpdatedAt = time.Now()
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, branchID, "", "Order", order.OrderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ลงรายการกลับสต็อกที่ Order ทำไว้ (รับสินค้า หักด้วยที่ส่งคืน Supplier ไปแล้ว) แล้วปิด Order ด้วยสถานะ Cancelled หรือ Amended
+func reverseOrder(tx *gorm.DB, order *Models.Order, status, reason, username string) ([]StockReversal, error) {
+	if order.Status == "Cancelled" || order.Status == "Amended" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Order is already "+order.Status)
+	}
+
+	var openReturns int64
+	if err := tx.Model(&Models.ReturnToVendor{}).
+		Where("order_id = ? AND status IN ?", order.OrderID, []string{"Draft", "Submitted", "Approved"}).
+		Count(&openReturns).Error; err != nil {
+		return nil, err
+	}
+	if openReturns > 0 {
+		return nil, fiber.NewError(fiber.StatusConflict, "Order has open returns to vendor; cancel or dispatch them first")
+	}
+
+	if err := cancelApproval(tx, "PurchaseOrder", order.OrderID); err != nil {
+		return nil, err
+	}
+
+	var receiptIDs, returnIDs []string
+	if err := tx.Model(&Models.GoodsReceipt{}).Where("order_id = ?", order.OrderID).Pluck("receipt_id", &receiptIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Models.ReturnToVendor{}).
+		Where("order_id = ? AND status IN ?", order.OrderID, []string{"Dispatched", "Closed"}).
+		Pluck("return_id", &returnIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if err := cancelOrderSerials(tx, *order); err != nil {
+		return nil, err
+	}
+
+	reversals, err := reverseDocumentStock(tx,
+		[]movementSource{{DocumentType: "GoodsReceipt", DocumentIDs: receiptIDs}, {DocumentType: "ReturnToVendor", DocumentIDs: returnIDs}},
+		"PurchaseOrder", order.OrderID, status+" "+order.OrderNumber+": "+reason, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// ตัดล็อตที่รับเข้าด้วย Order นี้
+	for _, reversal := range reversals {
+		if reversal.Delta < 0 {
+			if err := returnLots(tx, reversal.InventoryID, order.OrderID, -reversal.Delta); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	order.Status = req.Status
-	order.UpdatedAt = time.Now()
-	if err := db.Save(&order).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order status"})
+	now := time.Now()
+	order.Status = status
+	order.CancelReason = reason
+	order.CancelledBy = username
+	order.CancelledAt = &now
+	order.UpdatedAt = now
+	return reversals, tx.Omit("OrderItems").Save(order).Error
+}
+
+// ยกเลิก Order (รับสินค้าแล้วก็ยกเลิกได้ โดยลงรายการกลับสต็อก ต้นทุน ล็อต และซีเรียลที่รับเข้า)
+func CancelOrder(db *gorm.DB, c *fiber.Ctx) error {
+	reason, err := parseCancelReason(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var order Models.Order
+	var reversals []StockReversal
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", c.Params("id")).First(&order).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Order not found")
+		}
+		var err error
+		reversals, err = reverseOrder(tx, &order, "Cancelled", reason, currentUsername(c))
+		return err
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to cancel order: " + err.Error()})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Order updated successfully"})
+	return c.JSON(fiber.Map{"message": "Order cancelled", "data": order, "reversals": reversals})
+}
+
+// แก้ไข Order ที่ส่งอนุมัติหรือรับสินค้าแล้ว: Order เดิมถูกลงรายการกลับและเป็นสถานะ Amended
+// ฉบับแก้ไขเป็น Order ใหม่สถานะ Draft ที่คัดลอกรายการเดิม (แก้ไขรายการต่อได้ก่อนส่งอนุมัติ)
+func AmendOrder(db *gorm.DB, c *fiber.Ctx) error {
+	reason, err := parseCancelReason(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	username := currentUsername(c)
+	var order, amendment Models.Order
+	var reversals []StockReversal
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", c.Params("id")).First(&order).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Order not found")
+		}
+		if order.Status == "Draft" {
+			return fiber.NewError(fiber.StatusBadRequest, "Draft orders can be edited directly")
+		}
+
+		var err error
+		if reversals, err = reverseOrder(tx, &order, "Amended", reason, username); err != nil {
+			return err
+		}
+
+		branchID := ""
+		if order.BranchID != nil {
+			branchID = *order.BranchID
+		}
+		now := time.Now()
+		orderNumber, err := NextDocumentNumber(tx, "PurchaseOrder", branchID, now)
+		if err != nil {
+			return err
+		}
+		amendment = Models.Order{
+			OrderID:       uuid.New().String(),
+			OrderNumber:   orderNumber,
+			Status:        "Draft",
+			SupplierID:    order.SupplierID,
+			BranchID:      order.BranchID,
+			PromisedDate:  order.PromisedDate,
+			EmployeesID:   order.EmployeesID,
+			AmendedFromID: &order.OrderID,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := tx.Omit("OrderItems").Create(&amendment).Error; err != nil {
+			return err
+		}
+
+		var items []Models.OrderItem
+		if err := tx.Where("order_id = ?", order.OrderID).Order("created_at").Find(&items).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i] = Models.OrderItem{
+				OrderID:     amendment.OrderID,
+				ProductID:   items[i].ProductID,
+				Quantity:    items[i].Quantity,
+				ConversRate: items[i].ConversRate,
+				UnitPrice:   items[i].UnitPrice,
+				Currency:    items[i].Currency,
+				Discount:    items[i].Discount,
+				TaxRate:     items[i].TaxRate,
+				LineTotal:   items[i].LineTotal,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		if err := UpdateTotalAmount(tx, amendment.OrderID); err != nil {
+			return err
+		}
+
+		order.AmendedToID = &amendment.OrderID
+		if err := tx.Model(&order).Update("amended_to_id", amendment.OrderID).Error; err != nil {
+			return err
+		}
+		return tx.Preload("OrderItems").Where("order_id = ?", amendment.OrderID).First(&amendment).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to amend order: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   "Order amended",
+		"data":      order,
+		"amendment": amendment,
+		"reversals": reversals,
+	})
 }
 
 // ดึงข้อมูล Order ทั้งหมด
@@ -208,14 +566,34 @@ func FindOrder(db *gorm.DB, c *fiber.Ctx) error {
 	if err := db.Preload("Supplier").Where("order_id = ?", id).First(&order).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
 	}
-	return c.JSON(fiber.Map{"data": order})
+	return c.JSON(fiber.Map{"data": order, "approval": approvalHistory(db, "PurchaseOrder", order.OrderID)})
 }
 
 // ลบข้อมูล Order
 func DeleteOrder(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
-	if err := db.Where("order_id = ?", id).Delete(&Models.Order{}).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete order"})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// Order ที่รับสินค้าแล้วลบไม่ได้ ต้องยกเลิกเพื่อให้สต็อกถูกลงรายการกลับ
+		var receiptIDs []string
+		if err := tx.Model(&Models.GoodsReceipt{}).Where("order_id = ?", id).Pluck("receipt_id", &receiptIDs).Error; err != nil {
+			return err
+		}
+		affected, err := documentAffectedStock(tx,
+			movementSource{DocumentType: "GoodsReceipt", DocumentIDs: receiptIDs},
+			movementSource{DocumentType: "PurchaseOrder", DocumentIDs: []string{id}})
+		if err != nil {
+			return err
+		}
+		if affected || len(receiptIDs) > 0 {
+			return fiber.NewError(fiber.StatusConflict, "Order has affected stock and cannot be deleted; cancel it instead (POST /Orders/:id/cancel)")
+		}
+
+		if err := cancelApproval(tx, "PurchaseOrder", id); err != nil {
+			return err
+		}
+		return tx.Where("order_id = ?", id).Delete(&Models.Order{}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to delete order: " + err.Error()})
 	}
 	return c.JSON(fiber.Map{"message": "Order deleted successfully"})
 }
@@ -230,14 +608,42 @@ func OrderRoutes(app *fiber.App, db *gorm.DB) {
 	})
 
 	app.Post("/Orders", func(c *fiber.Ctx) error {
-		return AddOrder(db, c)
+		return AddOrder(auditDB(db, c), c)
+	})
+
+	app.Put("/Orders/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return UpdateOrder(auditDB(db, c), c)
+	})
+
+	app.Post("/Orders/:id/cancel", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CancelOrder(auditDB(db, c), c)
+	})
+
+	app.Post("/Orders/:id/amend", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AmendOrder(auditDB(db, c), c)
+	})
+
+	app.Post("/Orders/:id/approve", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondDocumentApproval(auditDB(db, c), c, "PurchaseOrder", c.Params("id"), true)
+	})
+
+	app.Post("/Orders/:id/reject", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondDocumentApproval(auditDB(db, c), c, "PurchaseOrder", c.Params("id"), false)
+	})
+
+	app.Get("/Orders/:id/pdf", func(c *fiber.Ctx) error {
+		return GetOrderPDF(db, c)
+	})
+
+	app.Get("/Orders/:id/receipts", func(c *fiber.Ctx) error {
+		return LookGoodsReceipts(db, c)
 	})
 
-	app.Put("/Orders/:id", func(c *fiber.Ctx) error {
-		return UpdateOrder(db, c)
+	app.Post("/Orders/:id/receipts", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AddGoodsReceipt(auditDB(db, c), c)
 	})
 
 	app.Delete("/Orders/:id", func(c *fiber.Ctx) error {
-		return DeleteOrder(db, c)
+		return DeleteOrder(auditDB(db, c), c)
 	})
 }
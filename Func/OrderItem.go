@@ -7,6 +7,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// Order ที่ยังแก้ไขรายการได้ (ก่อนอนุมัติ)
+func editableOrder(tx *gorm.DB, orderID string) (Models.Order, error) {
+	var order Models.Order
+	if err := tx.Where("order_id = ?", orderID).First(&order).Error; err != nil {
+		return order, fiber.NewError(fiber.StatusNotFound, "ไม่พบคำสั่งซื้อ")
+	}
+	if order.Status != "Draft" {
+		return order, fiber.NewError(fiber.StatusBadRequest, "แก้ไขรายการได้เฉพาะคำสั่งซื้อสถานะ Draft (คำสั่งซื้อที่ส่งอนุมัติแล้วต้องถอนกลับเป็น Draft ก่อน)")
+	}
+	return order, nil
+}
+
 // AddOrderItem สร้างรายการคำสั่งซื้อ
 func AddOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 	type OrderItemRequest struct {
@@ -14,6 +26,7 @@ func AddOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 		ProductID   string  `json:"productid" validate:"required"`
 		Quantity    int     `json:"quantity" validate:"required,min=1"`
 		ConversRate float64 `json:"conversrate" validate:"required,min=0"`
+		OrderLinePricing
 	}
 
 	var req OrderItemRequest
@@ -28,13 +41,21 @@ func AddOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 		ConversRate: req.ConversRate,
 	}
 
-	if err := db.Create(&orderItem).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างรายการคำสั่งซื้อได้: " + err.Error()})
-	}
-
-	// อัปเดตยอดรวมของ Order
-	if err := UpdateTotalAmount(db, req.OrderID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถอัปเดตยอดรวมได้: " + err.Error()})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := editableOrder(tx, req.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := applyOrderLinePricing(tx, order.SupplierID.String(), &orderItem, req.OrderLinePricing); err != nil {
+			return err
+		}
+		if err := tx.Create(&orderItem).Error; err != nil {
+			return err
+		}
+		// อัปเดตยอดรวมของ Order
+		return UpdateTotalAmount(tx, req.OrderID)
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "ไม่สามารถสร้างรายการคำสั่งซื้อได้: " + err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "รายการคำสั่งซื้อถูกสร้างสำเร็จ", "data": orderItem})
@@ -48,19 +69,23 @@ func DeleteOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ไม่พบรายการคำสั่งซื้อ"})
 	}
 
-	if err := db.Delete(&orderItem).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถลบรายการคำสั่งซื้อได้: " + err.Error()})
-	}
-
-	// อัปเดตยอดรวมของ Order
-	if err := UpdateTotalAmount(db, orderItem.OrderID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถอัปเดตยอดรวมได้: " + err.Error()})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := editableOrder(tx, orderItem.OrderID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&orderItem).Error; err != nil {
+			return err
+		}
+		// อัปเดตยอดรวมของ Order
+		return UpdateTotalAmount(tx, orderItem.OrderID)
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "ไม่สามารถลบรายการคำสั่งซื้อได้: " + err.Error()})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "รายการคำสั่งซื้อถูกลบสำเร็จ"})
 }
 
-// อัปเดตยอดรวมของ Order
+// อัปเดตรายการคำสั่งซื้อ และยอดรวมของ Order
 func UpdateOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
 	var orderItem Models.OrderItem
@@ -72,6 +97,7 @@ func UpdateOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 		ProductID   string  `json:"productid" validate:"required"`
 		Quantity    int     `json:"quantity" validate:"required,min=1"`
 		ConversRate float64 `json:"conversrate" validate:"required,min=0"`
+		OrderLinePricing
 	}
 
 	var req OrderItemRequest
@@ -79,16 +105,33 @@ func UpdateOrderItem(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
 	}
 
+	// ไม่ระบุราคาใหม่และสินค้าเดิม = ใช้ราคาเดิม
+	if req.UnitPrice == nil && req.ProductID == orderItem.ProductID && req.ConversRate == orderItem.ConversRate {
+		unitPrice := orderItem.UnitPrice
+		req.UnitPrice = &unitPrice
+	}
+	if req.Currency == "" {
+		req.Currency = orderItem.Currency
+	}
+
 	orderItem.ProductID = req.ProductID
 	orderItem.Quantity = req.Quantity
 	orderItem.ConversRate = req.ConversRate
 
-	if err := db.Save(&orderItem).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order item: " + err.Error()})
-	}
-
-	if err := UpdateTotalAmount(db, orderItem.OrderID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update total amount: " + err.Error()})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := editableOrder(tx, orderItem.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := applyOrderLinePricing(tx, order.SupplierID.String(), &orderItem, req.OrderLinePricing); err != nil {
+			return err
+		}
+		if err := tx.Save(&orderItem).Error; err != nil {
+			return err
+		}
+		return UpdateTotalAmount(tx, orderItem.OrderID)
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to update order item: " + err.Error()})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Order item updated successfully", "data": orderItem})
@@ -123,14 +166,14 @@ func OrderItemRoutes(app *fiber.App, db *gorm.DB) {
 	})
 
 	app.Post("/OrderItems", func(c *fiber.Ctx) error {
-		return AddOrderItem(db, c)
+		return AddOrderItem(auditDB(db, c), c)
 	})
 
 	app.Put("/OrderItems/:id", func(c *fiber.Ctx) error {
-		return UpdateOrderItem(db, c)
+		return UpdateOrderItem(auditDB(db, c), c)
 	})
 
 	app.Delete("/OrderItems/:id", func(c *fiber.Ctx) error {
-		return DeleteOrderItem(db, c)
+		return DeleteOrderItem(auditDB(db, c), c)
 	})
 }
@@ -0,0 +1,87 @@
+package Func
+
+import (
+	"Api/Models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// สกุลเงินเริ่มต้นของรายการสั่งซื้อ
+const defaultCurrency = "THB"
+
+var hundred = decimal.NewFromInt(100)
+
+// OrderLinePricing ราคาของรายการสั่งซื้อที่ผู้ใช้ระบุ (ไม่ระบุ unitprice = ใช้ราคาจาก Supplier)
+type OrderLinePricing struct {
+	UnitPrice *decimal.Decimal `json:"unitprice"`
+	Currency  string           `json:"currency"`
+	Discount  decimal.Decimal  `json:"discount"`
+	TaxRate   decimal.Decimal  `json:"taxrate"`
+}
+
+// ราคาต่อหน่วยสั่งซื้อเริ่มต้นจากแคตตาล็อกของ Supplier (แปลงจากหน่วยที่ซื้อตาม ConversRate)
+// ถ้า Supplier ไม่ได้ขายสินค้านี้หรือไม่มีราคา ใช้ราคาทุนปัจจุบันของสินค้า
+func defaultOrderUnitPrice(tx *gorm.DB, supplierID, productID string, conversRate float64) decimal.Decimal {
+	var supplierProduct Models.SupplierProduct
+	if err := tx.Where("supplier_id = ? AND product_id = ?", supplierID, productID).First(&supplierProduct).Error; err == nil &&
+		supplierProduct.PricePerUnit.IsPositive() && supplierProduct.ConversRate > 0 {
+		return supplierProduct.PricePerUnit.
+			Div(decimal.NewFromInt(int64(supplierProduct.ConversRate))).
+			Mul(decimal.NewFromFloat(conversRate)).
+			Round(4)
+	}
+	return decimal.NewFromFloat(currentUnitCost(tx, productID, "")).Mul(decimal.NewFromFloat(conversRate)).Round(4)
+}
+
+// หน่วยและจำนวนหน่วยย่อยต่อหน่วยของสินค้าจาก ProductUnit (ไม่มี ProductUnit = ชิ้น, 1)
+func productUnitRate(tx *gorm.DB, productID string) (string, int) {
+	var productUnit Models.ProductUnit
+	if err := tx.Where("product_id = ?", productID).First(&productUnit).Error; err != nil || productUnit.ConversRate <= 0 {
+		return "Pieces", 1
+	}
+	return productUnit.Type, productUnit.ConversRate
+}
+
+// ใส่ราคาให้รายการสั่งซื้อ ตรวจค่าที่ระบุ และคำนวณยอดของรายการ
+func applyOrderLinePricing(tx *gorm.DB, supplierID string, item *Models.OrderItem, pricing OrderLinePricing) error {
+	if pricing.UnitPrice != nil {
+		item.UnitPrice = *pricing.UnitPrice
+	} else {
+		item.UnitPrice = defaultOrderUnitPrice(tx, supplierID, item.ProductID, item.ConversRate)
+	}
+	item.Currency = pricing.Currency
+	if item.Currency == "" {
+		item.Currency = defaultCurrency
+	}
+	item.Discount = pricing.Discount
+	item.TaxRate = pricing.TaxRate
+
+	if item.UnitPrice.IsNegative() || item.Discount.IsNegative() {
+		return fiber.NewError(fiber.StatusBadRequest, "unit price and discount must be greater or equal to 0")
+	}
+	if item.TaxRate.IsNegative() || item.TaxRate.GreaterThan(hundred) {
+		return fiber.NewError(fiber.StatusBadRequest, "tax rate must be between 0 and 100")
+	}
+	computeOrderLine(item)
+	if item.LineTotal.IsNegative() {
+		return fiber.NewError(fiber.StatusBadRequest, "discount cannot exceed the line amount for product: "+item.ProductID)
+	}
+	return nil
+}
+
+// ยอดของรายการ = ราคาต่อหน่วยสั่งซื้อ × จำนวนหน่วยสั่งซื้อ - ส่วนลด (ปัดเศษ 2 ตำแหน่ง)
+// Quantity เก็บเป็นหน่วยย่อย จึงหารด้วย ConversRate ก่อน
+func computeOrderLine(item *Models.OrderItem) {
+	units := decimal.NewFromInt(int64(item.Quantity))
+	if item.ConversRate > 0 {
+		units = units.Div(decimal.NewFromFloat(item.ConversRate))
+	}
+	item.LineTotal = item.UnitPrice.Mul(units).Sub(item.Discount).Round(2)
+}
+
+// ภาษีของรายการ (ปัดเศษ 2 ตำแหน่งต่อรายการ)
+func orderLineTax(item Models.OrderItem) decimal.Decimal {
+	return item.LineTotal.Mul(item.TaxRate).Div(hundred).Round(2)
+}
@@ -0,0 +1,474 @@
+package Func
+
+import (
+	"Api/Models"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// แปลงวันที่จาก string (รองรับทั้ง 2006-01-02 และ RFC3339)
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// ดึง username ของผู้ใช้งานจาก Context (ถ้ามี)
+func currentUsername(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+// ดึง employees_id ของผู้ใช้งานจาก Context (ถ้ามี)
+func currentEmployeeID(c *fiber.Ctx) *string {
+	if employeeID, ok := c.Locals("employees_id").(string); ok && employeeID != "" {
+		return &employeeID
+	}
+	return nil
+}
+
+// แปลง string ว่างให้เป็น nil
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// กำหนดเงื่อนไขขอบเขตราคา (สาขา / รายการราคา) ให้ตรงกันแบบเป๊ะ
+func priceScope(query *gorm.DB, branchID, priceListID *string) *gorm.DB {
+	if branchID == nil {
+		query = query.Where("branch_id IS NULL")
+	} else {
+		query = query.Where("branch_id = ?", *branchID)
+	}
+	if priceListID == nil {
+		query = query.Where("price_list_id IS NULL")
+	} else {
+		query = query.Where("price_list_id = ?", *priceListID)
+	}
+	return query
+}
+
+// หาราคาที่มีผล ณ เวลาที่กำหนด โดยเลือกราคาที่เจาะจงที่สุดก่อน
+// (สาขา+รายการราคา → สาขา → รายการราคา → ราคากลาง)
+func CurrentProductPrice(db *gorm.DB, productID, branchID, priceListID string, at time.Time) (*Models.ProductPrice, error) {
+	query := db.Where("product_id = ?", productID).
+		Where("effective_from <= ?", at).
+		Where("effective_to IS NULL OR effective_to > ?", at)
+
+	if branchID == "" {
+		query = query.Where("branch_id IS NULL")
+	} else {
+		query = query.Where("branch_id = ? OR branch_id IS NULL", branchID)
+	}
+	if priceListID == "" {
+		query = query.Where("price_list_id IS NULL")
+	} else {
+		query = query.Where("price_list_id = ? OR price_list_id IS NULL", priceListID)
+	}
+
+	var price Models.ProductPrice
+	err := query.
+		Order("branch_id IS NULL, price_list_id IS NULL, effective_from DESC").
+		First(&price).Error
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// บันทึกราคาใหม่ ปิดช่วงราคาเดิม และเก็บประวัติการเปลี่ยนราคา
+func SetProductPrice(tx *gorm.DB, price Models.ProductPrice, changedBy string) error {
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = time.Now()
+	}
+
+	history := Models.PriceHistory{
+		ProductID:       price.ProductID,
+		BranchID:        price.BranchID,
+		PriceListID:     price.PriceListID,
+		NewCostPrice:    price.CostPrice,
+		NewSellingPrice: price.SellingPrice,
+		EffectiveFrom:   price.EffectiveFrom,
+		ChangedBy:       changedBy,
+		ChangedAt:       time.Now(),
+	}
+
+	// ราคาเดิม = ราคาที่มีผลอยู่ ณ เวลาที่ราคาใหม่เริ่มมีผล (ไม่นับราคาที่ตั้งล่วงหน้าไว้หลังจากนั้น)
+	var previous Models.ProductPrice
+	err := priceScope(tx.Where("product_id = ?", price.ProductID), price.BranchID, price.PriceListID).
+		Where("effective_from <= ?", price.EffectiveFrom).
+		Where("effective_to IS NULL OR effective_to > ?", price.EffectiveFrom).
+		Order("effective_from DESC").
+		First(&previous).Error
+	if err == nil {
+		history.OldCostPrice = previous.CostPrice
+		history.OldSellingPrice = previous.SellingPrice
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// ปิดช่วงราคาเดิมที่ยังมีผลอยู่ในขอบเขตเดียวกัน
+	if err := priceScope(tx.Model(&Models.ProductPrice{}).Where("product_id = ?", price.ProductID), price.BranchID, price.PriceListID).
+		Where("effective_from < ?", price.EffectiveFrom).
+		Where("effective_to IS NULL OR effective_to > ?", price.EffectiveFrom).
+		Update("effective_to", price.EffectiveFrom).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&price).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+
+	// ซิงค์ Inventory.Price ตามราคาที่มีผลตอนนี้ (ราคาที่มีผลในอนาคตจะถูกซิงค์โดย ApplyEffectivePrices)
+	if price.PriceListID == nil {
+		return syncInventoryPrices(tx, price.ProductID, time.Now())
+	}
+	return nil
+}
+
+// ตั้ง Inventory.Price ของทุกสาขาเป็นราคาขายที่มีผล ณ เวลาที่กำหนด
+// (ราคาเฉพาะสาขามาก่อนราคากลาง ราคากลางจึงไม่เขียนทับสาขาที่มีราคาของตัวเอง)
+func syncInventoryPrices(tx *gorm.DB, productID string, at time.Time) error {
+	var inventories []Models.Inventory
+	if err := tx.Where("product_id = ?", productID).Find(&inventories).Error; err != nil {
+		return err
+	}
+
+	for _, inventory := range inventories {
+		price, err := CurrentProductPrice(tx, productID, inventory.BranchID, "", at)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		sellingPrice := price.SellingPrice.InexactFloat64()
+		if inventory.Price == sellingPrice {
+			continue
+		}
+		if err := tx.Model(&Models.Inventory{}).Where("inventory_id = ?", inventory.InventoryID).
+			Updates(map[string]interface{}{"price": sellingPrice, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// เวลาที่ซิงค์ราคาที่ถึงกำหนดครั้งล่าสุด (ค่าเริ่มต้นศูนย์ = รอบแรกหลังเปิดระบบซิงค์ทุกสินค้าที่มีราคา)
+var lastPriceSync time.Time
+
+// ซิงค์ Inventory.Price ของสินค้าที่มีราคาเริ่มมีผลหรือหมดผลตั้งแต่รอบก่อน
+func ApplyEffectivePrices(db *gorm.DB) error {
+	now := time.Now()
+	var productIDs []string
+	if err := db.Model(&Models.ProductPrice{}).
+		Where("price_list_id IS NULL").
+		Where("(effective_from > ? AND effective_from <= ?) OR (effective_to > ? AND effective_to <= ?)", lastPriceSync, now, lastPriceSync, now).
+		Distinct().
+		Pluck("product_id", &productIDs).Error; err != nil {
+		return err
+	}
+
+	for _, productID := range productIDs {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return syncInventoryPrices(tx, productID, now)
+		}); err != nil {
+			return err
+		}
+	}
+	lastPriceSync = now
+	return nil
+}
+
+// เพิ่มรายการราคา
+func AddPriceList(db *gorm.DB, c *fiber.Ctx) error {
+	type PriceListRequest struct {
+		Name      string `json:"name" validate:"required"`
+		Currency  string `json:"currency"`
+		IsDefault bool   `json:"is_default"`
+	}
+
+	var req PriceListRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Price list name is required"})
+	}
+	if req.Currency == "" {
+		req.Currency = "THB"
+	}
+
+	priceList := Models.PriceList{
+		Name:      req.Name,
+		Currency:  req.Currency,
+		IsDefault: req.IsDefault,
+	}
+
+	if err := db.Create(&priceList).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create price list: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Price list created successfully", "data": priceList})
+}
+
+// ดูรายการราคาทั้งหมด
+func LookPriceLists(db *gorm.DB, c *fiber.Ctx) error {
+	var priceLists []Models.PriceList
+	if err := db.Find(&priceLists).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch price lists: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": priceLists})
+}
+
+// กำหนดราคาสินค้า (ราคาทุน/ราคาขาย) ตามสาขาหรือรายการราคา
+func AddProductPrice(db *gorm.DB, c *fiber.Ctx) error {
+	productID := c.Params("id")
+
+	type ProductPriceRequest struct {
+		BranchID      string          `json:"branch_id"`
+		PriceListID   string          `json:"price_list_id"`
+		CostPrice     decimal.Decimal `json:"cost_price"`
+		SellingPrice  decimal.Decimal `json:"selling_price" validate:"required,min=0"`
+		EffectiveFrom string          `json:"effective_from"`
+		EffectiveTo   string          `json:"effective_to"`
+	}
+
+	var req ProductPriceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.CostPrice.IsNegative() || !req.SellingPrice.IsPositive() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Selling price must be greater than 0 and cost price cannot be negative"})
+	}
+
+	var product Models.Product
+	if err := db.Where("product_id = ?", productID).First(&product).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	price := Models.ProductPrice{
+		ProductID:    product.ProductID,
+		BranchID:     optionalString(req.BranchID),
+		PriceListID:  optionalString(req.PriceListID),
+		CostPrice:    req.CostPrice,
+		SellingPrice: req.SellingPrice,
+	}
+
+	if req.EffectiveFrom != "" {
+		effectiveFrom, err := parseDate(req.EffectiveFrom)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid effective_from format"})
+		}
+		price.EffectiveFrom = effectiveFrom
+	}
+	if req.EffectiveTo != "" {
+		effectiveTo, err := parseDate(req.EffectiveTo)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid effective_to format"})
+		}
+		if !price.EffectiveFrom.IsZero() && !effectiveTo.After(price.EffectiveFrom) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "effective_to must be after effective_from"})
+		}
+		price.EffectiveTo = &effectiveTo
+	}
+
+	if price.PriceListID != nil {
+		var priceList Models.PriceList
+		if err := db.Where("price_list_id = ?", *price.PriceListID).First(&priceList).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Price list not found"})
+		}
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return SetProductPrice(tx, price, currentUsername(c))
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to set product price: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Product price set successfully"})
+}
+
+// ดูราคาปัจจุบันและช่วงราคาทั้งหมดของสินค้า
+func GetProductPrices(db *gorm.DB, c *fiber.Ctx) error {
+	productID := c.Params("id")
+
+	at := time.Now()
+	if c.Query("at") != "" {
+		parsed, err := parseDate(c.Query("at"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid at format"})
+		}
+		at = parsed
+	}
+
+	var prices []Models.ProductPrice
+	if err := db.Where("product_id = ?", productID).Order("effective_from DESC").Find(&prices).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product prices: " + err.Error()})
+	}
+
+	current, err := CurrentProductPrice(db, productID, c.Query("branch_id"), c.Query("price_list_id"), at)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resolve current price: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"current": current, "prices": prices})
+}
+
+// ดูประวัติการเปลี่ยนราคาของสินค้า
+func GetPriceHistory(db *gorm.DB, c *fiber.Ctx) error {
+	productID := c.Params("id")
+
+	var history []Models.PriceHistory
+	if err := db.Where("product_id = ?", productID).Order("changed_at DESC").Find(&history).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch price history: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": history})
+}
+
+// จับคู่ Product ของ Warehouse กับ Products ของ POS
+func AddProductPosMapping(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	type MappingRequest struct {
+		ProductID    string `json:"product_id" validate:"required"`
+		PosProductID string `json:"pos_product_id" validate:"required"`
+	}
+
+	var req MappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if _, err := uuid.Parse(req.ProductID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product UUID format"})
+	}
+	if _, err := uuid.Parse(req.PosProductID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid POS product UUID format"})
+	}
+
+	var product Models.Product
+	if err := db.Where("product_id = ?", req.ProductID).First(&product).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	var posProduct ProductsPos
+	if err := posDB.Table("Products").Where("product_id = ?", req.PosProductID).First(&posProduct).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "POS product not found"})
+	}
+
+	mapping := Models.ProductPosMapping{
+		ProductID:    req.ProductID,
+		PosProductID: req.PosProductID,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := db.Save(&mapping).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save product mapping: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Product mapping saved successfully", "data": mapping})
+}
+
+// ดูการจับคู่สินค้ากับ POS ทั้งหมด
+func LookProductPosMappings(db *gorm.DB, c *fiber.Ctx) error {
+	var mappings []Models.ProductPosMapping
+	if err := db.Find(&mappings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product mappings: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": mappings})
+}
+
+// ส่งราคาขายปัจจุบันของสินค้าที่จับคู่แล้วไปยังคอลัมน์ Products.price ของ POS
+func PushPricesToPOS(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	type PushRequest struct {
+		BranchID    string `json:"branch_id"`
+		PriceListID string `json:"price_list_id"`
+	}
+
+	var req PushRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+		}
+	}
+
+	var mappings []Models.ProductPosMapping
+	if err := db.Find(&mappings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch product mappings: " + err.Error()})
+	}
+
+	now := time.Now()
+	updated := []fiber.Map{}
+	skipped := []string{}
+
+	for _, mapping := range mappings {
+		price, err := CurrentProductPrice(db, mapping.ProductID, req.BranchID, req.PriceListID, now)
+		if err != nil {
+			skipped = append(skipped, mapping.ProductID)
+			continue
+		}
+
+		if err := posDB.Table("Products").
+			Where("product_id = ?", mapping.PosProductID).
+			Updates(map[string]interface{}{"price": price.SellingPrice, "updated_at": now}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update POS product price: " + err.Error()})
+		}
+
+		updated = append(updated, fiber.Map{
+			"product_id":     mapping.ProductID,
+			"pos_product_id": mapping.PosProductID,
+			"price":          price.SellingPrice,
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Prices pushed to POS successfully", "updated": updated, "skipped": skipped})
+}
+
+func PricingRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
+	app.Get("/PriceLists", func(c *fiber.Ctx) error {
+		return LookPriceLists(db, c)
+	})
+
+	app.Post("/PriceLists", func(c *fiber.Ctx) error {
+		return AddPriceList(auditDB(db, c), c)
+	})
+
+	app.Get("/Product/:id/prices", func(c *fiber.Ctx) error {
+		return GetProductPrices(db, c)
+	})
+
+	app.Post("/Product/:id/prices", func(c *fiber.Ctx) error {
+		return AddProductPrice(auditDB(db, c), c)
+	})
+
+	app.Get("/Product/:id/price-history", func(c *fiber.Ctx) error {
+		return GetPriceHistory(db, c)
+	})
+
+	app.Get("/ProductPosMapping", func(c *fiber.Ctx) error {
+		return LookProductPosMappings(db, c)
+	})
+
+	app.Post("/ProductPosMapping", func(c *fiber.Ctx) error {
+		return AddProductPosMapping(auditDB(db, c), posDB, c)
+	})
+
+	app.Post("/Pricing/PushToPOS", func(c *fiber.Ctx) error {
+		return PushPricesToPOS(auditDB(db, c), posDB, c)
+	})
+}
@@ -1,13 +1,20 @@
 package Func
 
 import (
+	"Api/Authentication"
 	"Api/Models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -27,16 +34,21 @@ type ProductsPos struct {
 func AddProductWithInventory(db *gorm.DB, c *fiber.Ctx) error {
 	type ProductRequest struct {
 		ProductName     string
+		SKU             string
+		Serialized      bool
 		Description     string
 		Type            string
 		BranchID        string
 		InitialQuantity int
 		Price           float64
+		CostPrice       float64
 		Image           []byte
 	}
 
 	req := ProductRequest{}
 	req.ProductName = c.FormValue("product_name")
+	req.SKU = c.FormValue("sku")
+	req.Serialized = c.FormValue("serialized") == "true"
 	req.Description = c.FormValue("description")
 	req.Type = c.FormValue("type")
 	req.BranchID = c.FormValue("branch_id")
@@ -55,6 +67,15 @@ func AddProductWithInventory(db *gorm.DB, c *fiber.Ctx) error {
 	}
 	req.Price = price
 
+	// แปลง cost_price (ไม่บังคับ)
+	if costPrice := c.FormValue("cost_price"); costPrice != "" {
+		parsedCost, err := strconv.ParseFloat(costPrice, 64)
+		if err != nil || parsedCost < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cost_price"})
+		}
+		req.CostPrice = parsedCost
+	}
+
 	file, err := c.FormFile("image")
 	if err == nil && file != nil {
 		fileContent, err := file.Open()
@@ -72,6 +93,8 @@ func AddProductWithInventory(db *gorm.DB, c *fiber.Ctx) error {
 	// 1. สร้าง Product
 	product := Models.Product{
 		ProductName: req.ProductName,
+		SKU:         req.SKU,
+		Serialized:  req.Serialized,
 		Description: req.Description,
 		Image:       req.Image,
 		CreatedAt:   time.Now(),
@@ -122,10 +145,26 @@ func AddProductWithInventory(db *gorm.DB, c *fiber.Ctx) error {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.Create(&inventory).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inventory).Error; err != nil {
+			return err
+		}
+		return recordMovement(tx, inventory, inventory.Quantity, "Opening", "Product", product.ProductID, "", currentUsername(c))
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create inventory"})
 	}
 
+	// 5. บันทึกราคาเริ่มต้นของสินค้าในระบบราคา
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return SetProductPrice(tx, Models.ProductPrice{
+			ProductID:    product.ProductID,
+			CostPrice:    decimal.NewFromFloat(req.CostPrice),
+			SellingPrice: decimal.NewFromFloat(req.Price),
+		}, currentUsername(c))
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create product price"})
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":     "Product, Product Unit, and Inventory created successfully",
 		"product":     product,
@@ -146,12 +185,13 @@ func GetProductsBySupplier(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier UUID format"})
 	}
 
-	// ✅ ใช้ `JOIN` กับ `"ProductSupplier"` และ `"Product"`
+	// ✅ อ่านจากแคตตาล็อก `"SupplierProduct"`
 	var products []Models.Product
 	err = db.Table(`"Product"`). // 🔹 ใช้ `""` ครอบ "Product"
 					Select(`"Product".*`).
-					Joins(`JOIN "ProductSupplier" ON "ProductSupplier".product_id = "Product".product_id`).
-					Where(`"ProductSupplier".supplier_id = ?`, parsedUUID).
+					Joins(`JOIN "SupplierProduct" ON "SupplierProduct".product_id = "Product".product_id`).
+					Where(`"SupplierProduct".supplier_id = ?`, parsedUUID).
+					Where(`"Product".deleted_at IS NULL`).
 					Find(&products).Error
 
 	if err != nil {
@@ -172,11 +212,21 @@ func UpdateProduct(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if product.Version != version {
+		return preconditionFailed(c, "product", product, product.Version)
+	}
+
 	// Extract form data
 	productName := c.FormValue("product_name")
+	sku := c.FormValue("sku")
 	description := c.FormValue("description")
 	productType := c.FormValue("type")
-	price, _ := strconv.ParseFloat(c.FormValue("price"), 64)       // Convert price
+	price, _ := strconv.ParseFloat(c.FormValue("price"), 64) // Convert price
+	costPrice, _ := strconv.ParseFloat(c.FormValue("cost_price"), 64)
 	initialQty, _ := strconv.Atoi(c.FormValue("initial_quantity")) // Convert initial quantity
 
 	// Optionally, handle file upload (if any)
@@ -198,6 +248,12 @@ func UpdateProduct(db *gorm.DB, c *fiber.Ctx) error {
 	if productName != "" {
 		product.ProductName = productName
 	}
+	if sku != "" {
+		product.SKU = sku
+	}
+	if serialized := c.FormValue("serialized"); serialized != "" {
+		product.Serialized = serialized == "true"
+	}
 	if description != "" {
 		product.Description = description
 	}
@@ -205,54 +261,257 @@ func UpdateProduct(db *gorm.DB, c *fiber.Ctx) error {
 		product.Image = image
 	}
 
-	// Save updated product
-	if err := db.Save(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product"})
-	}
+	// บันทึก Product, ProductUnit และราคาใน transaction เดียว (ล้มเหลวส่วนไหน = ไม่เปลี่ยนอะไรเลย รวมถึง version)
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// Save updated product (เฉพาะเมื่อ version ยังไม่ถูกแก้ไขโดยคนอื่น)
+		if err := updateVersioned(tx, &Models.Product{}, "product_id", id, version, map[string]interface{}{
+			"product_name": product.ProductName,
+			"sku":          product.SKU,
+			"serialized":   product.Serialized,
+			"description":  product.Description,
+			"image":        product.Image,
+		}); err != nil {
+			return err
+		}
 
-	// Update associated ProductUnit and Inventory
-	var productUnit Models.ProductUnit
-	if err := db.Where("product_id = ?", id).First(&productUnit).Error; err == nil {
-		if productType != "" {
-			productUnit.Type = productType
-			switch productType {
-			case "Pallet":
-				productUnit.ConversRate = 12
-			case "Box":
-				productUnit.ConversRate = 6
-			case "Pieces":
-				productUnit.ConversRate = 1
+		// Update associated ProductUnit and Inventory
+		var productUnit Models.ProductUnit
+		if err := tx.Where("product_id = ?", id).First(&productUnit).Error; err == nil {
+			if productType != "" {
+				productUnit.Type = productType
+				switch productType {
+				case "Pallet":
+					productUnit.ConversRate = 12
+				case "Box":
+					productUnit.ConversRate = 6
+				case "Pieces":
+					productUnit.ConversRate = 1
+				}
+			}
+			if initialQty > 0 {
+				productUnit.InitialQuantity = initialQty
+			}
+			if err := tx.Save(&productUnit).Error; err != nil {
+				return fmt.Errorf("failed to update product unit: %w", err)
 			}
 		}
-		if initialQty > 0 {
-			productUnit.InitialQuantity = initialQty
-		}
-		if err := db.Save(&productUnit).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product unit"})
-		}
-	}
 
-	// Optionally, update the inventory price
-	var inventory Models.Inventory
-	if err := db.Where("product_id = ?", id).First(&inventory).Error; err == nil {
+		// อัปเดตราคาผ่านระบบราคา (ปิดราคาเดิม เก็บประวัติ และซิงค์ราคาให้ทุกสาขา)
 		if price > 0 {
-			inventory.Price = price
-		}
-		if err := db.Save(&inventory).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update inventory"})
+			if c.FormValue("cost_price") == "" {
+				if current, err := CurrentProductPrice(tx, product.ProductID, "", "", time.Now()); err == nil {
+					costPrice = current.CostPrice.InexactFloat64()
+				}
+			}
+			if costPrice < 0 {
+				costPrice = 0
+			}
+			if err := SetProductPrice(tx, Models.ProductPrice{
+				ProductID:    product.ProductID,
+				CostPrice:    decimal.NewFromFloat(costPrice),
+				SellingPrice: decimal.NewFromFloat(price),
+			}, currentUsername(c)); err != nil {
+				return fmt.Errorf("failed to update product price: %w", err)
+			}
 		}
+		return nil
+	}); errors.Is(err, errStaleVersion) {
+		db.Where("product_id = ?", id).First(&product)
+		return preconditionFailed(c, "product", product, product.Version)
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update product: " + err.Error()})
 	}
+	product.Version = version + 1
 
+	setETag(c, product.Version)
 	return c.JSON(fiber.Map{"message": "Product updated successfully"})
 }
 
-// ดึงข้อมูล Product ทั้งหมด
+// cursor สำหรับแบ่งหน้า (ค่าของคอลัมน์ที่ใช้เรียง + product_id)
+type productCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeProductCursor(cursor productCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeProductCursor(value string) (productCursor, error) {
+	var cursor productCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(raw, &cursor)
+	return cursor, err
+}
+
+// สร้าง Index สำหรับค้นหาสินค้า (full-text และ trigram)
+func EnsureProductSearchIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_product_name_trgm ON "Product" USING gin (product_name gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_description_trgm ON "Product" USING gin (description gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_sku_trgm ON "Product" USING gin (sku gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_search_fts ON "Product" USING gin (` + productSearchVector + `)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_created_at ON "Product" (created_at, product_id)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const productSearchVector = `to_tsvector('simple', coalesce(product_name, '') || ' ' || coalesce(description, '') || ' ' || coalesce(sku, ''))`
+
+// ดึงข้อมูล Product พร้อมค้นหา กรอง เรียงลำดับ และแบ่งหน้าแบบ cursor
 func LookProducts(db *gorm.DB, c *fiber.Ctx) error {
+	sortColumns := map[string]string{
+		"name":       `"Product".product_name`,
+		"created_at": `"Product".created_at`,
+		"sku":        `"Product".sku`,
+	}
+
+	sortField := c.Query("sort", "created_at")
+	sortColumn, ok := sortColumns[sortField]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid sort field"})
+	}
+
+	direction := strings.ToLower(c.Query("order", "desc"))
+	if direction != "asc" && direction != "desc" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order, must be asc or desc"})
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "limit must be between 1 and 200"})
+	}
+
+	query := withArchived(db, c).Model(&Models.Product{})
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where(productSearchVector+` @@ plainto_tsquery('simple', ?) OR product_name ILIKE ? OR description ILIKE ? OR sku ILIKE ?`, q, like, like, like)
+	}
+
+	if category := c.Query("category"); category != "" {
+		query = query.Where(`LOWER("Product".description) = LOWER(?)`, category)
+	}
+
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		if _, err := uuid.Parse(supplierID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier UUID format"})
+		}
+		query = query.Where(`EXISTS (SELECT 1 FROM "SupplierProduct" sp WHERE sp.product_id = "Product".product_id AND sp.supplier_id = ?)`, supplierID)
+	}
+
+	branchID := c.Query("branch_id")
+	if branchID != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM "Inventory" i WHERE i.product_id::uuid = "Product".product_id AND i.branch_id = ?)`, branchID)
+	}
+
+	switch c.Query("stock") {
+	case "":
+	case "in":
+		if branchID != "" {
+			query = query.Where(`EXISTS (SELECT 1 FROM "Inventory" i WHERE i.product_id::uuid = "Product".product_id AND i.branch_id = ? AND i.quantity > 0)`, branchID)
+		} else {
+			query = query.Where(`EXISTS (SELECT 1 FROM "Inventory" i WHERE i.product_id::uuid = "Product".product_id AND i.quantity > 0)`)
+		}
+	case "out":
+		if branchID != "" {
+			query = query.Where(`NOT EXISTS (SELECT 1 FROM "Inventory" i WHERE i.product_id::uuid = "Product".product_id AND i.branch_id = ? AND i.quantity > 0)`, branchID)
+		} else {
+			query = query.Where(`NOT EXISTS (SELECT 1 FROM "Inventory" i WHERE i.product_id::uuid = "Product".product_id AND i.quantity > 0)`)
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid stock filter, must be in or out"})
+	}
+
+	if createdFrom := c.Query("created_from"); createdFrom != "" {
+		from, err := parseDate(createdFrom)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid created_from format"})
+		}
+		query = query.Where(`"Product".created_at >= ?`, from)
+	}
+	if createdTo := c.Query("created_to"); createdTo != "" {
+		to, err := parseDate(createdTo)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid created_to format"})
+		}
+		query = query.Where(`"Product".created_at <= ?`, to)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count products"})
+	}
+
+	comparator := "<"
+	if direction == "asc" {
+		comparator = ">"
+	}
+
+	if cursorValue := c.Query("cursor"); cursorValue != "" {
+		cursor, err := decodeProductCursor(cursorValue)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+		if sortField == "created_at" {
+			createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+			}
+			query = query.Where(`(`+sortColumn+`, "Product".product_id) `+comparator+` (?, ?)`, createdAt, cursor.ID)
+		} else {
+			query = query.Where(`(COALESCE(`+sortColumn+`, ''), "Product".product_id) `+comparator+` (?, ?)`, cursor.Value, cursor.ID)
+		}
+	}
+
+	orderColumn := sortColumn
+	if sortField != "created_at" {
+		orderColumn = `COALESCE(` + sortColumn + `, '')`
+	}
+
 	var products []Models.Product
-	if err := db.Preload("ProductUnit").Preload("Inventory").Find(&products).Error; err != nil {
+	if err := query.
+		Preload("ProductUnit").
+		Preload("Inventory", func(tx *gorm.DB) *gorm.DB {
+			if branchID != "" {
+				return tx.Where("branch_id = ?", branchID)
+			}
+			return tx
+		}).
+		Order(orderColumn + " " + direction).
+		Order(`"Product".product_id ` + direction).
+		Limit(limit + 1).
+		Find(&products).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch products"})
 	}
-	return c.JSON(fiber.Map{"products": products})
+
+	var nextCursor string
+	if len(products) > limit {
+		products = products[:limit]
+		last := products[len(products)-1]
+		cursor := productCursor{ID: last.ProductID}
+		switch sortField {
+		case "name":
+			cursor.Value = last.ProductName
+		case "sku":
+			cursor.Value = last.SKU
+		default:
+			cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		nextCursor = encodeProductCursor(cursor)
+	}
+
+	return c.JSON(fiber.Map{"products": products, "total": total, "next_cursor": nextCursor})
 }
 
 func LookProductsPos(posDB *gorm.DB, c *fiber.Ctx) error {
@@ -272,43 +531,73 @@ func LookProductUnit(db *gorm.DB, c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"products": products})
 }
 
-// ลบ Product
+// ลบ Product (เก็บถาวร หรือ ลบถาวรเมื่อส่ง hard=true และไม่มีข้อมูลอ้างอิง)
 func DeleteProduct(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
+	hard := c.QueryBool("hard")
+
+	query := db
+	if hard {
+		query = db.Unscoped()
+	}
+
 	var product Models.Product
-	if err := db.Where("product_id = ?", id).First(&product).Error; err != nil {
+	if err := query.Where("product_id = ?", id).First(&product).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 	}
 
-	if err := db.Delete(&product).Error; err != nil {
+	if hard {
+		return hardDeleteRecord(db, c, &product, func(tx *gorm.DB) (map[string]int64, error) {
+			return productDependencies(tx, product.ProductID)
+		})
+	}
+
+	if err := archiveRecord(db, c, &product); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete product"})
 	}
 
 	return c.JSON(fiber.Map{"message": "Product deleted successfully"})
 }
 
+// กู้คืน Product ที่ถูกเก็บถาวร
+func RestoreProduct(db *gorm.DB, c *fiber.Ctx) error {
+	restored, err := restoreRecord(db, &Models.Product{}, "product_id", c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore product: " + err.Error()})
+	}
+	if !restored {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Archived product not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Product restored successfully"})
+}
+
 func GetProductByID(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var product Models.Product
-	err := db.Preload("ProductUnit").Preload("Inventory").
+	err := withArchived(db, c).Preload("ProductUnit").Preload("Inventory").
 		Where("product_id = ?", id).First(&product).Error
 
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
 	}
 
+	setETag(c, product.Version)
 	return c.JSON(fiber.Map{"product": product})
 }
 
 func ProductRouter(app fiber.Router, db *gorm.DB, posDB *gorm.DB) {
 	app.Post("/Product", func(c *fiber.Ctx) error {
-		return AddProductWithInventory(db, c)
+		return AddProductWithInventory(auditDB(db, c), c)
 	})
 	app.Get("/Product", func(c *fiber.Ctx) error {
 		return LookProducts(db, c)
 	})
 
+	app.Get("/Product/:id", func(c *fiber.Ctx) error {
+		return GetProductByID(db, c)
+	})
+
 	app.Get("/ProductsBySupplier", func(c *fiber.Ctx) error {
 		return GetProductsBySupplier(db, c)
 	})
@@ -322,9 +611,13 @@ func ProductRouter(app fiber.Router, db *gorm.DB, posDB *gorm.DB) {
 	})
 
 	app.Put("/Product/:id", func(c *fiber.Ctx) error {
-		return UpdateProduct(db, c)
+		return UpdateProduct(auditDB(db, c), c)
+	})
+	app.Delete("/Product/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return DeleteProduct(auditDB(db, c), c)
 	})
-	app.Delete("/Product/:id", func(c *fiber.Ctx) error {
-		return DeleteProduct(db, c)
+
+	app.Put("/Product/:id/restore", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RestoreProduct(auditDB(db, c), c)
 	})
 }
@@ -0,0 +1,430 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// จุดสั่งซื้อเริ่มต้นเมื่อสินค้ายังไม่มีการตั้งค่า (ค่าเดิมของ GetPosLowStock)
+const defaultReorderPoint = 1000
+
+// สถานะเอกสารที่ยังเปิดอยู่ (ยังไม่เข้าสต็อก/ยังไม่ออกจากสต็อก)
+var (
+	openOrderStatuses    = []string{"Draft", "Submitted", "Approved", "PartiallyReceived"}
+	openShipmentStatuses = []string{"Pending"}
+)
+
+// ReorderGroup รายการแนะนำให้สั่งซื้อที่จัดกลุ่มตาม Supplier
+type ReorderGroup struct {
+	SupplierID   *string                    `json:"supplier_id"`
+	SupplierName string                     `json:"supplier_name"`
+	Suggestions  []Models.ReorderSuggestion `json:"suggestions"`
+}
+
+// Supplier ที่ใช้สั่งซื้อสินค้า (เลือก preferred ก่อน ถ้าไม่มีใช้รายแรกที่พบ)
+func preferredSupplier(db *gorm.DB, productID string) *string {
+	var supplierProduct Models.SupplierProduct
+	if err := db.Joins(`JOIN "Supplier" s ON s.supplier_id = "SupplierProduct".supplier_id AND s.deleted_at IS NULL`).
+		Where(`"SupplierProduct".product_id = ?`, productID).
+		Order(`"SupplierProduct".preferred DESC, "SupplierProduct".created_at`).
+		First(&supplierProduct).Error; err != nil {
+		return nil
+	}
+	return &supplierProduct.SupplierID
+}
+
+// คำนวณจำนวนที่ควรสั่งซื้อของสินค้าในสาขา
+// projected = คงเหลือ + รอรับจาก Order + รอรับจาก Shipment - รอส่งออกจาก Shipment
+func computeReorder(db *gorm.DB, setting Models.ReorderSetting) (Models.ReorderSuggestion, error) {
+	suggestion := Models.ReorderSuggestion{ProductID: setting.ProductID, BranchID: setting.BranchID}
+
+	if err := db.Model(&Models.Inventory{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("product_id = ? AND branch_id = ?", setting.ProductID, setting.BranchID).
+		Scan(&suggestion.OnHand).Error; err != nil {
+		return suggestion, err
+	}
+
+	// นับเฉพาะจำนวนที่ยังค้างรับของ Order ที่ส่งเข้าสาขานี้
+	if err := db.Table(`"OrderItem" oi`).
+		Select("COALESCE(SUM(oi.quantity - oi.received_qty - oi.damaged_qty), 0)").
+		Joins(`JOIN "Order" o ON o.order_id = oi.order_id`).
+		Where("oi.product_id = ? AND o.branch_id = ? AND o.status IN ?", setting.ProductID, setting.BranchID, openOrderStatuses).
+		Scan(&suggestion.OnOrder).Error; err != nil {
+		return suggestion, err
+	}
+
+	shipmentQuantity := func(branchColumn string) (int, error) {
+		var quantity int
+		err := db.Table(`"ShipmentItem" si`).
+			Select("COALESCE(SUM(si.quantity), 0)").
+			Joins(`JOIN "Shipment" s ON s.shipment_id = si.shipment_id`).
+			Joins(`JOIN "Inventory" i ON i.inventory_id = si.warehouse_inventory_id`).
+			Where("i.product_id = ? AND s."+branchColumn+" = ? AND s.status IN ?", setting.ProductID, setting.BranchID, openShipmentStatuses).
+			Scan(&quantity).Error
+		return quantity, err
+	}
+
+	var err error
+	if suggestion.InboundQty, err = shipmentQuantity("to_branch_id"); err != nil {
+		return suggestion, err
+	}
+	if suggestion.OutboundQty, err = shipmentQuantity("from_branch_id"); err != nil {
+		return suggestion, err
+	}
+
+	projected := suggestion.OnHand + suggestion.OnOrder + suggestion.InboundQty - suggestion.OutboundQty
+	if projected <= setting.ReorderPoint {
+		suggestion.SuggestedQty = setting.MaxQty - projected
+	}
+	return suggestion, nil
+}
+
+// คำนวณรายการแนะนำให้สั่งซื้อจากการตั้งค่าทั้งหมด (ใช้ทั้งใน scheduler และเรียกผ่าน API)
+// รายการ Open เดิมจะถูกอัปเดต หรือปิดเป็น Resolved เมื่อไม่ต้องสั่งแล้ว
+func GenerateReorderSuggestions(db *gorm.DB) error {
+	var settings []Models.ReorderSetting
+	if err := db.Find(&settings).Error; err != nil {
+		return err
+	}
+
+	for _, setting := range settings {
+		suggestion, err := computeReorder(db, setting)
+		if err != nil {
+			return fmt.Errorf("failed to compute reorder for product %s: %w", setting.ProductID, err)
+		}
+
+		var existing Models.ReorderSuggestion
+		found := db.Where("product_id = ? AND branch_id = ? AND status = ?", setting.ProductID, setting.BranchID, "Open").
+			First(&existing).Error == nil
+
+		now := time.Now()
+		if suggestion.SuggestedQty <= 0 {
+			if found {
+				existing.Status = "Resolved"
+				existing.UpdatedAt = now
+				if err := db.Save(&existing).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		suggestion.SupplierID = preferredSupplier(db, setting.ProductID)
+		suggestion.Status = "Open"
+		suggestion.UpdatedAt = now
+		if found {
+			suggestion.SuggestionID = existing.SuggestionID
+			suggestion.CreatedAt = existing.CreatedAt
+			if err := db.Save(&suggestion).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		suggestion.CreatedAt = now
+		if err := db.Create(&suggestion).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ตั้งค่า min/max/จุดสั่งซื้อ ของสินค้าในสาขา (ถ้ามีอยู่แล้วจะอัปเดต)
+func SaveReorderSetting(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		ProductID    string `json:"product_id"`
+		BranchID     string `json:"branch_id"`
+		MinQty       int    `json:"min_qty"`
+		MaxQty       int    `json:"max_qty"`
+		ReorderPoint int    `json:"reorder_point"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.ProductID == "" || req.BranchID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "product_id and branch_id are required"})
+	}
+	if req.MinQty < 0 || req.MaxQty <= 0 || req.MinQty > req.MaxQty {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "min_qty must be between 0 and max_qty, and max_qty must be greater than 0"})
+	}
+	if req.ReorderPoint < req.MinQty || req.ReorderPoint > req.MaxQty {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reorder_point must be between min_qty and max_qty"})
+	}
+
+	if err := db.Where("product_id = ?", req.ProductID).First(&Models.Product{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+
+	var setting Models.ReorderSetting
+	if err := db.Where("product_id = ? AND branch_id = ?", req.ProductID, req.BranchID).First(&setting).Error; err != nil {
+		setting = Models.ReorderSetting{ProductID: req.ProductID, BranchID: req.BranchID, CreatedAt: time.Now()}
+	}
+	setting.MinQty = req.MinQty
+	setting.MaxQty = req.MaxQty
+	setting.ReorderPoint = req.ReorderPoint
+	setting.UpdatedAt = time.Now()
+
+	if setting.ReorderSettingID == "" {
+		if err := db.Create(&setting).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save reorder setting: " + err.Error()})
+		}
+	} else if err := db.Save(&setting).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save reorder setting: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Reorder setting saved", "data": setting})
+}
+
+// ดูการตั้งค่าจุดสั่งซื้อ (กรองตามสาขา/สินค้าได้)
+func LookReorderSettings(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.ReorderSetting{})
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var settings []Models.ReorderSetting
+	if err := query.Find(&settings).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch reorder settings"})
+	}
+	return c.JSON(fiber.Map{"data": settings})
+}
+
+// กำหนด Supplier หลักของสินค้า (ใช้จัดกลุ่มรายการแนะนำให้สั่งซื้อ)
+func SetPreferredSupplier(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		ProductID  string `json:"product_id"`
+		SupplierID string `json:"supplier_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if _, err := uuid.Parse(req.ProductID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product_id"})
+	}
+	if _, err := uuid.Parse(req.SupplierID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid supplier_id"})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Models.SupplierProduct{}).
+			Where("product_id = ? AND supplier_id = ?", req.ProductID, req.SupplierID).
+			Update("preferred", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusNotFound, "supplier does not supply this product")
+		}
+		return tx.Model(&Models.SupplierProduct{}).
+			Where("product_id = ? AND supplier_id <> ?", req.ProductID, req.SupplierID).
+			Update("preferred", false).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to set preferred supplier: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Preferred supplier updated"})
+}
+
+// ดูรายการแนะนำให้สั่งซื้อที่ยังเปิดอยู่ จัดกลุ่มตาม Supplier
+func LookReorderSuggestions(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Where("status = ?", "Open")
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var suggestions []Models.ReorderSuggestion
+	if err := query.Order("supplier_id, created_at").Find(&suggestions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch reorder suggestions"})
+	}
+
+	groups := []*ReorderGroup{}
+	bySupplier := map[string]*ReorderGroup{}
+	for _, suggestion := range suggestions {
+		key := ""
+		if suggestion.SupplierID != nil {
+			key = *suggestion.SupplierID
+		}
+
+		group, ok := bySupplier[key]
+		if !ok {
+			group = &ReorderGroup{SupplierID: suggestion.SupplierID}
+			if suggestion.SupplierID != nil {
+				var supplier Models.Supplier
+				if err := db.Unscoped().Where("supplier_id = ?", key).First(&supplier).Error; err == nil {
+					group.SupplierName = supplier.Name
+				}
+			}
+			bySupplier[key] = group
+			groups = append(groups, group)
+		}
+		group.Suggestions = append(group.Suggestions, suggestion)
+	}
+
+	return c.JSON(fiber.Map{"data": groups})
+}
+
+// คำนวณรายการแนะนำให้สั่งซื้อทันที (ไม่ต้องรอ scheduler)
+func RunReorderSuggestions(db *gorm.DB, c *fiber.Ctx) error {
+	if err := GenerateReorderSuggestions(db); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate reorder suggestions: " + err.Error()})
+	}
+	return LookReorderSuggestions(db, c)
+}
+
+// แปลงรายการแนะนำให้สั่งซื้อของ Supplier เดียวกันเป็น Order สถานะ Draft
+func ConvertReorderSuggestions(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		SuggestionIDs []string `json:"suggestion_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.SuggestionIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "suggestion_ids is required"})
+	}
+
+	var order Models.Order
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var suggestions []Models.ReorderSuggestion
+		if err := tx.Where("suggestion_id IN ? AND status = ?", req.SuggestionIDs, "Open").Find(&suggestions).Error; err != nil {
+			return err
+		}
+		if len(suggestions) != len(req.SuggestionIDs) {
+			return fiber.NewError(fiber.StatusBadRequest, "some suggestions were not found or are no longer open")
+		}
+
+		supplierID := suggestions[0].SupplierID
+		branchID := suggestions[0].BranchID
+		for _, suggestion := range suggestions {
+			if suggestion.SupplierID == nil || supplierID == nil || *suggestion.SupplierID != *supplierID {
+				return fiber.NewError(fiber.StatusBadRequest, "all suggestions must have the same supplier")
+			}
+			if suggestion.BranchID != branchID {
+				return fiber.NewError(fiber.StatusBadRequest, "all suggestions must be for the same branch")
+			}
+		}
+
+		orderNumber, err := NextDocumentNumber(tx, "PurchaseOrder", branchID, time.Now())
+		if err != nil {
+			return err
+		}
+
+		order = Models.Order{
+			OrderID:      uuid.New().String(),
+			OrderNumber:  orderNumber,
+			Status:       "Draft",
+			SupplierID:   uuid.MustParse(*supplierID),
+			BranchID:     &branchID,
+			PromisedDate: defaultPromisedDate(tx, *supplierID, time.Now()),
+			EmployeesID:  parseUUIDPointer(currentEmployeeID(c)),
+			CreatedAt:    time.Now(),
+		}
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		// รวมจำนวนของสินค้าเดียวกันไว้ในรายการเดียว
+		quantities := map[string]int{}
+		var productOrder []string
+		for _, suggestion := range suggestions {
+			if _, ok := quantities[suggestion.ProductID]; !ok {
+				productOrder = append(productOrder, suggestion.ProductID)
+			}
+			quantities[suggestion.ProductID] += suggestion.SuggestedQty
+		}
+
+		for _, productID := range productOrder {
+			var productUnit Models.ProductUnit
+			if err := tx.Where("product_id = ?", productID).First(&productUnit).Error; err != nil {
+				return fiber.NewError(fiber.StatusNotFound, "ProductUnit not found for product: "+productID)
+			}
+
+			item := Models.OrderItem{
+				OrderItemID: uuid.New().String(),
+				OrderID:     order.OrderID,
+				ProductID:   productID,
+				Quantity:    quantities[productID],
+				ConversRate: float64(productUnit.ConversRate),
+				CreatedAt:   time.Now(),
+			}
+			if err := applyOrderLinePricing(tx, *supplierID, &item, OrderLinePricing{}); err != nil {
+				return err
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := UpdateTotalAmount(tx, order.OrderID); err != nil {
+			return err
+		}
+
+		return tx.Model(&Models.ReorderSuggestion{}).
+			Where("suggestion_id IN ?", req.SuggestionIDs).
+			Updates(map[string]interface{}{"status": "Converted", "order_id": order.OrderID, "updated_at": time.Now()}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to convert suggestions: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Draft order created", "order_id": order.OrderID, "order_number": order.OrderNumber})
+}
+
+// จุดสั่งซื้อของสินค้าใน POS แยกตามสาขา (key: pos product id + branch id)
+func posReorderPoints(db *gorm.DB) map[string]int {
+	var rows []struct {
+		PosProductID string
+		BranchID     string
+		ReorderPoint int
+	}
+	points := map[string]int{}
+	if err := db.Table(`"ReorderSetting" r`).
+		Select("m.pos_product_id, r.branch_id, r.reorder_point").
+		Joins(`JOIN "ProductPosMapping" m ON m.product_id = r.product_id`).
+		Scan(&rows).Error; err != nil {
+		log.Println("❌ Error fetching reorder settings:", err)
+		return points
+	}
+	for _, row := range rows {
+		points[row.PosProductID+"|"+row.BranchID] = row.ReorderPoint
+	}
+	return points
+}
+
+func ReorderRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Reorder/settings", func(c *fiber.Ctx) error {
+		return LookReorderSettings(db, c)
+	})
+
+	app.Put("/Reorder/settings", func(c *fiber.Ctx) error {
+		return SaveReorderSetting(auditDB(db, c), c)
+	})
+
+	app.Put("/ProductSupplier/preferred", func(c *fiber.Ctx) error {
+		return SetPreferredSupplier(auditDB(db, c), c)
+	})
+
+	app.Get("/Reorder/suggestions", func(c *fiber.Ctx) error {
+		return LookReorderSuggestions(db, c)
+	})
+
+	app.Post("/Reorder/suggestions/run", func(c *fiber.Ctx) error {
+		return RunReorderSuggestions(auditDB(db, c), c)
+	})
+
+	app.Post("/Reorder/suggestions/convert", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return ConvertReorderSuggestions(auditDB(db, c), c)
+	})
+}
@@ -0,0 +1,355 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// สต็อกคลังที่ยังส่งได้ (หักยอดที่ถูกจองไว้)
+type warehouseAvailability struct {
+	InventoryID string
+	BranchID    string
+	Available   int
+}
+
+// ดึงสต็อกคลังของสินค้าทุกสาขาที่ยังใช้งานอยู่ เรียงจากมากไปน้อย
+func warehouseAvailabilities(db *gorm.DB, productID string) ([]warehouseAvailability, error) {
+	var rows []warehouseAvailability
+	err := db.Raw(`
+		SELECT i.inventory_id, i.branch_id,
+			i.quantity - COALESCE((
+				SELECT SUM(r.quantity)
+				FROM "StockReservation" r
+				WHERE r.inventory_id = i.inventory_id AND r.status = 'Active'
+			), 0) AS available
+		FROM "Inventory" i
+		JOIN "Branches" b ON b.branch_id = i.branch_id::uuid AND b.deleted_at IS NULL
+		WHERE i.product_id = ?
+		ORDER BY available DESC
+	`, productID).Scan(&rows).Error
+	return rows, err
+}
+
+// สร้างข้อเสนอเติมสินค้าให้สาขา POS จากระดับเป้าหมาย (ใช้ทั้งใน scheduler และเรียกผ่าน API)
+// คงเหลือใน POS + ที่กำลังส่งไป <= MinQty จะเสนอให้เติมจนถึง TargetQty จากคลังที่มีสต็อกมากที่สุด
+func GenerateReplenishmentProposals(db *gorm.DB, posDB *gorm.DB) error {
+	var targets []Models.ReplenishmentTarget
+	if err := db.Find(&targets).Error; err != nil {
+		return err
+	}
+
+	// จำนวนที่เสนอไปแล้วในรอบนี้ เพื่อไม่ให้เสนอสต็อกก้อนเดียวกันซ้ำหลายสาขา
+	allocated := map[string]int{}
+
+	for _, target := range targets {
+		var existing Models.ReplenishmentProposal
+		found := db.Where("product_id = ? AND to_branch_id = ? AND status = ?", target.ProductID, target.BranchID, "Open").
+			First(&existing).Error == nil
+
+		proposal, err := computeReplenishment(db, posDB, target, allocated)
+		if err != nil {
+			return fmt.Errorf("failed to compute replenishment for product %s: %w", target.ProductID, err)
+		}
+
+		now := time.Now()
+		if proposal == nil {
+			if found {
+				existing.Status = "Resolved"
+				existing.UpdatedAt = now
+				if err := db.Save(&existing).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		allocated[proposal.WarehouseInventoryID] += proposal.ProposedQty
+		proposal.Status = "Open"
+		proposal.UpdatedAt = now
+		if found {
+			proposal.ProposalID = existing.ProposalID
+			proposal.CreatedAt = existing.CreatedAt
+			if err := db.Save(proposal).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		proposal.CreatedAt = now
+		if err := db.Create(proposal).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// คำนวณข้อเสนอเติมสินค้าของเป้าหมายหนึ่งรายการ คืน nil ถ้าไม่ต้องเติมหรือไม่มีสต็อกคลังให้ส่ง
+func computeReplenishment(db *gorm.DB, posDB *gorm.DB, target Models.ReplenishmentTarget, allocated map[string]int) (*Models.ReplenishmentProposal, error) {
+	// ไม่มี mapping หรือไม่มี Inventory ใน POS = ไม่ต้องเติม ส่วนข้อผิดพลาดอื่น (เช่น POS ล่ม) ต้องส่งต่อ
+	// เพื่อไม่ให้ข้อเสนอที่เปิดอยู่ถูกปิดเป็น Resolved
+	var mapping Models.ProductPosMapping
+	if err := db.Where("product_id = ?", target.ProductID).First(&mapping).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var posInventory Inventory
+	if err := posDB.Where("branch_id = ? AND product_id = ?", target.BranchID, mapping.PosProductID).
+		First(&posInventory).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var inbound int
+	if err := db.Table(`"ShipmentItem" si`).
+		Select("COALESCE(SUM(si.quantity), 0)").
+		Joins(`JOIN "Shipment" s ON s.shipment_id = si.shipment_id`).
+		Where("si.pos_inventory_id = ? AND s.status IN ?", posInventory.InventoryID.String(), []string{"Pending", "Approved"}).
+		Scan(&inbound).Error; err != nil {
+		return nil, err
+	}
+
+	projected := posInventory.Quantity + inbound
+	if projected > target.MinQty {
+		return nil, nil
+	}
+
+	availabilities, err := warehouseAvailabilities(db, target.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *warehouseAvailability
+	for i := range availabilities {
+		availabilities[i].Available -= allocated[availabilities[i].InventoryID]
+		if availabilities[i].Available > 0 && (best == nil || availabilities[i].Available > best.Available) {
+			best = &availabilities[i]
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	quantity := target.TargetQty - projected
+	if quantity > best.Available {
+		quantity = best.Available
+	}
+	if quantity <= 0 {
+		return nil, nil
+	}
+
+	return &Models.ReplenishmentProposal{
+		ProductID:            target.ProductID,
+		FromBranchID:         best.BranchID,
+		ToBranchID:           target.BranchID,
+		WarehouseInventoryID: best.InventoryID,
+		PosInventoryID:       posInventory.InventoryID.String(),
+		PosQuantity:          posInventory.Quantity,
+		InboundQty:           inbound,
+		TargetQty:            target.TargetQty,
+		ProposedQty:          quantity,
+	}, nil
+}
+
+// ตั้งค่าระดับสต็อกเป้าหมายของสินค้าในสาขา POS (ถ้ามีอยู่แล้วจะอัปเดต)
+func SaveReplenishmentTarget(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		BranchID  string `json:"branch_id"`
+		ProductID string `json:"product_id"`
+		MinQty    int    `json:"min_qty"`
+		TargetQty int    `json:"target_qty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.BranchID == "" || req.ProductID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id and product_id are required"})
+	}
+	if req.MinQty < 0 || req.TargetQty <= 0 || req.MinQty >= req.TargetQty {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "target_qty must be greater than min_qty and min_qty must be at least 0"})
+	}
+
+	if err := db.Where("product_id = ?", req.ProductID).First(&Models.ProductPosMapping{}).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Product is not mapped to a POS product"})
+	}
+
+	var target Models.ReplenishmentTarget
+	if err := db.Where("branch_id = ? AND product_id = ?", req.BranchID, req.ProductID).First(&target).Error; err != nil {
+		target = Models.ReplenishmentTarget{BranchID: req.BranchID, ProductID: req.ProductID, CreatedAt: time.Now()}
+	}
+	target.MinQty = req.MinQty
+	target.TargetQty = req.TargetQty
+	target.UpdatedAt = time.Now()
+
+	if target.TargetID == "" {
+		if err := db.Create(&target).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save replenishment target: " + err.Error()})
+		}
+	} else if err := db.Save(&target).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save replenishment target: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Replenishment target saved", "data": target})
+}
+
+// ดูระดับสต็อกเป้าหมาย (กรองตามสาขาได้)
+func LookReplenishmentTargets(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.ReplenishmentTarget{})
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var targets []Models.ReplenishmentTarget
+	if err := query.Find(&targets).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch replenishment targets"})
+	}
+	return c.JSON(fiber.Map{"data": targets})
+}
+
+// ดูข้อเสนอเติมสินค้าที่ยังเปิดอยู่
+func LookReplenishmentProposals(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Where("status = ?", "Open")
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("to_branch_id = ?", branchID)
+	}
+
+	var proposals []Models.ReplenishmentProposal
+	if err := query.Order("to_branch_id, created_at").Find(&proposals).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch replenishment proposals"})
+	}
+	return c.JSON(fiber.Map{"data": proposals})
+}
+
+// คำนวณข้อเสนอเติมสินค้าทันที (ไม่ต้องรอ scheduler)
+func RunReplenishmentProposals(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	if err := GenerateReplenishmentProposals(db, posDB); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate replenishment proposals: " + err.Error()})
+	}
+	return LookReplenishmentProposals(db, c)
+}
+
+// อนุมัติข้อเสนอหลายรายการพร้อมกัน สร้าง Shipment หนึ่งใบต่อคู่สาขาต้นทาง/ปลายทาง
+func ApproveReplenishmentProposals(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		ProposalIDs []string `json:"proposal_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.ProposalIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "proposal_ids is required"})
+	}
+
+	var shipmentIDs []string
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var proposals []Models.ReplenishmentProposal
+		if err := tx.Where("proposal_id IN ? AND status = ?", req.ProposalIDs, "Open").
+			Order("from_branch_id, to_branch_id").
+			Find(&proposals).Error; err != nil {
+			return err
+		}
+		if len(proposals) != len(req.ProposalIDs) {
+			return fiber.NewError(fiber.StatusBadRequest, "some proposals were not found or are no longer open")
+		}
+
+		type route struct{ from, to string }
+		var routes []route
+		grouped := map[route][]Models.ReplenishmentProposal{}
+		for _, proposal := range proposals {
+			key := route{proposal.FromBranchID, proposal.ToBranchID}
+			if _, ok := grouped[key]; !ok {
+				routes = append(routes, key)
+			}
+			grouped[key] = append(grouped[key], proposal)
+		}
+
+		for _, key := range routes {
+			var items []ShipmentItemInput
+			var ids []string
+			for _, proposal := range grouped[key] {
+				items = append(items, ShipmentItemInput{
+					WarehouseInventoryID: proposal.WarehouseInventoryID,
+					PosInventoryID:       proposal.PosInventoryID,
+					Quantity:             proposal.ProposedQty,
+				})
+				ids = append(ids, proposal.ProposalID)
+			}
+
+			shipment, err := createShipment(tx, posDB, key.from, key.to, items)
+			if err != nil {
+				return err
+			}
+			shipmentIDs = append(shipmentIDs, shipment.ShipmentID)
+
+			if err := tx.Model(&Models.ReplenishmentProposal{}).
+				Where("proposal_id IN ?", ids).
+				Updates(map[string]interface{}{"status": "Approved", "shipment_id": shipment.ShipmentID, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to approve proposals: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Shipments created successfully", "shipment_ids": shipmentIDs})
+}
+
+// ยกเลิกข้อเสนอเติมสินค้า
+func DismissReplenishmentProposals(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		ProposalIDs []string `json:"proposal_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.ProposalIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "proposal_ids is required"})
+	}
+
+	result := db.Model(&Models.ReplenishmentProposal{}).
+		Where("proposal_id IN ? AND status = ?", req.ProposalIDs, "Open").
+		Updates(map[string]interface{}{"status": "Dismissed", "updated_at": time.Now()})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to dismiss proposals"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Proposals dismissed", "dismissed": result.RowsAffected})
+}
+
+func ReplenishmentRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
+	app.Get("/Replenishment/targets", func(c *fiber.Ctx) error {
+		return LookReplenishmentTargets(db, c)
+	})
+
+	app.Put("/Replenishment/targets", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return SaveReplenishmentTarget(auditDB(db, c), c)
+	})
+
+	app.Get("/Replenishment/proposals", func(c *fiber.Ctx) error {
+		return LookReplenishmentProposals(db, c)
+	})
+
+	app.Post("/Replenishment/proposals/run", func(c *fiber.Ctx) error {
+		return RunReplenishmentProposals(auditDB(db, c), posDB, c)
+	})
+
+	app.Post("/Replenishment/proposals/approve", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return ApproveReplenishmentProposals(auditDB(db, c), posDB, c)
+	})
+
+	app.Post("/Replenishment/proposals/dismiss", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return DismissReplenishmentProposals(auditDB(db, c), c)
+	})
+}
@@ -0,0 +1,114 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InventoryAvailability ยอดคงเหลือ ยอดที่ถูกจอง และยอดที่ยังสัญญาได้ของ Inventory
+type InventoryAvailability struct {
+	Models.Inventory
+	OnHand    int `json:"on_hand"`
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+}
+
+// รวมจำนวนที่ถูกจองอยู่ของ Inventory แต่ละรายการ
+func reservedQuantities(db *gorm.DB, inventoryIDs []string) (map[string]int, error) {
+	var rows []struct {
+		InventoryID string
+		Reserved    int
+	}
+	reserved := map[string]int{}
+	if len(inventoryIDs) == 0 {
+		return reserved, nil
+	}
+	if err := db.Model(&Models.StockReservation{}).
+		Select("inventory_id, SUM(quantity) AS reserved").
+		Where("inventory_id IN ? AND status = ?", inventoryIDs, "Active").
+		Group("inventory_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		reserved[row.InventoryID] = row.Reserved
+	}
+	return reserved, nil
+}
+
+// แปลง Inventory เป็นข้อมูลพร้อมยอดจองและยอดที่ยังสัญญาได้
+func withAvailability(db *gorm.DB, inventories []Models.Inventory) ([]InventoryAvailability, error) {
+	ids := make([]string, 0, len(inventories))
+	for _, inventory := range inventories {
+		ids = append(ids, inventory.InventoryID)
+	}
+	reserved, err := reservedQuantities(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]InventoryAvailability, 0, len(inventories))
+	for _, inventory := range inventories {
+		result = append(result, InventoryAvailability{
+			Inventory: inventory,
+			OnHand:    inventory.Quantity,
+			Reserved:  reserved[inventory.InventoryID],
+			Available: inventory.Quantity - reserved[inventory.InventoryID],
+		})
+	}
+	return result, nil
+}
+
+// จองสต็อกให้ ShipmentItem (ล็อกแถว Inventory เพื่อไม่ให้จองเกินยอดที่มีพร้อมกันหลายรายการ)
+func reserveStock(tx *gorm.DB, item Models.ShipmentItem) error {
+	if item.Quantity <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "quantity must be greater than 0")
+	}
+
+	var inventory Models.Inventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ?", item.WarehouseInventoryID).
+		First(&inventory).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid WarehouseInventoryID")
+	}
+
+	reserved, err := reservedQuantities(tx, []string{inventory.InventoryID})
+	if err != nil {
+		return err
+	}
+
+	available := inventory.Quantity - reserved[inventory.InventoryID]
+	if item.Quantity > available {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("requested quantity %d exceeds available quantity %d for inventory %s", item.Quantity, available, inventory.InventoryID))
+	}
+
+	reservation := Models.StockReservation{
+		InventoryID:    inventory.InventoryID,
+		ShipmentID:     item.ShipmentID,
+		ShipmentListID: item.ShipmentListID,
+		Quantity:       item.Quantity,
+		Status:         "Active",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	return tx.Create(&reservation).Error
+}
+
+// ปิดการจองของ Shipment: Consumed เมื่ออนุมัติ (ตัดสต็อกแล้ว), Released เมื่อปฏิเสธ/ยกเลิก
+func settleReservations(tx *gorm.DB, shipmentID, status string) error {
+	return tx.Model(&Models.StockReservation{}).
+		Where("shipment_id = ? AND status = ?", shipmentID, "Active").
+		Updates(map[string]interface{}{"status": status, "updated_at": time.Now()}).Error
+}
+
+// ปล่อยยอดจองของ ShipmentItem รายการเดียว (ใช้ตอนแก้จำนวนหรือลบรายการของ Shipment ที่ยัง Pending)
+func releaseItemReservation(tx *gorm.DB, shipmentListID string) error {
+	return tx.Model(&Models.StockReservation{}).
+		Where("shipment_list_id = ? AND status = ?", shipmentListID, "Active").
+		Updates(map[string]interface{}{"status": "Released", "updated_at": time.Now()}).Error
+}
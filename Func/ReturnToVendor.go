@@ -0,0 +1,578 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// เหตุผลการส่งคืนสินค้าที่รองรับ
+var returnReasons = map[string]bool{
+	"Damaged":   true,
+	"WrongItem": true,
+	"Expired":   true,
+	"Quality":   true,
+	"Excess":    true,
+	"Other":     true,
+}
+
+// สถานะของเอกสารส่งคืนที่ยังนับเป็นจำนวนที่คืนแล้ว (ใช้ตรวจไม่ให้คืนเกินจำนวนที่รับ)
+var activeReturnStatuses = []string{"Draft", "Submitted", "Approved", "Dispatched", "Closed"}
+
+// ReturnToVendorLineRequest รายการที่ต้องการส่งคืน (จำนวนเป็นหน่วยย่อย)
+type ReturnToVendorLineRequest struct {
+	OrderItemID string           `json:"order_item_id"`
+	Quantity    int              `json:"quantity"`
+	FromDamaged bool             `json:"from_damaged"`
+	Reason      string           `json:"reason"`
+	Note        string           `json:"note"`
+	UnitCredit  *decimal.Decimal `json:"unit_credit"` // ไม่ระบุ = ราคาต่อหน่วยย่อยรวมภาษีจาก Order
+}
+
+// ยอดลดหนี้ต่อหน่วยย่อยจากราคาใน Order (ยอดหลังส่วนลดรวมภาษี หารจำนวน)
+func orderItemUnitCredit(item Models.OrderItem) decimal.Decimal {
+	if item.Quantity <= 0 {
+		return decimal.Zero
+	}
+	return item.LineTotal.Add(orderLineTax(item)).Div(decimal.NewFromInt(int64(item.Quantity))).Round(4)
+}
+
+// จำนวนที่ส่งคืนไปแล้ว (หรือรออยู่ในเอกสารที่ยังไม่ถูกยกเลิก) ของรายการใน Order แยกตามคืนจากสต็อก/ของชำรุด
+func returnedQuantities(tx *gorm.DB, orderID string) (map[string]map[bool]int, error) {
+	var rows []struct {
+		OrderItemID string
+		FromDamaged bool
+		Quantity    int
+	}
+	if err := tx.Table(`"ReturnToVendorLine" l`).
+		Select("l.order_item_id, l.from_damaged, SUM(l.quantity) AS quantity").
+		Joins(`JOIN "ReturnToVendor" r ON r.return_id = l.return_id`).
+		Where("r.order_id = ? AND r.status IN ?", orderID, activeReturnStatuses).
+		Group("l.order_item_id, l.from_damaged").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	returned := map[string]map[bool]int{}
+	for _, row := range rows {
+		if returned[row.OrderItemID] == nil {
+			returned[row.OrderItemID] = map[bool]int{}
+		}
+		returned[row.OrderItemID][row.FromDamaged] = row.Quantity
+	}
+	return returned, nil
+}
+
+// สร้างเอกสารส่งคืนสินค้า (สถานะ Draft) จาก Order ที่รับสินค้าแล้ว
+func AddReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		OrderID string                      `json:"order_id"`
+		Note    string                      `json:"note"`
+		Lines   []ReturnToVendorLineRequest `json:"lines"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if req.OrderID == "" || len(req.Lines) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "order_id and lines are required"})
+	}
+	for _, line := range req.Lines {
+		if line.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must be greater than 0"})
+		}
+		if !returnReasons[line.Reason] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid reason: " + line.Reason})
+		}
+		if line.UnitCredit != nil && line.UnitCredit.IsNegative() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unit_credit must be greater or equal to 0"})
+		}
+	}
+
+	var vendorReturn Models.ReturnToVendor
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// ล็อก Order เพื่อไม่ให้สร้างเอกสารส่งคืนของ Order เดียวกันพร้อมกันจนเกินจำนวน
+		var order Models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", req.OrderID).First(&order).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Order not found")
+		}
+		if order.BranchID == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Order has no destination branch")
+		}
+		if order.Status == "Cancelled" || order.Status == "Amended" {
+			return fiber.NewError(fiber.StatusBadRequest, "Cannot return goods of a "+order.Status+" order")
+		}
+
+		var orderItems []Models.OrderItem
+		if err := tx.Where("order_id = ?", order.OrderID).Find(&orderItems).Error; err != nil {
+			return err
+		}
+		items := map[string]Models.OrderItem{}
+		for _, item := range orderItems {
+			items[item.OrderItemID] = item
+		}
+
+		returned, err := returnedQuantities(tx, order.OrderID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		returnNumber, err := NextDocumentNumber(tx, "ReturnToVendor", *order.BranchID, now)
+		if err != nil {
+			return err
+		}
+		vendorReturn = Models.ReturnToVendor{
+			ReturnNumber: returnNumber,
+			OrderID:      order.OrderID,
+			SupplierID:   order.SupplierID.String(),
+			BranchID:     *order.BranchID,
+			Status:       "Draft",
+			CreditStatus: "Pending",
+			Note:         req.Note,
+			Currency:     defaultCurrency,
+			CreatedBy:    currentUsername(c),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if err := tx.Omit("Lines", "CreditNotes").Create(&vendorReturn).Error; err != nil {
+			return err
+		}
+
+		total := decimal.Zero
+		for _, line := range req.Lines {
+			item, ok := items[line.OrderItemID]
+			if !ok {
+				return fiber.NewError(fiber.StatusBadRequest, "Order item not found in this order: "+line.OrderItemID)
+			}
+
+			// คืนจากสต็อกได้ไม่เกินจำนวนที่รับเข้า ส่วนของชำรุดคืนได้ไม่เกินจำนวนที่ชำรุดตอนรับ
+			limit := item.ReceivedQty
+			if line.FromDamaged {
+				limit = item.DamagedQty
+			}
+			if returned[item.OrderItemID] == nil {
+				returned[item.OrderItemID] = map[bool]int{}
+			}
+			if returned[item.OrderItemID][line.FromDamaged]+line.Quantity > limit {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Return for order item %s exceeds returnable quantity (%d)", item.OrderItemID, limit-returned[item.OrderItemID][line.FromDamaged]))
+			}
+			returned[item.OrderItemID][line.FromDamaged] += line.Quantity
+
+			record := Models.ReturnToVendorLine{
+				ReturnID:    vendorReturn.ReturnID,
+				OrderItemID: item.OrderItemID,
+				ProductID:   item.ProductID,
+				Quantity:    line.Quantity,
+				FromDamaged: line.FromDamaged,
+				Reason:      line.Reason,
+				Note:        line.Note,
+				UnitCredit:  orderItemUnitCredit(item),
+				CreatedAt:   now,
+			}
+			if line.UnitCredit != nil {
+				record.UnitCredit = *line.UnitCredit
+			}
+			record.CreditAmount = record.UnitCredit.Mul(decimal.NewFromInt(int64(line.Quantity))).Round(2)
+			if item.Currency != "" {
+				vendorReturn.Currency = item.Currency
+			}
+
+			if !line.FromDamaged {
+				var inventory Models.Inventory
+				if err := tx.Where("product_id = ? AND branch_id = ?", item.ProductID, *order.BranchID).Order("created_at").First(&inventory).Error; err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "inventory not found in the receiving branch for product: "+item.ProductID)
+				}
+				record.InventoryID = inventory.InventoryID
+			}
+
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			vendorReturn.Lines = append(vendorReturn.Lines, record)
+			total = total.Add(record.CreditAmount)
+		}
+
+		vendorReturn.CreditExpected = total
+		return tx.Model(&vendorReturn).Updates(map[string]interface{}{"credit_expected": total, "currency": vendorReturn.Currency}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to create return: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Return created successfully", "data": vendorReturn})
+}
+
+// ดูเอกสารส่งคืนทั้งหมด (กรองตาม Supplier, Order, สาขา, สถานะ หรือสถานะใบลดหนี้ได้)
+func LookReturnsToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.ReturnToVendor{})
+	for _, filter := range []string{"supplier_id", "order_id", "branch_id", "status", "credit_status"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+
+	var returns []Models.ReturnToVendor
+	if err := query.Order("created_at DESC").Find(&returns).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch returns"})
+	}
+	return c.JSON(fiber.Map{"data": returns})
+}
+
+// ดูเอกสารส่งคืนพร้อมรายการและใบลดหนี้
+func FindReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	var vendorReturn Models.ReturnToVendor
+	if err := db.Preload("Lines").Preload("CreditNotes").Where("return_id = ?", c.Params("id")).First(&vendorReturn).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Return not found"})
+	}
+	return c.JSON(fiber.Map{
+		"data":               vendorReturn,
+		"credit_outstanding": vendorReturn.CreditExpected.Sub(vendorReturn.CreditReceived),
+		"approval":           approvalHistory(db, "ReturnToVendor", vendorReturn.ReturnID),
+	})
+}
+
+// ยอดลดหนี้ที่ยังไม่ได้รับ แยกตาม Supplier
+func GetVendorCreditOutstanding(db *gorm.DB, c *fiber.Ctx) error {
+	var rows []struct {
+		SupplierID   string          `json:"supplier_id"`
+		SupplierName string          `json:"supplier_name"`
+		Currency     string          `json:"currency"`
+		Returns      int             `json:"returns"`
+		Expected     decimal.Decimal `json:"expected"`
+		Received     decimal.Decimal `json:"received"`
+		Outstanding  decimal.Decimal `json:"outstanding"`
+	}
+	if err := db.Table(`"ReturnToVendor" r`).
+		Select(`r.supplier_id, s.name AS supplier_name, r.currency, COUNT(*) AS returns,
+			SUM(r.credit_expected) AS expected, SUM(r.credit_received) AS received,
+			SUM(r.credit_expected - r.credit_received) AS outstanding`).
+		Joins(`LEFT JOIN "Supplier" s ON s.supplier_id = r.supplier_id`).
+		Where("r.status = ? AND r.credit_status <> ?", "Dispatched", "Received").
+		Group("r.supplier_id, s.name, r.currency").
+		Order("outstanding DESC").
+		Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch outstanding credits: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rows})
+}
+
+// เปลี่ยนสถานะเอกสารส่งคืน (ตรวจสอบสถานะเดิมก่อน)
+func transitionReturnToVendor(tx *gorm.DB, id string, from ...string) (Models.ReturnToVendor, error) {
+	var vendorReturn Models.ReturnToVendor
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("return_id = ?", id).First(&vendorReturn).Error; err != nil {
+		return vendorReturn, fiber.NewError(fiber.StatusNotFound, "return not found")
+	}
+	for _, status := range from {
+		if vendorReturn.Status == status {
+			return vendorReturn, nil
+		}
+	}
+	return vendorReturn, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("only %v returns can do this (current status: %s)", from, vendorReturn.Status))
+}
+
+// ส่งเอกสารส่งคืนเพื่อขออนุมัติ
+func SubmitReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	var request Models.ApprovalRequest
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		vendorReturn, err := transitionReturnToVendor(tx, c.Params("id"), "Draft")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&vendorReturn).Updates(map[string]interface{}{"status": "Submitted", "submitted_at": now, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		request, _, err = startApproval(tx, "ReturnToVendor", vendorReturn.ReturnID, vendorReturn.BranchID, vendorReturn.CreditExpected, currentUsername(c))
+		return err
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to submit return: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Return submitted", "approval": request})
+}
+
+// อนุมัติขั้นปัจจุบันของเอกสารส่งคืน
+func ApproveReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	return respondDocumentApproval(db, c, "ReturnToVendor", c.Params("id"), true)
+}
+
+// ปฏิเสธเอกสารส่งคืน (ต้องระบุ comment)
+func RejectReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	return respondDocumentApproval(db, c, "ReturnToVendor", c.Params("id"), false)
+}
+
+// ยกเลิกเอกสารส่งคืนที่ยังไม่ได้ส่งของออก
+func CancelReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		vendorReturn, err := transitionReturnToVendor(tx, c.Params("id"), "Draft", "Submitted", "Approved")
+		if err != nil {
+			return err
+		}
+		if err := cancelApproval(tx, "ReturnToVendor", vendorReturn.ReturnID); err != nil {
+			return err
+		}
+		return tx.Model(&vendorReturn).Updates(map[string]interface{}{"status": "Cancelled", "updated_at": time.Now()}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to cancel return: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Return cancelled"})
+}
+
+// ตัดล็อตของสินค้าที่ส่งคืน โดยตัดล็อตที่มาจาก Order เดิมก่อน แล้วจึงตัดล็อตที่รับเข้าเก่าสุด
+func returnLots(tx *gorm.DB, inventoryID, orderID string, quantity int) error {
+	var lots []Models.InventoryLot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ? AND quantity > 0", inventoryID).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "COALESCE(order_id = ?, false) DESC, received_date ASC", Vars: []interface{}{orderID}}}).
+		Find(&lots).Error; err != nil {
+		return err
+	}
+
+	remaining := quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		take := lot.Quantity
+		if take > remaining {
+			take = remaining
+		}
+		if err := tx.Model(&Models.InventoryLot{}).
+			Where("lot_id = ?", lot.LotID).
+			Updates(map[string]interface{}{"quantity": gorm.Expr("quantity - ?", take), "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		remaining -= take
+	}
+	return nil
+}
+
+// ส่งหมายเลขซีเรียลคืน Supplier (เฉพาะสินค้าที่เป็น serialized)
+func returnSerials(tx *gorm.DB, vendorReturn Models.ReturnToVendor, line Models.ReturnToVendorLine, serials []string) error {
+	var product Models.Product
+	if err := tx.Where("product_id = ?", line.ProductID).First(&product).Error; err != nil || !product.Serialized {
+		return nil
+	}
+
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return err
+	}
+	if len(serials) != line.Quantity {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("return line %s is serialized: expected %d serial numbers, got %d", line.LineID, line.Quantity, len(serials)))
+	}
+
+	for _, serialNo := range serials {
+		var serial Models.SerialNumber
+		if err := tx.Where("serial_no = ? AND product_id = ? AND branch_id = ? AND status = ?",
+			serialNo, line.ProductID, vendorReturn.BranchID, "InStock").First(&serial).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "serial number is not in stock at the returning branch: "+serialNo)
+		}
+
+		serial.Status = "ReturnedToVendor"
+		serial.UpdatedAt = time.Now()
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, vendorReturn.BranchID, "", "ReturnToVendor", vendorReturn.ReturnID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ส่งสินค้าคืน Supplier: ตัดสต็อกของสาขา (ยกเว้นรายการของชำรุดที่ไม่ได้เข้าสต็อก)
+// serials ระบุหมายเลขซีเรียลต่อ line_id สำหรับสินค้าที่เป็น serialized
+func DispatchReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		Serials map[string][]string `json:"serials"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+		}
+	}
+
+	username := currentUsername(c)
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		vendorReturn, err := transitionReturnToVendor(tx, c.Params("id"), "Approved")
+		if err != nil {
+			return err
+		}
+
+		var lines []Models.ReturnToVendorLine
+		if err := tx.Where("return_id = ?", vendorReturn.ReturnID).Find(&lines).Error; err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if line.FromDamaged {
+				continue
+			}
+			inventory, err := ApplyStockChange(tx, StockChange{
+				InventoryID:  line.InventoryID,
+				Delta:        -line.Quantity,
+				MovementType: "ReturnToVendor",
+				DocumentType: "ReturnToVendor",
+				DocumentID:   vendorReturn.ReturnID,
+				Note:         line.Reason,
+				CreatedBy:    username,
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := consumeCost(tx, inventory, line.Quantity, "ReturnToVendor", vendorReturn.ReturnID); err != nil {
+				return err
+			}
+			if err := returnLots(tx, inventory.InventoryID, vendorReturn.OrderID, line.Quantity); err != nil {
+				return err
+			}
+			if err := returnSerials(tx, vendorReturn, line, req.Serials[line.LineID]); err != nil {
+				return err
+			}
+			if err := releaseLocations(tx, inventory, vendorReturn.ReturnID, username); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(&vendorReturn).Updates(map[string]interface{}{"status": "Dispatched", "dispatched_by": username, "dispatched_at": now, "updated_at": now}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to dispatch return: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Return dispatched"})
+}
+
+// บันทึกใบลดหนี้ที่ได้รับจาก Supplier (ได้รับครบตามยอดที่คาดไว้ เอกสารจะถูกปิด)
+func AddVendorCreditNote(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		CreditNoteNumber string          `json:"credit_note_number"`
+		Amount           decimal.Decimal `json:"amount"`
+		ReceivedAt       string          `json:"received_at"`
+		Note             string          `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if req.CreditNoteNumber == "" || !req.Amount.IsPositive() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "credit_note_number and a positive amount are required"})
+	}
+	receivedAt := time.Now()
+	if req.ReceivedAt != "" {
+		date, err := parseDate(req.ReceivedAt)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid received_at"})
+		}
+		receivedAt = date
+	}
+
+	var vendorReturn Models.ReturnToVendor
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		vendorReturn, err = transitionReturnToVendor(tx, c.Params("id"), "Dispatched")
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		note := Models.VendorCreditNote{
+			ReturnID:         vendorReturn.ReturnID,
+			CreditNoteNumber: req.CreditNoteNumber,
+			Amount:           req.Amount,
+			ReceivedAt:       receivedAt,
+			Note:             req.Note,
+			RecordedBy:       currentUsername(c),
+			CreatedAt:        now,
+		}
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+
+		vendorReturn.CreditReceived = vendorReturn.CreditReceived.Add(req.Amount)
+		vendorReturn.CreditStatus = "Partial"
+		if vendorReturn.CreditReceived.GreaterThanOrEqual(vendorReturn.CreditExpected) {
+			vendorReturn.CreditStatus = "Received"
+			vendorReturn.Status = "Closed"
+		}
+		vendorReturn.UpdatedAt = now
+		return tx.Model(&vendorReturn).Updates(map[string]interface{}{
+			"credit_received": vendorReturn.CreditReceived,
+			"credit_status":   vendorReturn.CreditStatus,
+			"status":          vendorReturn.Status,
+			"updated_at":      now,
+		}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to record credit note: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":            "Credit note recorded",
+		"credit_status":      vendorReturn.CreditStatus,
+		"credit_outstanding": vendorReturn.CreditExpected.Sub(vendorReturn.CreditReceived),
+		"approval":           approvalHistory(db, "ReturnToVendor", vendorReturn.ReturnID),
+	})
+}
+
+// ปิดเอกสารส่งคืนที่ส่งของแล้ว แม้ยังได้รับใบลดหนี้ไม่ครบ (เช่น Supplier เปลี่ยนสินค้าให้แทน)
+func CloseReturnToVendor(db *gorm.DB, c *fiber.Ctx) error {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		vendorReturn, err := transitionReturnToVendor(tx, c.Params("id"), "Dispatched")
+		if err != nil {
+			return err
+		}
+		return tx.Model(&vendorReturn).Updates(map[string]interface{}{"status": "Closed", "updated_at": time.Now()}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to close return: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Return closed"})
+}
+
+func ReturnToVendorRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/ReturnToVendor", func(c *fiber.Ctx) error {
+		return LookReturnsToVendor(db, c)
+	})
+
+	app.Get("/ReturnToVendor/credits/outstanding", func(c *fiber.Ctx) error {
+		return GetVendorCreditOutstanding(db, c)
+	})
+
+	app.Get("/ReturnToVendor/:id", func(c *fiber.Ctx) error {
+		return FindReturnToVendor(db, c)
+	})
+
+	app.Post("/ReturnToVendor", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AddReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/submit", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return SubmitReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/approve", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return ApproveReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/reject", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RejectReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/cancel", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CancelReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/dispatch", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return DispatchReturnToVendor(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/credit-notes", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AddVendorCreditNote(auditDB(db, c), c)
+	})
+
+	app.Post("/ReturnToVendor/:id/close", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CloseReturnToVendor(auditDB(db, c), c)
+	})
+}
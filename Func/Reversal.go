@@ -0,0 +1,257 @@
+package Func
+
+import (
+	"Api/Models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// movementSource เอกสารที่ movement ของมันถูกนำมาคิดยอดสุทธิเพื่อลงรายการกลับ
+type movementSource struct {
+	DocumentType string
+	DocumentIDs  []string
+}
+
+// StockReversal movement ที่ลงรายการกลับของ Inventory หนึ่งรายการ
+type StockReversal struct {
+	InventoryID string `json:"inventory_id"`
+	ProductID   string `json:"product_id"`
+	BranchID    string `json:"branch_id"`
+	Delta       int    `json:"delta"`
+}
+
+// CancelDocumentRequest เหตุผลการยกเลิก/แก้ไขเอกสาร
+type CancelDocumentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// อ่านเหตุผลการยกเลิก/แก้ไข (จำเป็นต้องระบุ)
+func parseCancelReason(c *fiber.Ctx) (string, error) {
+	var req CancelDocumentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return "", fiber.NewError(fiber.StatusBadRequest, "Invalid JSON format: "+err.Error())
+		}
+	}
+	if req.Reason == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "reason is required")
+	}
+	return req.Reason, nil
+}
+
+// เงื่อนไข WHERE ของ movement/ต้นทุนที่มาจากเอกสารในรายการ
+func movementSourceScope(typeColumn, idColumn string, sources []movementSource) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		condition := tx.Session(&gorm.Session{NewDB: true}).Where("1 = 0")
+		for _, source := range sources {
+			if len(source.DocumentIDs) > 0 {
+				condition = condition.Or(typeColumn+" = ? AND "+idColumn+" IN ?", source.DocumentType, source.DocumentIDs)
+			}
+		}
+		return tx.Where(condition)
+	}
+}
+
+// เอกสารเคยเปลี่ยนยอดสต็อกหรือไม่ (ใช้ห้ามลบเอกสารจริง)
+func documentAffectedStock(tx *gorm.DB, sources ...movementSource) (bool, error) {
+	var count int64
+	err := tx.Model(&Models.InventoryMovement{}).
+		Scopes(movementSourceScope("document_type", "document_id", sources)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ลงรายการกลับสต็อกของเอกสาร: คิดยอดสุทธิของ movement ต่อ Inventory จากเอกสารต้นทาง
+// แล้วลง movement ตรงข้ามในชื่อ documentType/documentID พร้อมกลับรายการต้นทุนและ Bin
+// movement ที่เคยลงรายการกลับไปแล้ว (documentType/documentID เดียวกัน) ถูกนับรวม จึงเรียกซ้ำได้โดยไม่กลับรายการซ้ำ
+func reverseDocumentStock(tx *gorm.DB, sources []movementSource, documentType, documentID, note, username string) ([]StockReversal, error) {
+	sources = append(sources, movementSource{DocumentType: documentType, DocumentIDs: []string{documentID}})
+
+	var nets []struct {
+		InventoryID string
+		Quantity    int
+	}
+	if err := tx.Model(&Models.InventoryMovement{}).
+		Select("inventory_id, SUM(quantity) AS quantity").
+		Scopes(movementSourceScope("document_type", "document_id", sources)).
+		Group("inventory_id").
+		Having("SUM(quantity) <> 0").
+		Order("inventory_id").
+		Scan(&nets).Error; err != nil {
+		return nil, err
+	}
+
+	var reversals []StockReversal
+	for _, net := range nets {
+		delta := -net.Quantity
+		inventory, err := ApplyStockChange(tx, StockChange{
+			InventoryID:  net.InventoryID,
+			Delta:        delta,
+			MovementType: "Reversal",
+			DocumentType: documentType,
+			DocumentID:   documentID,
+			Note:         note,
+			CreatedBy:    username,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := reverseDocumentCost(tx, inventory, delta, sources, documentType, documentID); err != nil {
+			return nil, err
+		}
+		if delta < 0 {
+			if err := releaseLocations(tx, inventory, documentID, username); err != nil {
+				return nil, err
+			}
+		}
+
+		reversals = append(reversals, StockReversal{
+			InventoryID: inventory.InventoryID,
+			ProductID:   inventory.ProductID,
+			BranchID:    inventory.BranchID,
+			Delta:       delta,
+		})
+	}
+	return reversals, nil
+}
+
+// กลับรายการต้นทุนให้ตรงกับที่เอกสารต้นทางทำไว้
+// ลดสต็อก = ตัดต้นทุนเท่าที่เอกสารเคยบันทึกชั้นต้นทุนไว้, เพิ่มสต็อก = คืนชั้นต้นทุนด้วยต้นทุนที่เอกสารเคยตัดไป
+func reverseDocumentCost(tx *gorm.DB, inventory Models.Inventory, delta int, sources []movementSource, documentType, documentID string) error {
+	var layered, consumed struct {
+		Quantity int
+		Total    float64
+	}
+	if err := tx.Model(&Models.CostLayer{}).
+		Select("COALESCE(SUM(quantity), 0) AS quantity").
+		Where("inventory_id = ?", inventory.InventoryID).
+		Scopes(movementSourceScope("source_type", "source_id", sources)).
+		Scan(&layered).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Models.CostConsumption{}).
+		Select("COALESCE(SUM(quantity), 0) AS quantity, COALESCE(SUM(total_cost), 0) AS total").
+		Where("inventory_id = ?", inventory.InventoryID).
+		Scopes(movementSourceScope("document_type", "document_id", sources)).
+		Scan(&consumed).Error; err != nil {
+		return err
+	}
+
+	if delta < 0 {
+		quantity := layered.Quantity - consumed.Quantity
+		if quantity > -delta {
+			quantity = -delta
+		}
+		_, err := consumeCost(tx, inventory, quantity, documentType, documentID)
+		return err
+	}
+	if consumed.Quantity <= 0 {
+		return nil
+	}
+	return receiveCost(tx, inventory, delta, consumed.Total/float64(consumed.Quantity), documentType, documentID)
+}
+
+// หลังลดสต็อก ถ้าจำนวนใน Bin มากกว่ายอด Inventory ให้เอาออกจาก Bin ตามลำดับรหัสตำแหน่ง
+func releaseLocations(tx *gorm.DB, inventory Models.Inventory, documentID, username string) error {
+	unlocated, err := unlocatedQuantity(tx, inventory)
+	if err != nil || unlocated >= 0 {
+		return err
+	}
+
+	var stocks []Models.LocationStock
+	if err := tx.Table(`"LocationStock" s`).
+		Select("s.*").
+		Joins(`JOIN "Location" l ON l.location_id = s.location_id`).
+		Where("s.inventory_id = ? AND s.quantity > 0", inventory.InventoryID).
+		Order("l.code").
+		Scan(&stocks).Error; err != nil {
+		return err
+	}
+
+	excess := -unlocated
+	for _, stock := range stocks {
+		if excess == 0 {
+			break
+		}
+		take := stock.Quantity
+		if take > excess {
+			take = excess
+		}
+		if err := changeLocationStock(tx, stock.LocationID, inventory, -take); err != nil {
+			return err
+		}
+		locationID := stock.LocationID
+		if err := tx.Create(&Models.LocationMove{
+			InventoryID:    inventory.InventoryID,
+			ProductID:      inventory.ProductID,
+			BranchID:       inventory.BranchID,
+			FromLocationID: &locationID,
+			Quantity:       take,
+			MoveType:       "Reversal",
+			DocumentID:     &documentID,
+			MovedBy:        username,
+			CreatedAt:      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		excess -= take
+	}
+	return nil
+}
+
+// คืนสินค้าที่หยิบออกจาก Bin ตอนอนุมัติ Shipment กลับเข้า Bin เดิม
+func restoreShipmentLocations(tx *gorm.DB, shipmentID, username string) error {
+	var moves []Models.LocationMove
+	if err := tx.Where("document_id = ? AND move_type = ? AND from_location_id IS NOT NULL", shipmentID, "Pick").
+		Find(&moves).Error; err != nil {
+		return err
+	}
+
+	for _, move := range moves {
+		var inventory Models.Inventory
+		if err := tx.Where("inventory_id = ?", move.InventoryID).First(&inventory).Error; err != nil {
+			return err
+		}
+		var location Models.Location
+		if err := tx.Where("location_id = ?", *move.FromLocationID).First(&location).Error; err != nil {
+			// Bin ถูกลบไปแล้ว สินค้ากลับเป็นสต็อกที่ยังไม่จัดเก็บ
+			continue
+		}
+		if err := changeLocationStock(tx, *move.FromLocationID, inventory, move.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Create(&Models.LocationMove{
+			InventoryID:  move.InventoryID,
+			ProductID:    move.ProductID,
+			BranchID:     move.BranchID,
+			ToLocationID: move.FromLocationID,
+			Quantity:     move.Quantity,
+			MoveType:     "Reversal",
+			DocumentID:   &shipmentID,
+			MovedBy:      username,
+			CreatedAt:    time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// คืนจำนวนล็อตที่หยิบไปกับ Shipment
+func restoreShipmentLots(tx *gorm.DB, shipmentID string) error {
+	var picks []Models.ShipmentLotPick
+	if err := tx.Where("shipment_id = ?", shipmentID).Find(&picks).Error; err != nil {
+		return err
+	}
+	for _, pick := range picks {
+		if err := tx.Model(&Models.InventoryLot{}).
+			Where("lot_id = ?", pick.LotID).
+			Updates(map[string]interface{}{"quantity": gorm.Expr("quantity + ?", pick.Quantity), "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
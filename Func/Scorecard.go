@@ -0,0 +1,265 @@
+package Func
+
+import (
+	"Api/Models"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// น้ำหนักของคะแนนรวมที่ใช้จัดอันดับ Supplier
+const (
+	onTimeWeight = 0.4
+	fillWeight   = 0.4
+	damageWeight = 0.2
+)
+
+// PricePoint ต้นทุนต่อหน่วยย่อยเฉลี่ยของสินค้าในแต่ละเดือน
+type PricePoint struct {
+	Period   time.Time `json:"period"`
+	UnitCost float64   `json:"unit_cost"`
+}
+
+// ProductPriceTrend การเปลี่ยนแปลงราคาของสินค้าจาก Supplier ในช่วงเวลา
+type ProductPriceTrend struct {
+	ProductID   string       `json:"product_id"`
+	ProductName string       `json:"product_name"`
+	FirstCost   float64      `json:"first_cost"`
+	LastCost    float64      `json:"last_cost"`
+	ChangePct   float64      `json:"change_pct"`
+	History     []PricePoint `json:"history"`
+}
+
+// SupplierScorecard ตัวชี้วัดผลการส่งของของ Supplier
+type SupplierScorecard struct {
+	SupplierID      string              `json:"supplier_id"`
+	SupplierName    string              `json:"supplier_name"`
+	From            time.Time           `json:"from"`
+	To              time.Time           `json:"to"`
+	Orders          int                 `json:"orders"`
+	Deliveries      int                 `json:"deliveries"`
+	OnTimeRate      *float64            `json:"on_time_rate"` // null เมื่อไม่มี Order ที่ระบุวันที่สัญญา
+	FillRate        *float64            `json:"fill_rate"`
+	DamageRate      *float64            `json:"damage_rate"`
+	AvgLeadTimeDays *float64            `json:"avg_lead_time_days"`
+	PriceChangePct  *float64            `json:"price_change_pct"` // ค่าเฉลี่ยการเปลี่ยนแปลงราคาของทุกสินค้า
+	Score           float64             `json:"score"`
+	PriceTrends     []ProductPriceTrend `json:"price_trends,omitempty"`
+}
+
+// ช่วงวันที่จาก query from/to (ค่าเริ่มต้น 90 วันล่าสุด) โดย to เป็นเวลาสิ้นสุดของวัน
+func scorecardRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -90)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = parseDate(value); err != nil {
+			return from, to, err
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = parseDate(value); err != nil {
+			return from, to, err
+		}
+	}
+	return from, to.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// สัดส่วน (ปัดเศษ 4 ตำแหน่ง) หรือ nil เมื่อตัวหารเป็น 0
+func ratio(numerator, denominator float64) *float64 {
+	if denominator <= 0 {
+		return nil
+	}
+	value := math.Round(numerator/denominator*10000) / 10000
+	return &value
+}
+
+// วันที่สัญญาเริ่มต้นของ Order ตาม lead time ของ Supplier (ไม่มี lead time = ไม่กำหนด)
+func defaultPromisedDate(tx *gorm.DB, supplierID string, orderedAt time.Time) *time.Time {
+	var supplier Models.Supplier
+	if err := tx.Select("supplier_id, lead_time_days").Where("supplier_id = ?", supplierID).First(&supplier).Error; err != nil || supplier.LeadTimeDays <= 0 {
+		return nil
+	}
+	date := orderedAt.AddDate(0, 0, supplier.LeadTimeDays)
+	return &date
+}
+
+// คำนวณตัวชี้วัดของ Supplier จาก Order ที่มีการรับสินค้าในช่วงวันที่
+func buildSupplierScorecard(db *gorm.DB, supplier Models.Supplier, from, to time.Time, withTrends bool) (SupplierScorecard, error) {
+	card := SupplierScorecard{SupplierID: supplier.SupplierID, SupplierName: supplier.Name, From: from, To: to}
+
+	// การส่งของแต่ละครั้ง (ใบรับสินค้า) เทียบกับวันที่สั่งและวันที่สัญญา
+	var deliveries []struct {
+		OrderID      string
+		OrderedAt    time.Time
+		PromisedDate *time.Time
+		ReceivedAt   time.Time
+	}
+	if err := db.Table(`"GoodsReceipt" r`).
+		Select("r.order_id, o.created_at AS ordered_at, o.promised_date, r.received_at").
+		Joins(`JOIN "Order" o ON o.order_id = r.order_id`).
+		Where("o.supplier_id = ? AND r.received_at BETWEEN ? AND ?", supplier.SupplierID, from, to).
+		Scan(&deliveries).Error; err != nil {
+		return card, err
+	}
+
+	orders := map[string]bool{}
+	onTime, promised := 0.0, 0.0
+	leadDays := 0.0
+	for _, delivery := range deliveries {
+		orders[delivery.OrderID] = true
+		leadDays += delivery.ReceivedAt.Sub(delivery.OrderedAt).Hours() / 24
+		if delivery.PromisedDate != nil {
+			promised++
+			// ส่งภายในวันที่สัญญาถือว่าตรงเวลา
+			promisedDate := *delivery.PromisedDate
+			deadline := time.Date(promisedDate.Year(), promisedDate.Month(), promisedDate.Day()+1, 0, 0, 0, 0, promisedDate.Location())
+			if delivery.ReceivedAt.Before(deadline) {
+				onTime++
+			}
+		}
+	}
+	card.Orders = len(orders)
+	card.Deliveries = len(deliveries)
+	card.OnTimeRate = ratio(onTime, promised)
+	if len(deliveries) > 0 {
+		average := math.Round(leadDays/float64(len(deliveries))*100) / 100
+		card.AvgLeadTimeDays = &average
+	}
+
+	// จำนวนที่สั่ง รับได้ และชำรุด ของ Order เหล่านั้น
+	orderIDs := make([]string, 0, len(orders))
+	for orderID := range orders {
+		orderIDs = append(orderIDs, orderID)
+	}
+	if len(orderIDs) > 0 {
+		var quantities struct {
+			Ordered  float64
+			Received float64
+			Damaged  float64
+		}
+		if err := db.Model(&Models.OrderItem{}).
+			Select("COALESCE(SUM(quantity), 0) AS ordered, COALESCE(SUM(received_qty), 0) AS received, COALESCE(SUM(damaged_qty), 0) AS damaged").
+			Where("order_id IN ?", orderIDs).
+			Scan(&quantities).Error; err != nil {
+			return card, err
+		}
+		card.FillRate = ratio(quantities.Received, quantities.Ordered)
+		card.DamageRate = ratio(quantities.Damaged, quantities.Received+quantities.Damaged)
+	}
+
+	// ต้นทุนต่อหน่วยย่อยรายเดือนของสินค้าแต่ละตัว (จาก Order ที่สั่งในช่วงวันที่)
+	var points []struct {
+		ProductID   string
+		ProductName string
+		Period      time.Time
+		UnitCost    float64
+	}
+	if err := db.Table(`"OrderItem" oi`).
+		Select(`oi.product_id, p.product_name, date_trunc('month', o.created_at) AS period,
+			SUM(oi.line_total) / NULLIF(SUM(oi.quantity), 0) AS unit_cost`).
+		Joins(`JOIN "Order" o ON o.order_id = oi.order_id`).
+		Joins(`JOIN "Product" p ON p.product_id = oi.product_id`).
+		Where("o.supplier_id = ? AND o.created_at BETWEEN ? AND ? AND o.status NOT IN ?", supplier.SupplierID, from, to, []string{"Draft", "Cancelled"}).
+		Group("oi.product_id, p.product_name, period").
+		Order("oi.product_id, period").
+		Scan(&points).Error; err != nil {
+		return card, err
+	}
+
+	var trends []ProductPriceTrend
+	for _, point := range points {
+		if len(trends) == 0 || trends[len(trends)-1].ProductID != point.ProductID {
+			trends = append(trends, ProductPriceTrend{ProductID: point.ProductID, ProductName: point.ProductName, FirstCost: point.UnitCost})
+		}
+		trend := &trends[len(trends)-1]
+		trend.LastCost = point.UnitCost
+		trend.History = append(trend.History, PricePoint{Period: point.Period, UnitCost: point.UnitCost})
+	}
+	totalChange, priced := 0.0, 0.0
+	for i := range trends {
+		if trends[i].FirstCost > 0 {
+			trends[i].ChangePct = math.Round((trends[i].LastCost-trends[i].FirstCost)/trends[i].FirstCost*10000) / 100
+			totalChange += trends[i].ChangePct
+			priced++
+		}
+	}
+	if priced > 0 {
+		average := math.Round(totalChange/priced*100) / 100
+		card.PriceChangePct = &average
+	}
+	if withTrends {
+		card.PriceTrends = trends
+	}
+
+	// คะแนนรวม 0-100 (ตัวชี้วัดที่ยังไม่มีข้อมูลไม่นำมาคิด)
+	score, weight := 0.0, 0.0
+	if card.OnTimeRate != nil {
+		score += *card.OnTimeRate * onTimeWeight
+		weight += onTimeWeight
+	}
+	if card.FillRate != nil {
+		score += *card.FillRate * fillWeight
+		weight += fillWeight
+	}
+	if card.DamageRate != nil {
+		score += (1 - *card.DamageRate) * damageWeight
+		weight += damageWeight
+	}
+	if weight > 0 {
+		card.Score = math.Round(score/weight*10000) / 100
+	}
+	return card, nil
+}
+
+// ดู scorecard ของ Supplier (from, to)
+func GetSupplierScorecard(db *gorm.DB, c *fiber.Ctx) error {
+	var supplier Models.Supplier
+	if err := db.Unscoped().Where("supplier_id = ?", c.Params("id")).First(&supplier).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+
+	from, to, err := scorecardRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date range"})
+	}
+
+	card, err := buildSupplierScorecard(db, supplier, from, to, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build supplier scorecard: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": card})
+}
+
+// จัดอันดับ Supplier ที่มีการส่งของในช่วงวันที่ ตามคะแนนรวม
+func GetSupplierRanking(db *gorm.DB, c *fiber.Ctx) error {
+	from, to, err := scorecardRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date range"})
+	}
+
+	var suppliers []Models.Supplier
+	if err := db.Unscoped().
+		Where(`supplier_id IN (SELECT o.supplier_id FROM "GoodsReceipt" r JOIN "Order" o ON o.order_id = r.order_id WHERE r.received_at BETWEEN ? AND ?)`, from, to).
+		Find(&suppliers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch suppliers: " + err.Error()})
+	}
+
+	ranking := make([]SupplierScorecard, 0, len(suppliers))
+	for _, supplier := range suppliers {
+		card, err := buildSupplierScorecard(db, supplier, from, to, false)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to build supplier scorecard: " + err.Error()})
+		}
+		ranking = append(ranking, card)
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Score > ranking[j].Score
+	})
+
+	return c.JSON(fiber.Map{"from": from, "to": to, "data": ranking})
+}
@@ -0,0 +1,241 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SerialReceiptRequest หมายเลขซีเรียลที่รับเข้าพร้อม Order
+type SerialReceiptRequest struct {
+	ProductID     string   `json:"product_id"`
+	BranchID      string   `json:"branch_id"`
+	SerialNumbers []string `json:"serial_numbers"`
+}
+
+// SerialDispatchRequest หมายเลขซีเรียลที่ส่งออกไปกับ ShipmentItem
+type SerialDispatchRequest struct {
+	ShipmentListID string   `json:"shipment_list_id"`
+	SerialNumbers  []string `json:"serial_numbers"`
+}
+
+// ตรวจสอบว่าหมายเลขซีเรียลไม่ว่างและไม่ซ้ำกันในคำขอเดียวกัน
+func normalizeSerials(serials []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "serial number cannot be empty")
+		}
+		if seen[serial] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "duplicate serial number: "+serial)
+		}
+		seen[serial] = true
+		result = append(result, serial)
+	}
+	return result, nil
+}
+
+// บันทึกการเคลื่อนไหวของหมายเลขซีเรียล
+func recordSerialMovement(tx *gorm.DB, serial Models.SerialNumber, fromBranchID, toBranchID, documentType, documentID string) error {
+	movement := Models.SerialMovement{
+		SerialID:     serial.SerialID,
+		SerialNo:     serial.SerialNo,
+		ProductID:    serial.ProductID,
+		FromBranchID: fromBranchID,
+		ToBranchID:   toBranchID,
+		Status:       serial.Status,
+		DocumentType: documentType,
+		DocumentID:   documentID,
+		CreatedAt:    time.Now(),
+	}
+	return tx.Create(&movement).Error
+}
+
+// รับหมายเลขซีเรียลเข้าสาขาพร้อม Order (ต้องมีจำนวนเท่ากับจำนวนที่รับเข้า)
+func receiveSerials(tx *gorm.DB, order Models.Order, item Models.OrderItem, receipts []SerialReceiptRequest) error {
+	var product Models.Product
+	if err := tx.Where("product_id = ?", item.ProductID).First(&product).Error; err != nil {
+		return fmt.Errorf("product not found: %s", item.ProductID)
+	}
+	if !product.Serialized {
+		return nil
+	}
+
+	var serials []string
+	branchID := ""
+	for _, receipt := range receipts {
+		serials = append(serials, receipt.SerialNumbers...)
+		if receipt.BranchID != "" {
+			branchID = receipt.BranchID
+		}
+	}
+
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return err
+	}
+	if len(serials) != item.Quantity {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("product %s is serialized: expected %d serial numbers, got %d", item.ProductID, item.Quantity, len(serials)))
+	}
+
+	inventoryQuery := tx.Where("product_id = ?", item.ProductID)
+	if branchID != "" {
+		inventoryQuery = inventoryQuery.Where("branch_id = ?", branchID)
+	}
+	var inventory Models.Inventory
+	if err := inventoryQuery.Order("created_at").First(&inventory).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "no inventory found to receive serial numbers for product: "+item.ProductID)
+	}
+
+	now := time.Now()
+	for _, serialNo := range serials {
+		var existing int64
+		if err := tx.Model(&Models.SerialNumber{}).Where("serial_no = ?", serialNo).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fiber.NewError(fiber.StatusConflict, "serial number already exists: "+serialNo)
+		}
+
+		serial := Models.SerialNumber{
+			SerialNo:    serialNo,
+			ProductID:   item.ProductID,
+			BranchID:    inventory.BranchID,
+			InventoryID: inventory.InventoryID,
+			Status:      "InStock",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Create(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, "", inventory.BranchID, "Order", order.OrderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ส่งหมายเลขซีเรียลออกไปกับ ShipmentItem (เปลี่ยนสถานะเป็น InTransit)
+func dispatchSerials(tx *gorm.DB, shipment Models.Shipment, item Models.ShipmentItem, serials []string) error {
+	var inventory Models.Inventory
+	if err := tx.Where("inventory_id = ?", item.WarehouseInventoryID).First(&inventory).Error; err != nil {
+		return fmt.Errorf("failed to find inventory for item: %s", item.WarehouseInventoryID)
+	}
+
+	var product Models.Product
+	if err := tx.Where("product_id = ?", inventory.ProductID).First(&product).Error; err != nil || !product.Serialized {
+		return nil
+	}
+
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return err
+	}
+	if len(serials) != item.Quantity {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("shipment item %s is serialized: expected %d serial numbers, got %d", item.ShipmentListID, item.Quantity, len(serials)))
+	}
+
+	for _, serialNo := range serials {
+		var serial Models.SerialNumber
+		if err := tx.Where("serial_no = ? AND product_id = ? AND branch_id = ? AND status = ?",
+			serialNo, inventory.ProductID, inventory.BranchID, "InStock").First(&serial).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "serial number is not in stock at the source branch: "+serialNo)
+		}
+
+		serial.Status = "InTransit"
+		serial.UpdatedAt = time.Now()
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, shipment.FromBranchID, shipment.ToBranchID, "Shipment", shipment.ShipmentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ปิดการขนส่งของหมายเลขซีเรียล: รับเข้าสาขาปลายทาง หรือคืนสาขาต้นทางเมื่อถูกปฏิเสธ
+func settleShipmentSerials(tx *gorm.DB, shipment Models.Shipment, delivered bool) error {
+	var movements []Models.SerialMovement
+	if err := tx.Where("document_type = ? AND document_id = ? AND status = ?", "Shipment", shipment.ShipmentID, "InTransit").
+		Find(&movements).Error; err != nil {
+		return err
+	}
+
+	for _, movement := range movements {
+		var serial Models.SerialNumber
+		if err := tx.Where("serial_id = ? AND status = ?", movement.SerialID, "InTransit").First(&serial).Error; err != nil {
+			continue
+		}
+
+		if delivered {
+			serial.Status = "Delivered"
+			serial.BranchID = shipment.ToBranchID
+		} else {
+			serial.Status = "InStock"
+		}
+		serial.UpdatedAt = time.Now()
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+
+		from, to := shipment.FromBranchID, shipment.ToBranchID
+		if !delivered {
+			from, to = shipment.ToBranchID, shipment.FromBranchID
+		}
+		if err := recordSerialMovement(tx, serial, from, to, "Shipment", shipment.ShipmentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// รับซีเรียลที่ค้าง InTransit ของ Shipment ที่ปิดเป็น Completed ไปแล้วโดยไม่ได้รับซีเรียล (ข้อมูลเดิมก่อนเลิกปิด Shipment อัตโนมัติ)
+func SettleCompletedShipmentSerials(db *gorm.DB) error {
+	var shipments []Models.Shipment
+	if err := db.Where("status = ?", "Completed").
+		Where(`shipment_id::text IN (SELECT m.document_id FROM "SerialMovement" m JOIN "SerialNumber" s ON s.serial_id = m.serial_id
+			WHERE m.document_type = ? AND m.status = ? AND s.status = ?)`, "Shipment", "InTransit", "InTransit").
+		Find(&shipments).Error; err != nil {
+		return err
+	}
+
+	for _, shipment := range shipments {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return settleShipmentSerials(tx, shipment, true)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ดูหมายเลขซีเรียลพร้อมประวัติการเคลื่อนไหวทั้งหมด
+func FindSerial(db *gorm.DB, c *fiber.Ctx) error {
+	serialNo := c.Params("sn")
+
+	var serial Models.SerialNumber
+	if err := db.Where("serial_no = ?", serialNo).First(&serial).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Serial number not found"})
+	}
+
+	var movements []Models.SerialMovement
+	if err := db.Where("serial_id = ?", serial.SerialID).Order("created_at ASC").Find(&movements).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch serial history: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"serial": serial, "history": movements})
+}
+
+func SerialRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/serials/:sn", func(c *fiber.Ctx) error {
+		return FindSerial(db, c)
+	})
+}
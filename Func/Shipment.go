@@ -1,6 +1,7 @@
 package Func
 
 import (
+	"Api/Authentication"
 	"Api/Models"
 	"encoding/json"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"github.com/go-co-op/gocron"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Inventory struct {
@@ -39,6 +42,85 @@ func (Request) TableName() string {
 	return "Requests"
 }
 
+// ShipmentItemInput ข้อมูลสินค้าที่จะส่งใน Shipment
+type ShipmentItemInput struct {
+	WarehouseInventoryID string
+	PosInventoryID       string
+	ProductUnitID        string
+	Quantity             int
+}
+
+// สร้าง Shipment พร้อม ShipmentItem และ Request ใน POS (ใช้ร่วมกันระหว่างสร้างเองและจากข้อเสนอเติมสินค้า)
+func createShipment(tx *gorm.DB, posDB *gorm.DB, fromBranchID, toBranchID string, items []ShipmentItemInput) (Models.Shipment, error) {
+	shipmentID := uuid.New()
+	shipmentNumber, err := NextDocumentNumber(tx, "Shipment", fromBranchID, time.Now())
+	if err != nil {
+		return Models.Shipment{}, err
+	}
+
+	shipment := Models.Shipment{
+		ShipmentID:     shipmentID.String(),
+		ShipmentNumber: shipmentNumber,
+		FromBranchID:   fromBranchID,
+		ToBranchID:     toBranchID,
+		Status:         "Pending",
+		ShipmentDate:   time.Now(),
+	}
+
+	if err := tx.Save(&shipment).Error; err != nil {
+		return shipment, err
+	}
+
+	for _, item := range items {
+		productUnitID := item.ProductUnitID
+		if productUnitID == "" {
+			productUnitID = uuid.New().String()
+		}
+
+		var posInventory Inventory
+		if err := posDB.Where("inventory_id = ?", item.PosInventoryID).First(&posInventory).Error; err != nil {
+			return shipment, fiber.NewError(fiber.StatusBadRequest, "Invalid PosInventoryID")
+		}
+
+		shipmentItem := Models.ShipmentItem{
+			ShipmentListID:       uuid.New().String(),
+			ShipmentID:           shipmentID.String(),
+			WarehouseInventoryID: item.WarehouseInventoryID,
+			PosInventoryID:       item.PosInventoryID,
+			ProductUnitID:        productUnitID,
+			Status:               "Pending",
+			Quantity:             item.Quantity,
+			CreatedAt:            time.Now(),
+			UpdatedAt:            time.Now(),
+		}
+
+		if err := tx.Create(&shipmentItem).Error; err != nil {
+			return shipment, err
+		}
+
+		// จองสต็อกไว้จนกว่า Shipment จะถูกอนุมัติหรือปฏิเสธ
+		if err := reserveStock(tx, shipmentItem); err != nil {
+			return shipment, err
+		}
+
+		request := Request{
+			RequestID:    shipmentID,
+			FromBranchID: fromBranchID,
+			ToBranchID:   toBranchID,
+			ProductID:    posInventory.ProductID.String(),
+			Quantity:     item.Quantity,
+			Status:       "Pending",
+			CreatedAt:    time.Now(),
+		}
+
+		if err := posDB.Create(&request).Error; err != nil {
+			return shipment, err
+		}
+	}
+
+	return shipment, nil
+}
+
 // เพิ่ม Shipment ใหม่ พร้อมกับ Request ใน POS
 func AddShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 	type ShipmentRequest struct {
@@ -61,78 +143,177 @@ func AddShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "FromBranchID, ToBranchID, and Items are required"})
 	}
 
-	shipmentID := uuid.New()
+	var items []ShipmentItemInput
+	for _, item := range req.Items {
+		quantity, err := item.Quantity.Int64()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid quantity format"})
+		}
+		if quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must be greater than 0"})
+		}
+		items = append(items, ShipmentItemInput{
+			WarehouseInventoryID: item.WarehouseInventoryID,
+			PosInventoryID:       item.PosInventoryID,
+			ProductUnitID:        item.ProductUnitID,
+			Quantity:             int(quantity),
+		})
+	}
 
+	var shipment Models.Shipment
 	if err := db.Transaction(func(tx *gorm.DB) error {
-		shipment := Models.Shipment{
-			ShipmentID:     shipmentID.String(),
-			ShipmentNumber: GenerateULID(),
-			FromBranchID:   req.FromBranchID,
-			ToBranchID:     req.ToBranchID,
-			Status:         "Pending",
-			ShipmentDate:   time.Now(),
+		var err error
+		shipment, err = createShipment(tx, posDB, req.FromBranchID, req.ToBranchID, items)
+		return err
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Transaction failed", "details": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Shipment created successfully", "shipment_id": shipment.ShipmentID})
+}
+
+// ตัดสต็อกคลังของ Shipment ที่ได้รับอนุมัติครบทุกขั้น (ตัดต้นทุน หยิบล็อต หยิบจาก Bin และส่งซีเรียล)
+func approveShipment(tx *gorm.DB, shipmentID, username string, serials []SerialDispatchRequest) (fiber.Map, error) {
+	var shipment Models.Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("shipment_id = ?", shipmentID).First(&shipment).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Shipment not found")
+	}
+	if shipment.Status != "Pending" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Only Pending shipments can be approved (current status: "+shipment.Status+")")
+	}
+
+	serialsByItem := map[string][]string{}
+	for _, serial := range serials {
+		serialsByItem[serial.ShipmentListID] = append(serialsByItem[serial.ShipmentListID], serial.SerialNumbers...)
+	}
+
+	var shipmentItems []Models.ShipmentItem
+	if err := tx.Where("shipment_id = ?", shipment.ShipmentID).Find(&shipmentItems).Error; err != nil {
+		return nil, err
+	}
+	if len(shipmentItems) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "No shipment items found for this shipment")
+	}
+
+	var lotPicks []Models.ShipmentLotPick
+	var pickList []PickLine
+	transferCost := 0.0
+	for _, item := range shipmentItems {
+		// ตัดสต็อกคลัง (ล็อกแถว, ไม่พอ = ยกเลิกทั้ง transaction)
+		warehouseInventory, err := ApplyStockChange(tx, StockChange{
+			InventoryID:  item.WarehouseInventoryID,
+			Delta:        -item.Quantity,
+			MovementType: "TransferOut",
+			DocumentType: "Shipment",
+			DocumentID:   shipment.ShipmentID,
+			Note:         shipment.ShipmentNumber,
+			CreatedBy:    username,
+		})
+		if err != nil {
+			log.Printf("Failed to decrease Warehouse Inventory for ID %s: %v", item.WarehouseInventoryID, err)
+			return nil, err
 		}
 
-		if err := tx.Save(&shipment).Error; err != nil {
-			return err
+		// ตัดต้นทุนของสินค้าที่โอนออก
+		cost, err := consumeCost(tx, warehouseInventory, item.Quantity, "Shipment", shipment.ShipmentID)
+		if err != nil {
+			return nil, err
 		}
+		transferCost += cost
 
-		for _, item := range req.Items {
-			quantity, err := item.Quantity.Int64()
-			if err != nil {
-				return fiber.NewError(fiber.StatusBadRequest, "Invalid quantity format")
-			}
+		// หยิบล็อตแบบ FEFO
+		picks, err := consumeLotsFEFO(tx, item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pick lots for item: %s", item.WarehouseInventoryID)
+		}
+		lotPicks = append(lotPicks, picks...)
 
-			productUnitID := item.ProductUnitID
-			if productUnitID == "" {
-				productUnitID = uuid.New().String()
-			}
+		// หยิบสินค้าจาก Bin ตามตำแหน่งจัดเก็บ
+		lines, err := pickFromLocations(tx, shipment, item, username)
+		if err != nil {
+			return nil, err
+		}
+		pickList = append(pickList, lines...)
 
-			var posInventory Inventory
-			if err := posDB.Where("inventory_id = ?", item.PosInventoryID).First(&posInventory).Error; err != nil {
-				return fiber.NewError(fiber.StatusBadRequest, "Invalid PosInventoryID")
-			}
+		// ส่งหมายเลขซีเรียลออก (เฉพาะสินค้าที่เป็น serialized)
+		if err := dispatchSerials(tx, shipment, item, serialsByItem[item.ShipmentListID]); err != nil {
+			return nil, err
+		}
+	}
+	if err := settleReservations(tx, shipment.ShipmentID, "Consumed"); err != nil {
+		return nil, err
+	}
 
-			shipmentItem := Models.ShipmentItem{
-				ShipmentListID:       uuid.New().String(),
-				ShipmentID:           shipmentID.String(),
-				WarehouseInventoryID: item.WarehouseInventoryID,
-				PosInventoryID:       item.PosInventoryID,
-				ProductUnitID:        productUnitID,
-				Status:               "Pending",
-				Quantity:             int(quantity),
-				CreatedAt:            time.Now(),
-				UpdatedAt:            time.Now(),
-			}
+	shipment.Status = "Approved"
+	shipment.UpdatedAt = time.Now()
+	if err := tx.Save(&shipment).Error; err != nil {
+		return nil, err
+	}
 
-			if err := tx.Create(&shipmentItem).Error; err != nil {
-				return err
-			}
+	return fiber.Map{
+		"shipment":      shipment,
+		"lot_picks":     lotPicks,
+		"pick_list":     pickList,
+		"transfer_cost": transferCost,
+	}, nil
+}
 
-			request := Request{
-				RequestID:    shipmentID,
-				FromBranchID: req.FromBranchID,
-				ToBranchID:   req.ToBranchID,
-				ProductID:    posInventory.ProductID.String(),
-				Quantity:     int(quantity),
-				Status:       "Pending",
-				CreatedAt:    time.Now(),
-			}
+// ปฏิเสธ Shipment และคืนยอดที่จองไว้
+func rejectShipment(tx *gorm.DB, shipmentID string) error {
+	if err := settleReservations(tx, shipmentID, "Released"); err != nil {
+		return err
+	}
+	return tx.Model(&Models.Shipment{}).Where("shipment_id = ?", shipmentID).
+		Updates(map[string]interface{}{"status": "Rejected", "updated_at": time.Now()}).Error
+}
 
-			if err := posDB.Create(&request).Error; err != nil {
-				return err
-			}
-		}
+// มูลค่าของ Shipment ตามราคาทุนของสาขาต้นทาง (ใช้เลือกกฎการอนุมัติ)
+func shipmentValue(tx *gorm.DB, shipment Models.Shipment) (decimal.Decimal, error) {
+	var items []struct {
+		ProductID string
+		Quantity  int
+	}
+	if err := tx.Table(`"ShipmentItem" si`).
+		Select("i.product_id, si.quantity").
+		Joins(`JOIN "Inventory" i ON i.inventory_id = si.warehouse_inventory_id`).
+		Where("si.shipment_id = ?", shipment.ShipmentID).
+		Scan(&items).Error; err != nil {
+		return decimal.Zero, err
+	}
 
-		return nil
+	total := 0.0
+	for _, item := range items {
+		total += float64(item.Quantity) * currentUnitCost(tx, item.ProductID, shipment.FromBranchID)
+	}
+	return decimal.NewFromFloat(total).Round(2), nil
+}
+
+// ส่ง Shipment เพื่อขออนุมัติ (สต็อกจะถูกตัดเมื่ออนุมัติครบทุกขั้น)
+func SubmitShipment(db *gorm.DB, c *fiber.Ctx) error {
+	var request Models.ApprovalRequest
+	var result fiber.Map
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var shipment Models.Shipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("shipment_id = ?", c.Params("id")).First(&shipment).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Shipment not found")
+		}
+		if shipment.Status != "Pending" {
+			return fiber.NewError(fiber.StatusBadRequest, "Only Pending shipments can be submitted for approval")
+		}
+		amount, err := shipmentValue(tx, shipment)
+		if err != nil {
+			return err
+		}
+		request, result, err = startApproval(tx, "Shipment", shipment.ShipmentID, shipment.FromBranchID, amount, currentUsername(c))
+		return err
 	}); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Transaction failed", "details": err.Error()})
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to submit shipment: " + err.Error()})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Shipment created successfully", "shipment_id": shipmentID.String()})
+	return c.JSON(fiber.Map{"message": "Shipment submitted for approval", "approval": request, "result": result})
 }
 
-// อัพเดตสถานะของ Shipment
+// อัพเดตสถานะของ Shipment (การอนุมัติต้องผ่าน approval workflow: POST /Shipments/:id/submit)
 func UpdateShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
 	var shipment Models.Shipment
@@ -152,125 +333,240 @@ func UpdateShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON"})
 	}
 
-	allowedStatuses := map[string]bool{"Pending": true, "Approved": true, "Rejected": true}
+	if req.Status == "Approved" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Shipments are approved through the approval workflow (POST /Shipments/:id/submit)"})
+	}
+	allowedStatuses := map[string]bool{"Pending": true, "Rejected": true}
 	if !allowedStatuses[req.Status] {
 		log.Println("Invalid status provided:", req.Status)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid status"})
 	}
 
-	if req.Status == "Approved" {
-		var shipmentItems []Models.ShipmentItem
-		if err := db.Where("shipment_id = ?", id).Find(&shipmentItems).Error; err != nil {
-			log.Println("Error retrieving shipment items:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retrieve shipment items"})
-		}
+	if shipment.Status != "Pending" && shipment.Status != "Rejected" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot update a " + shipment.Status + " shipment",
+		})
+	}
 
-		if len(shipmentItems) == 0 {
-			log.Println("No shipment items found for shipment ID:", id)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No shipment items found for this shipment"})
+	previousStatus := shipment.Status
+	shipment.Status = req.Status
+	shipment.UpdatedAt = time.Now()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// ปฏิเสธแล้วยกเลิกคำขออนุมัติที่ค้างอยู่ และคืนยอดที่จองไว้
+		if req.Status == "Rejected" {
+			if err := cancelApproval(tx, "Shipment", shipment.ShipmentID); err != nil {
+				return err
+			}
+			return rejectShipment(tx, shipment.ShipmentID)
 		}
-
-		if err := db.Transaction(func(tx *gorm.DB) error {
+		// เปิด Shipment ที่ถูกปฏิเสธกลับมาเป็น Pending ต้องจองสต็อกใหม่ (ยอดจองเดิมถูกปล่อยไปแล้ว)
+		if previousStatus == "Rejected" {
+			var shipmentItems []Models.ShipmentItem
+			if err := tx.Where("shipment_id = ?", shipment.ShipmentID).Find(&shipmentItems).Error; err != nil {
+				return err
+			}
 			for _, item := range shipmentItems {
-				log.Printf("Processing item: %+v\n", item)
-
-				var inventory Inventory
-				if err := tx.Where("inventory_id = ?", item.WarehouseInventoryID).First(&inventory).Error; err != nil {
-					log.Printf("Error finding inventory for ID %s: %v\n", item.WarehouseInventoryID, err)
-					return fmt.Errorf("failed to find inventory for item: %s", item.WarehouseInventoryID)
+				if err := reserveStock(tx, item); err != nil {
+					return err
 				}
+			}
+		}
+		return tx.Save(&shipment).Error
+	}); err != nil {
+		log.Println("Error updating shipment:", err)
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to update shipment: " + err.Error()})
+	}
 
-				log.Printf("Before update - Inventory ID: %s, Current Quantity: %d, Required Quantity: %d",
-					item.WarehouseInventoryID, inventory.Quantity, item.Quantity)
-
-				if err := tx.Model(&Inventory{}).
-					Where("inventory_id = ?", item.WarehouseInventoryID).
-					Where("quantity >= ?", item.Quantity).
-					Update("quantity", gorm.Expr("quantity - ?", item.Quantity)).Error; err != nil {
-					log.Printf("Failed to decrease Warehouse Inventory for ID %s: %v", item.WarehouseInventoryID, err)
-					return fmt.Errorf("failed to update Warehouse Inventory for item: %s", item.WarehouseInventoryID)
-				}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":  "Shipment updated successfully",
+		"shipment": shipment,
+	})
+}
 
-				if err := tx.Where("inventory_id = ?", item.WarehouseInventoryID).First(&inventory).Error; err != nil {
-					log.Printf("Error fetching inventory after update for ID %s: %v", item.WarehouseInventoryID, err)
-					return fmt.Errorf("failed to fetch inventory after update for WarehouseInventoryID %s", item.WarehouseInventoryID)
-				}
+// คืนหมายเลขซีเรียลที่ส่งไปกับ Shipment กลับเข้าสต็อกสาขาต้นทาง (ต้องยังไม่ถูกขาย/ส่งต่อ)
+func reverseShipmentSerials(tx *gorm.DB, shipment Models.Shipment) error {
+	var serials []Models.SerialNumber
+	if err := tx.Where(`serial_id IN (SELECT serial_id FROM "SerialMovement" WHERE document_type = ? AND document_id = ?)`, "Shipment", shipment.ShipmentID).
+		Find(&serials).Error; err != nil {
+		return err
+	}
 
-				log.Printf("After update - Inventory ID: %s, New Quantity: %d", item.WarehouseInventoryID, inventory.Quantity)
+	for _, serial := range serials {
+		switch serial.Status {
+		case "InTransit":
+			if serial.BranchID != shipment.FromBranchID {
+				return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("serial number %s is in transit with another shipment", serial.SerialNo))
 			}
-			return nil
-		}); err != nil {
-			log.Println("Error during inventory update transaction:", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update inventory", "details": err.Error()})
+		case "Delivered":
+			if serial.BranchID != shipment.ToBranchID {
+				return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("serial number %s has moved on from the destination branch", serial.SerialNo))
+			}
+		case "InStock":
+			if serial.BranchID == shipment.FromBranchID {
+				continue
+			}
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("serial number %s is now in stock at another branch", serial.SerialNo))
+		default:
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("serial number %s can no longer be returned (%s)", serial.SerialNo, serial.Status))
+		}
+
+		serial.Status = "InStock"
+		serial.BranchID = shipment.FromBranchID
+		serial.UpdatedAt = time.Now()
+		if err := tx.Save(&serial).Error; err != nil {
+			return err
+		}
+		if err := recordSerialMovement(tx, serial, shipment.ToBranchID, shipment.FromBranchID, "Shipment", shipment.ShipmentID); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if shipment.Status == "Completed" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Cannot update a completed shipment",
-		})
+// ลงรายการกลับสต็อกที่ Shipment ทำไว้ (ตัดสต็อกต้นทาง/รับเข้าปลายทาง ล็อต Bin และซีเรียล) แล้วปิดด้วยสถานะ Cancelled หรือ Amended
+func reverseShipment(tx *gorm.DB, shipment *Models.Shipment, status, reason, username string) ([]StockReversal, error) {
+	switch shipment.Status {
+	case "Rejected", "Cancelled", "Amended":
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Shipment is already "+shipment.Status)
 	}
 
-	shipment.Status = req.Status
-	shipment.UpdatedAt = time.Now()
-	if err := db.Save(&shipment).Error; err != nil {
-		log.Println("Error updating shipment:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update shipment"})
+	if err := cancelApproval(tx, "Shipment", shipment.ShipmentID); err != nil {
+		return nil, err
 	}
 
-	log.Println("Shipment updated successfully:", shipment)
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message":  "Shipment updated successfully",
-		"shipment": shipment,
-	})
+	var reversals []StockReversal
+	if shipment.Status == "Pending" {
+		// ยังไม่ตัดสต็อก คืนเฉพาะยอดที่จองไว้
+		if err := settleReservations(tx, shipment.ShipmentID, "Released"); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := reverseShipmentSerials(tx, *shipment); err != nil {
+			return nil, err
+		}
+		var err error
+		reversals, err = reverseDocumentStock(tx, nil, "Shipment", shipment.ShipmentID,
+			status+" "+shipment.ShipmentNumber+": "+reason, username)
+		if err != nil {
+			return nil, err
+		}
+		if err := restoreShipmentLots(tx, shipment.ShipmentID); err != nil {
+			return nil, err
+		}
+		if err := restoreShipmentLocations(tx, shipment.ShipmentID, username); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	shipment.Status = status
+	shipment.CancelReason = reason
+	shipment.CancelledBy = username
+	shipment.CancelledAt = &now
+	shipment.UpdatedAt = now
+	return reversals, tx.Omit("ShipmentItems").Save(shipment).Error
 }
 
-// อัพเดตสถานะของ Shipment ที่มีสถานะ Approved ให้เป็น Completed แบบอัตโนมัต
-func AutoUpdateShipments(db *gorm.DB) error {
-	var shipments []Models.Shipment
+// ยกเลิก Shipment (อนุมัติหรือส่งถึงแล้วก็ยกเลิกได้ โดยลงรายการกลับสต็อกทั้งสองสาขา)
+func CancelShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	reason, err := parseCancelReason(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	if err := db.Where("status = ?", "Approved").Find(&shipments).Error; err != nil {
-		return err
+	var shipment Models.Shipment
+	var reversals []StockReversal
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("shipment_id = ?", c.Params("id")).First(&shipment).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Shipment not found")
+		}
+		var err error
+		if reversals, err = reverseShipment(tx, &shipment, "Cancelled", reason, currentUsername(c)); err != nil {
+			return err
+		}
+		// ยกเลิก Request ที่ยังค้างอยู่ใน POS
+		return posDB.Model(&Request{}).Where("request_id = ? AND status = ?", shipment.ShipmentID, "Pending").Update("status", "Cancelled").Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to cancel shipment: " + err.Error()})
 	}
 
-	for _, shipment := range shipments {
-		if shipment.Status == "Completed" {
-			continue
+	return c.JSON(fiber.Map{"message": "Shipment cancelled", "shipment": shipment, "reversals": reversals})
+}
+
+// แก้ไข Shipment: Shipment เดิมถูกลงรายการกลับและเป็นสถานะ Amended
+// ฉบับแก้ไขเป็น Shipment ใหม่สถานะ Pending ที่คัดลอกรายการเดิม (ต้องส่งอนุมัติใหม่)
+func AmendShipment(db *gorm.DB, posDB *gorm.DB, c *fiber.Ctx) error {
+	reason, err := parseCancelReason(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var shipment, amendment Models.Shipment
+	var reversals []StockReversal
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("shipment_id = ?", c.Params("id")).First(&shipment).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "Shipment not found")
 		}
+
 		var shipmentItems []Models.ShipmentItem
-		if err := db.Where("shipment_id = ?", shipment.ShipmentID).Find(&shipmentItems).Error; err != nil {
-			continue
+		if err := tx.Where("shipment_id = ?", shipment.ShipmentID).Order("created_at").Find(&shipmentItems).Error; err != nil {
+			return err
 		}
 
-		if err := db.Transaction(func(tx *gorm.DB) error {
-
-			for _, item := range shipmentItems {
-				if err := tx.Model(&Inventory{}).
-					Where("inventory_id = ?", item.WarehouseInventoryID).
-					Where("quantity >= ?", item.Quantity).
-					Update("quantity", gorm.Expr("quantity - ?", item.Quantity)).Error; err != nil {
-					return fmt.Errorf("not enough inventory for WarehouseInventoryID %s", item.WarehouseInventoryID)
-				}
-			}
+		var err error
+		if reversals, err = reverseShipment(tx, &shipment, "Amended", reason, currentUsername(c)); err != nil {
+			return err
+		}
+		if err := posDB.Model(&Request{}).Where("request_id = ? AND status = ?", shipment.ShipmentID, "Pending").Update("status", "Cancelled").Error; err != nil {
+			return err
+		}
 
-			shipment.Status = "Completed"
-			shipment.UpdatedAt = time.Now()
-			if err := tx.Save(&shipment).Error; err != nil {
-				return err
-			}
+		items := make([]ShipmentItemInput, 0, len(shipmentItems))
+		for _, item := range shipmentItems {
+			items = append(items, ShipmentItemInput{
+				WarehouseInventoryID: item.WarehouseInventoryID,
+				PosInventoryID:       item.PosInventoryID,
+				ProductUnitID:        item.ProductUnitID,
+				Quantity:             item.Quantity,
+			})
+		}
+		if amendment, err = createShipment(tx, posDB, shipment.FromBranchID, shipment.ToBranchID, items); err != nil {
+			return err
+		}
 
-			return nil
-		}); err != nil {
-			continue
+		amendment.AmendedFromID = &shipment.ShipmentID
+		if err := tx.Model(&amendment).Update("amended_from_id", shipment.ShipmentID).Error; err != nil {
+			return err
 		}
+		shipment.AmendedToID = &amendment.ShipmentID
+		return tx.Model(&shipment).Update("amended_to_id", amendment.ShipmentID).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to amend shipment: " + err.Error()})
 	}
 
-	return nil
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   "Shipment amended",
+		"shipment":  shipment,
+		"amendment": amendment,
+		"reversals": reversals,
+	})
 }
 
 // แคช RequestID ที่หาไม่เจอในรอบก่อนหน้า
 var notFoundRequests = make(map[uuid.UUID]bool)
 
+// แคช RequestID ที่ซิงค์ไม่สำเร็จด้วยข้อผิดพลาดที่ลองใหม่ก็ไม่ผ่าน (Shipment ถูกทำเครื่องหมายให้ตรวจสอบแล้ว)
+var failedRequests = make(map[uuid.UUID]bool)
+
+// หมายเหตุบน Shipment ที่ POS รับสินค้าแล้วแต่ยังอนุมัติไม่ครบ
+const posReceivedBeforeApproval = "received by POS before approval was completed"
+
+// ทำเครื่องหมายให้ Shipment ต้องมีคนตรวจสอบ (ดูได้จาก review_note)
+func flagShipmentForReview(tx *gorm.DB, shipmentID, note string) error {
+	return tx.Model(&Models.Shipment{}).Where("shipment_id = ?", shipmentID).
+		Updates(map[string]interface{}{"review_note": note, "updated_at": time.Now()}).Error
+}
+
 // อัปเดตสถานะของ Request ใน Warehouse ให้ตรงกับ POS
 func SyncRequestStatusWithWarehouse(db *gorm.DB, posDB *gorm.DB) error {
 	var requests []Request
@@ -280,8 +576,8 @@ func SyncRequestStatusWithWarehouse(db *gorm.DB, posDB *gorm.DB) error {
 	}
 
 	for _, request := range requests {
-		// ข้าม RequestID ที่เคยหาไม่เจอในรอบก่อนหน้า
-		if notFoundRequests[request.RequestID] {
+		// ข้าม RequestID ที่เคยหาไม่เจอ หรือที่ซิงค์ไม่ได้และรอคนตรวจสอบ
+		if notFoundRequests[request.RequestID] || failedRequests[request.RequestID] {
 			continue
 		}
 
@@ -293,34 +589,49 @@ func SyncRequestStatusWithWarehouse(db *gorm.DB, posDB *gorm.DB) error {
 			continue
 		}
 
+		// POS รับสินค้าก่อนที่ Shipment จะอนุมัติครบ: ไม่ข้ามขั้นการอนุมัติ แต่ทำเครื่องหมายให้ผู้อนุมัติตรวจสอบ
+		// และคง Request ไว้ที่ complete เพื่อปิด Shipment ในรอบถัดไปหลังอนุมัติผ่าน workflow
+		if request.Status == "complete" && shipment.Status == "Pending" {
+			if shipment.ReviewNote != posReceivedBeforeApproval {
+				if err := flagShipmentForReview(db, shipment.ShipmentID, posReceivedBeforeApproval); err != nil {
+					log.Printf("Failed to flag shipment %s for review: %v\n", shipment.ShipmentID, err)
+				}
+			}
+			continue
+		}
+
 		// ดำเนินการอัปเดตตามปกติ
 		if err := db.Transaction(func(tx *gorm.DB) error {
-			if shipment.Status == "Completed" {
-				return nil
-			}
-			switch request.Status {
-			case "complete":
-				shipment.Status = "Approved"
+			switch {
+			// Shipment ที่ปิดไปแล้ว ไม่ต้องปรับสต็อกตาม POS อีก
+			case shipment.Status == "Rejected" || shipment.Status == "Cancelled" || shipment.Status == "Amended":
+
+			// สต็อกถูกตัดไปแล้วตอนอนุมัติ เหลือแค่รับซีเรียลและปิด Shipment
+			// (Completed = ปิดไปก่อนหน้าโดยไม่ได้รับซีเรียล ให้รับซีเรียลที่ค้าง InTransit)
+			case request.Status == "complete" && (shipment.Status == "Approved" || shipment.Status == "Completed"):
+				if err := settleShipmentSerials(tx, shipment, true); err != nil {
+					return fmt.Errorf("failed to receive serial numbers: %w", err)
+				}
+				shipment.Status = "Completed"
+				shipment.ReviewNote = ""
+				shipment.UpdatedAt = time.Now()
 				if err := tx.Save(&shipment).Error; err != nil {
 					return fmt.Errorf("failed to update shipment status: %v", err)
 				}
 
-				if err := tx.Model(&Inventory{}).
-					Where("branch_id = ? AND product_id = ?", request.FromBranchID, request.ProductID).
-					Update("quantity", gorm.Expr("quantity - ?", request.Quantity)).Error; err != nil {
-					return fmt.Errorf("failed to update source inventory: %v", err)
+			// POS ปฏิเสธ Shipment ที่ตัดสต็อกไปแล้ว: ลงรายการกลับสต็อก ล็อต Bin และซีเรียล
+			// (Completed = ปิดไปก่อนหน้าโดยยังไม่ได้รับการยืนยันจาก POS)
+			case request.Status == "reject" && (shipment.Status == "Approved" || shipment.Status == "Completed"):
+				if _, err := reverseShipment(tx, &shipment, "Rejected", "rejected by POS", "system"); err != nil {
+					return fmt.Errorf("failed to reverse shipment: %w", err)
 				}
 
-				if err := tx.Model(&Inventory{}).
-					Where("branch_id = ? AND product_id = ?", request.ToBranchID, request.ProductID).
-					Update("quantity", gorm.Expr("quantity + ?", request.Quantity)).Error; err != nil {
-					return fmt.Errorf("failed to update destination inventory: %v", err)
+			case request.Status == "reject":
+				if err := cancelApproval(tx, "Shipment", shipment.ShipmentID); err != nil {
+					return fmt.Errorf("failed to close pending approval: %v", err)
 				}
-
-			case "reject":
-				shipment.Status = "Rejected"
-				if err := tx.Save(&shipment).Error; err != nil {
-					return fmt.Errorf("failed to update shipment status: %v", err)
+				if err := rejectShipment(tx, shipment.ShipmentID); err != nil {
+					return fmt.Errorf("failed to reject shipment: %v", err)
 				}
 			}
 
@@ -332,6 +643,14 @@ func SyncRequestStatusWithWarehouse(db *gorm.DB, posDB *gorm.DB) error {
 			return nil
 		}); err != nil {
 			log.Printf("Error syncing request %s with warehouse: %v\n", request.RequestID, err)
+
+			// ข้อผิดพลาดทางธุรกิจ (เช่น ซีเรียลถูกย้ายไปแล้ว) จะเกิดซ้ำทุกรอบ: ทำเครื่องหมายให้ตรวจสอบและหยุดลองใหม่
+			if errorStatus(err, fiber.StatusInternalServerError) < fiber.StatusInternalServerError {
+				if err := flagShipmentForReview(db, shipment.ShipmentID, "POS sync failed: "+err.Error()); err != nil {
+					log.Printf("Failed to flag shipment %s for review: %v\n", shipment.ShipmentID, err)
+				}
+				failedRequests[request.RequestID] = true
+			}
 			continue
 		}
 
@@ -350,8 +669,28 @@ func StartSyncScheduler(db *gorm.DB, posDB *gorm.DB) {
 		_ = SyncRequestStatusWithWarehouse(db, posDB)
 	})
 
-	scheduler.Every(13).Seconds().Do(func() {
-		_ = AutoUpdateShipments(db)
+	scheduler.Every(5).Minutes().Do(func() {
+		if err := ApplyEffectivePrices(db); err != nil {
+			log.Println("❌ Failed to apply effective prices:", err)
+		}
+	})
+
+	scheduler.Every(1).Hour().Do(func() {
+		if err := GenerateReorderSuggestions(db); err != nil {
+			log.Println("❌ Failed to generate reorder suggestions:", err)
+		}
+	})
+
+	scheduler.Every(1).Day().At("23:55").Do(func() {
+		if err := TakeInventorySnapshot(db); err != nil {
+			log.Println("❌ Failed to take inventory snapshot:", err)
+		}
+	})
+
+	scheduler.Every(30).Minutes().Do(func() {
+		if err := GenerateReplenishmentProposals(db, posDB); err != nil {
+			log.Println("❌ Failed to generate replenishment proposals:", err)
+		}
 	})
 
 	scheduler.StartAsync()
@@ -359,8 +698,14 @@ func StartSyncScheduler(db *gorm.DB, posDB *gorm.DB) {
 
 // ดึงข้อมูล Shipment ทั้งหมด
 func LookShipments(db *gorm.DB, c *fiber.Ctx) error {
+	query := db
+	// needs_review=true: เฉพาะ Shipment ที่ต้องมีคนตรวจสอบ
+	if c.QueryBool("needs_review") {
+		query = query.Where("review_note <> ''")
+	}
+
 	var shipments []Models.Shipment
-	if err := db.Find(&shipments).Error; err != nil {
+	if err := query.Find(&shipments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch shipments",
 		})
@@ -378,18 +723,36 @@ func FindShipment(db *gorm.DB, c *fiber.Ctx) error {
 	if err := db.Where("shipment_id = ?", id).First(&shipment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shipment not found"})
 	}
-	return c.JSON(fiber.Map{"Shipment": shipment})
+	return c.JSON(fiber.Map{"Shipment": shipment, "approval": approvalHistory(db, "Shipment", shipment.ShipmentID)})
 }
 
-// ลบ Shipment
+// ลบ Shipment (เฉพาะที่ยังไม่เคยเปลี่ยนยอดสต็อก ที่เหลือต้องใช้ POST /Shipments/:id/cancel)
 func DeleteShipment(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
 	var shipment Models.Shipment
 	if err := db.Where("shipment_id = ?", id).First(&shipment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shipment not found"})
 	}
-	if err := db.Delete(&shipment).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete shipment: " + err.Error()})
+	if shipment.Status == "Approved" || shipment.Status == "Completed" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Shipment has affected stock; use POST /Shipments/:id/cancel instead"})
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		affected, err := documentAffectedStock(tx, movementSource{DocumentType: "Shipment", DocumentIDs: []string{shipment.ShipmentID}})
+		if err != nil {
+			return err
+		}
+		if affected {
+			return fiber.NewError(fiber.StatusConflict, "Shipment has affected stock; use POST /Shipments/:id/cancel instead")
+		}
+		if err := settleReservations(tx, shipment.ShipmentID, "Released"); err != nil {
+			return err
+		}
+		if err := cancelApproval(tx, "Shipment", shipment.ShipmentID); err != nil {
+			return err
+		}
+		return tx.Delete(&shipment).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to delete shipment: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"Deleted": "Succeed"})
 }
@@ -404,15 +767,39 @@ func ShipmentRoutes(app *fiber.App, db *gorm.DB, posDB *gorm.DB) {
 		return FindShipment(db, c)
 	})
 
+	app.Get("/Shipments/:id/pdf", func(c *fiber.Ctx) error {
+		return GetShipmentPDF(db, c)
+	})
+
 	app.Post("/Shipments", func(c *fiber.Ctx) error {
-		return AddShipment(db, posDB, c)
+		return AddShipment(auditDB(db, c), posDB, c)
+	})
+
+	app.Post("/Shipments/:id/submit", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return SubmitShipment(auditDB(db, c), c)
+	})
+
+	app.Post("/Shipments/:id/approve", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondDocumentApproval(auditDB(db, c), c, "Shipment", c.Params("id"), true)
+	})
+
+	app.Post("/Shipments/:id/reject", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return respondDocumentApproval(auditDB(db, c), c, "Shipment", c.Params("id"), false)
+	})
+
+	app.Post("/Shipments/:id/cancel", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CancelShipment(auditDB(db, c), posDB, c)
+	})
+
+	app.Post("/Shipments/:id/amend", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AmendShipment(auditDB(db, c), posDB, c)
 	})
 
-	app.Put("/Shipments/:id", func(c *fiber.Ctx) error {
-		return UpdateShipment(db, posDB, c)
+	app.Put("/Shipments/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return UpdateShipment(auditDB(db, c), posDB, c)
 	})
 
 	app.Delete("/Shipments/:id", func(c *fiber.Ctx) error {
-		return DeleteShipment(db, c)
+		return DeleteShipment(auditDB(db, c), c)
 	})
 }
@@ -2,7 +2,6 @@ package Func
 
 import (
 	"Api/Models"
-	"fmt"
 	"log"
 	"time"
 
@@ -30,6 +29,9 @@ func AddShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 	}
 
 	log.Println("Received Request:", req)
+	if req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must be greater than 0"})
+	}
 
 	var existingShipment Models.Shipment
 	err := db.Where("shipment_id = ?", req.ShipmentID).First(&existingShipment).Error
@@ -38,15 +40,21 @@ func AddShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 			log.Println("Shipment not found, creating new shipment:", req.ShipmentID)
 
 			newShipment := Models.Shipment{
-				ShipmentID:     req.ShipmentID, // ใช้ ShipmentID เดิม
-				ShipmentNumber: fmt.Sprintf("SH-%d", time.Now().UnixNano()),
-				FromBranchID:   req.FromBranchID,
-				ToBranchID:     req.ToBranchID,
-				Status:         "Pending",
-				ShipmentDate:   time.Now(),
+				ShipmentID:   req.ShipmentID, // ใช้ ShipmentID เดิม
+				FromBranchID: req.FromBranchID,
+				ToBranchID:   req.ToBranchID,
+				Status:       "Pending",
+				ShipmentDate: time.Now(),
 			}
 
-			if err := db.Create(&newShipment).Error; err != nil {
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				shipmentNumber, err := NextDocumentNumber(tx, "Shipment", req.FromBranchID, newShipment.ShipmentDate)
+				if err != nil {
+					return err
+				}
+				newShipment.ShipmentNumber = shipmentNumber
+				return tx.Create(&newShipment).Error
+			}); err != nil {
 				log.Println("Error creating new shipment:", err)
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create shipment"})
 			}
@@ -57,6 +65,9 @@ func AddShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Error checking existing shipment"})
 		}
 	}
+	if err := editableShipment(db, existingShipment.ShipmentID); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	shipmentItem := Models.ShipmentItem{
 		ShipmentID:           existingShipment.ShipmentID,
@@ -67,9 +78,15 @@ func AddShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 		Status:               req.Status,
 	}
 
-	if err := db.Create(&shipmentItem).Error; err != nil {
+	// สร้างรายการพร้อมจองสต็อก (ยอดที่ยังสัญญาได้ไม่พอ = ไม่สร้างรายการ)
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&shipmentItem).Error; err != nil {
+			return err
+		}
+		return reserveStock(tx, shipmentItem)
+	}); err != nil {
 		log.Println("Error creating shipment item:", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create shipment item"})
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to create shipment item: " + err.Error()})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Shipment and item added successfully", "data": shipmentItem})
@@ -94,6 +111,22 @@ func FindShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"data": shipmentItem})
 }
 
+// ตรวจว่า Shipment ยังแก้ไขรายการได้ (เฉพาะ Pending ที่ยังไม่ส่งอนุมัติ, ที่อนุมัติแล้วต้องยกเลิกหรือแก้ไขผ่าน /Shipments/:id/cancel, /amend)
+func editableShipment(db *gorm.DB, shipmentID string) error {
+	var shipment Models.Shipment
+	if err := db.Where("shipment_id = ?", shipmentID).First(&shipment).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Shipment not found")
+	}
+	if shipment.Status != "Pending" {
+		return fiber.NewError(fiber.StatusConflict, "Cannot change items of a "+shipment.Status+" shipment")
+	}
+	// ส่งอนุมัติแล้ว มูลค่าที่ใช้เลือกกฎการอนุมัติถูกคำนวณไปแล้ว ต้องถอนคำขอ (ปฏิเสธ) ก่อนแก้รายการ
+	if _, err := pendingApprovalID(db, "Shipment", shipmentID); err == nil {
+		return fiber.NewError(fiber.StatusConflict, "Shipment has been submitted for approval; reject it before changing items")
+	}
+	return nil
+}
+
 // ลบข้อมูล ShipmentItem ตาม ID
 func DeleteShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -101,7 +134,15 @@ func DeleteShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 	if err := db.Where("shipment_list_id = ?", id).First(&shipmentItem).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Shipment item not found"})
 	}
-	if err := db.Delete(&shipmentItem).Error; err != nil {
+	if err := editableShipment(db, shipmentItem.ShipmentID); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseItemReservation(tx, shipmentItem.ShipmentListID); err != nil {
+			return err
+		}
+		return tx.Delete(&shipmentItem).Error
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete shipment item: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Shipment item deleted successfully"})
@@ -126,12 +167,33 @@ func UpdateShipmentItem(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
 	}
 
+	if req.Quantity <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "quantity must be greater than 0"})
+	}
+	if err := editableShipment(db, shipmentItem.ShipmentID); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.ShipmentID != shipmentItem.ShipmentID {
+		if err := editableShipment(db, req.ShipmentID); err != nil {
+			return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
 	shipmentItem.ShipmentID = req.ShipmentID
 	shipmentItem.ProductUnitID = req.ProductUnitID
 	shipmentItem.Quantity = req.Quantity
 
-	if err := db.Save(&shipmentItem).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update shipment item: " + err.Error()})
+	// ปล่อยยอดจองเดิมแล้วจองใหม่ตามจำนวนที่แก้ (ยอดที่ยังสัญญาได้ไม่พอ = ไม่แก้ไข)
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseItemReservation(tx, shipmentItem.ShipmentListID); err != nil {
+			return err
+		}
+		if err := tx.Save(&shipmentItem).Error; err != nil {
+			return err
+		}
+		return reserveStock(tx, shipmentItem)
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to update shipment item: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Shipment item updated successfully", "data": shipmentItem})
 }
@@ -146,14 +208,14 @@ func ShipmentItemRoutes(app *fiber.App, db *gorm.DB) {
 	})
 
 	app.Post("/ShipmentItems", func(c *fiber.Ctx) error {
-		return AddShipmentItem(db, c)
+		return AddShipmentItem(auditDB(db, c), c)
 	})
 
 	app.Put("/ShipmentItems/:id", func(c *fiber.Ctx) error {
-		return UpdateShipmentItem(db, c)
+		return UpdateShipmentItem(auditDB(db, c), c)
 	})
 
 	app.Delete("/ShipmentItems/:id", func(c *fiber.Ctx) error {
-		return DeleteShipmentItem(db, c)
+		return DeleteShipmentItem(auditDB(db, c), c)
 	})
 }
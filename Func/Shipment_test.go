@@ -0,0 +1,99 @@
+package Func
+
+import (
+	"Api/Models"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// เพิ่มตารางที่ approveShipment/reverseShipment และ Request ฝั่ง POS ใช้ (ใช้ฐานข้อมูลเดียวกันแทน POS)
+func openShipmentTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := openStockTestDB(t)
+	if err := db.AutoMigrate(
+		&Models.Product{}, &Models.PriceList{}, &Models.ProductPrice{},
+		&Models.Shipment{}, &Models.ShipmentItem{}, &Models.StockReservation{},
+		&Models.CostLayer{}, &Models.CostConsumption{}, &Models.InventoryCost{},
+		&Models.InventoryLot{}, &Models.ShipmentLotPick{},
+		&Models.Location{}, &Models.LocationStock{}, &Models.LocationMove{},
+		&Models.SerialNumber{}, &Models.SerialMovement{},
+		&Models.ApprovalRequest{}, &Models.ApprovalTask{},
+		&Request{},
+	); err != nil {
+		t.Fatalf("migrate shipment tables: %v", err)
+	}
+	return db
+}
+
+// POS ปฏิเสธ Shipment ที่ตัดสต็อกไปแล้ว ต้องคืนสต็อกสาขาต้นทางและปิดเป็น Rejected
+func TestSyncPosRejectReversesDispatchedShipment(t *testing.T) {
+	for _, status := range []string{"Approved", "Completed"} {
+		t.Run(status, func(t *testing.T) {
+			db := openShipmentTestDB(t)
+			inventory := createStockTestInventory(t, db, 10, false)
+
+			shipment := Models.Shipment{
+				ShipmentNumber: "SH-TEST",
+				FromBranchID:   inventory.BranchID,
+				ToBranchID:     uuid.New().String(),
+				Status:         "Pending",
+			}
+			if err := db.Omit("ShipmentItems").Create(&shipment).Error; err != nil {
+				t.Fatalf("create shipment: %v", err)
+			}
+			if err := db.Create(&Models.ShipmentItem{
+				ShipmentID:           shipment.ShipmentID,
+				WarehouseInventoryID: inventory.InventoryID,
+				PosInventoryID:       uuid.New().String(),
+				Quantity:             4,
+			}).Error; err != nil {
+				t.Fatalf("create shipment item: %v", err)
+			}
+
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := approveShipment(tx, shipment.ShipmentID, "tester", nil)
+				return err
+			}); err != nil {
+				t.Fatalf("approve shipment: %v", err)
+			}
+			if got := stockTestQuantity(t, db, inventory.InventoryID); got != 6 {
+				t.Fatalf("quantity after approval = %d, want 6", got)
+			}
+			if status == "Completed" {
+				if err := db.Model(&Models.Shipment{}).Where("shipment_id = ?", shipment.ShipmentID).Update("status", status).Error; err != nil {
+					t.Fatalf("complete shipment: %v", err)
+				}
+			}
+
+			request := Request{RequestID: uuid.MustParse(shipment.ShipmentID), FromBranchID: shipment.FromBranchID, ToBranchID: shipment.ToBranchID, Status: "reject"}
+			if err := db.Create(&request).Error; err != nil {
+				t.Fatalf("create POS request: %v", err)
+			}
+
+			if err := SyncRequestStatusWithWarehouse(db, db); err != nil {
+				t.Fatalf("sync: %v", err)
+			}
+
+			if err := db.Where("shipment_id = ?", shipment.ShipmentID).First(&shipment).Error; err != nil {
+				t.Fatalf("reload shipment: %v", err)
+			}
+			if shipment.Status != "Rejected" {
+				t.Fatalf("shipment status = %s, want Rejected", shipment.Status)
+			}
+			if got := stockTestQuantity(t, db, inventory.InventoryID); got != 10 {
+				t.Fatalf("quantity after POS reject = %d, want 10", got)
+			}
+			if _, total := stockTestMovementTotal(t, db, inventory.InventoryID); total != 0 {
+				t.Fatalf("movement total = %d, want 0", total)
+			}
+			if err := db.Where("request_id = ?", request.RequestID).First(&request).Error; err != nil {
+				t.Fatalf("reload POS request: %v", err)
+			}
+			if request.Status != "Done" {
+				t.Fatalf("POS request status = %s, want Done", request.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ยอดคงเหลือของ Inventory ณ เวลาที่ต้องการ
+type inventoryBalance struct {
+	InventoryID string
+	ProductID   string
+	BranchID    string
+	Quantity    int
+	UnitCost    float64
+}
+
+// ต้นทุนต่อหน่วยที่ใช้คิดมูลค่าใน snapshot (ถัวเฉลี่ยถ้ามี ไม่เช่นนั้นใช้ราคาทุนปัจจุบัน)
+func snapshotUnitCost(db *gorm.DB, inventory Models.Inventory) float64 {
+	var cost Models.InventoryCost
+	if err := db.Where("inventory_id = ?", inventory.InventoryID).First(&cost).Error; err == nil {
+		return cost.AverageCost
+	}
+	return currentUnitCost(db, inventory.ProductID, inventory.BranchID)
+}
+
+// บันทึก snapshot ยอดคงเหลือของทุก Inventory (รันซ้ำในวันเดียวกันจะเขียนทับของวันนั้น)
+// ล็อก Inventory แบบ SHARE ระหว่างอ่าน เพื่อให้ยอดที่อ่านได้ตรงกับ movement ก่อน taken_at พอดี
+// (การเปลี่ยนยอดที่ค้างอยู่จะ commit ก่อน ส่วนที่เริ่มใหม่จะรอจน snapshot เสร็จ)
+func TakeInventorySnapshot(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`LOCK TABLE "Inventory" IN SHARE MODE`).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+		var inventories []Models.Inventory
+		if err := tx.Find(&inventories).Error; err != nil {
+			return err
+		}
+
+		for _, inventory := range inventories {
+			unitCost := snapshotUnitCost(tx, inventory)
+			snapshot := Models.InventorySnapshot{
+				SnapshotDate: date,
+				InventoryID:  inventory.InventoryID,
+				ProductID:    inventory.ProductID,
+				BranchID:     inventory.BranchID,
+				Quantity:     inventory.Quantity,
+				UnitCost:     unitCost,
+				Value:        float64(inventory.Quantity) * unitCost,
+				TakenAt:      now,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "snapshot_date"}, {Name: "inventory_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"quantity", "unit_cost", "value", "taken_at"}),
+			}).Create(&snapshot).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// คำนวณยอดคงเหลือ ณ เวลาที่ต้องการ จาก snapshot ล่าสุดก่อนเวลานั้น + movement ที่เกิดหลัง snapshot
+// Inventory ที่ไม่อยู่ใน snapshot (หรือยังไม่มี snapshot เลย) ย้อนจากยอดปัจจุบันด้วย movement ที่เกิดหลังเวลานั้น
+func inventoryBalancesAsOf(db *gorm.DB, asOf time.Time) ([]inventoryBalance, error) {
+	var takenAt *time.Time
+	if err := db.Model(&Models.InventorySnapshot{}).
+		Select("MAX(taken_at)").
+		Where("taken_at <= ?", asOf).
+		Scan(&takenAt).Error; err != nil {
+		return nil, err
+	}
+
+	balances := map[string]*inventoryBalance{}
+	var order []string
+	add := func(inventoryID, productID, branchID string, quantity int, unitCost float64) {
+		balance, ok := balances[inventoryID]
+		if !ok {
+			balance = &inventoryBalance{InventoryID: inventoryID, ProductID: productID, BranchID: branchID, UnitCost: unitCost}
+			balances[inventoryID] = balance
+			order = append(order, inventoryID)
+		}
+		balance.Quantity += quantity
+	}
+
+	movementQuery := func() *gorm.DB {
+		return db.Model(&Models.InventoryMovement{}).
+			Select("inventory_id, product_id, branch_id, SUM(quantity) AS quantity").
+			Group("inventory_id, product_id, branch_id")
+	}
+
+	// ยอดจาก snapshot + movement ระหว่าง snapshot ถึง as_of
+	if takenAt != nil {
+		var snapshots []Models.InventorySnapshot
+		if err := db.Where("taken_at = ?", *takenAt).Find(&snapshots).Error; err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			add(snapshot.InventoryID, snapshot.ProductID, snapshot.BranchID, snapshot.Quantity, snapshot.UnitCost)
+		}
+
+		var movements []inventoryBalance
+		if err := movementQuery().Where("created_at > ? AND created_at <= ?", *takenAt, asOf).Scan(&movements).Error; err != nil {
+			return nil, err
+		}
+		for _, movement := range movements {
+			if _, ok := balances[movement.InventoryID]; ok {
+				add(movement.InventoryID, movement.ProductID, movement.BranchID, movement.Quantity, 0)
+			}
+		}
+	}
+
+	// Inventory ที่สร้างก่อน as_of แต่ไม่อยู่ใน snapshot: ยอดปัจจุบัน - movement หลัง as_of
+	var inventories []Models.Inventory
+	if err := db.Where("created_at <= ?", asOf).Find(&inventories).Error; err != nil {
+		return nil, err
+	}
+	var rolledBack []string
+	for _, inventory := range inventories {
+		if _, ok := balances[inventory.InventoryID]; ok {
+			continue
+		}
+		add(inventory.InventoryID, inventory.ProductID, inventory.BranchID, inventory.Quantity, snapshotUnitCost(db, inventory))
+		rolledBack = append(rolledBack, inventory.InventoryID)
+	}
+	if len(rolledBack) > 0 {
+		var movements []inventoryBalance
+		if err := movementQuery().Where("created_at > ? AND inventory_id IN ?", asOf, rolledBack).Scan(&movements).Error; err != nil {
+			return nil, err
+		}
+		for _, movement := range movements {
+			add(movement.InventoryID, movement.ProductID, movement.BranchID, -movement.Quantity, 0)
+		}
+	}
+
+	result := make([]inventoryBalance, 0, len(order))
+	for _, inventoryID := range order {
+		result = append(result, *balances[inventoryID])
+	}
+	return result, nil
+}
+
+// อ่านค่า as_of (วันที่) เป็นเวลาสิ้นสุดของวันนั้น
+func parseAsOf(c *fiber.Ctx) (*time.Time, error) {
+	value := c.Query("as_of")
+	if value == "" {
+		return nil, nil
+	}
+	date, err := parseDate(value)
+	if err != nil {
+		return nil, err
+	}
+	asOf := date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	return &asOf, nil
+}
+
+// ข้อมูลสินค้า (รวมที่ถูกเก็บถาวร) ของยอดคงเหลือที่คำนวณได้
+func balanceProducts(db *gorm.DB, balances []inventoryBalance) (map[string]Models.Product, error) {
+	ids := make([]string, 0, len(balances))
+	for _, balance := range balances {
+		ids = append(ids, balance.ProductID)
+	}
+
+	var products []Models.Product
+	if err := db.Unscoped().Select("product_id, product_name, description").Where("product_id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	result := map[string]Models.Product{}
+	for _, product := range products {
+		result[product.ProductID] = product
+	}
+	return result, nil
+}
+
+// สรุปยอดคงเหลือรายสินค้า ณ วันที่ as_of
+func inventorySummaryAsOf(db *gorm.DB, c *fiber.Ctx, asOf time.Time) error {
+	balances, err := inventoryBalancesAsOf(db, asOf)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory summary"})
+	}
+	products, err := balanceProducts(db, balances)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory summary"})
+	}
+
+	type summaryRow struct {
+		ProductID   string  `json:"product_id"`
+		ProductName string  `json:"product_name"`
+		Description string  `json:"description"`
+		Quantity    int     `json:"quantity"`
+		Value       float64 `json:"value"`
+	}
+	var rows []*summaryRow
+	byProduct := map[string]*summaryRow{}
+	for _, balance := range balances {
+		product, ok := products[balance.ProductID]
+		if !ok {
+			continue
+		}
+		row, ok := byProduct[balance.ProductID]
+		if !ok {
+			row = &summaryRow{ProductID: product.ProductID, ProductName: product.ProductName, Description: product.Description}
+			byProduct[balance.ProductID] = row
+			rows = append(rows, row)
+		}
+		row.Quantity += balance.Quantity
+		row.Value += float64(balance.Quantity) * balance.UnitCost
+	}
+
+	return c.JSON(fiber.Map{"inventory_summary": rows, "as_of": asOf})
+}
+
+// สรุปยอดคงเหลือตามหมวดหมู่ ณ วันที่ as_of
+func inventoryByCategoryAsOf(db *gorm.DB, c *fiber.Ctx, asOf time.Time) error {
+	balances, err := inventoryBalancesAsOf(db, asOf)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory by category: " + err.Error()})
+	}
+	products, err := balanceProducts(db, balances)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory by category: " + err.Error()})
+	}
+
+	type detail struct {
+		ProductName string  `json:"product_name"`
+		Quantity    int     `json:"quantity"`
+		Value       float64 `json:"value"`
+	}
+	var categoryOrder []string
+	totals := map[string]int{}
+	values := map[string]float64{}
+	details := map[string][]detail{}
+	for _, balance := range balances {
+		product, ok := products[balance.ProductID]
+		if !ok {
+			continue
+		}
+		if _, ok := details[product.Description]; !ok {
+			categoryOrder = append(categoryOrder, product.Description)
+		}
+		value := float64(balance.Quantity) * balance.UnitCost
+		totals[product.Description] += balance.Quantity
+		values[product.Description] += value
+		details[product.Description] = append(details[product.Description], detail{ProductName: product.ProductName, Quantity: balance.Quantity, Value: value})
+	}
+
+	categories := make([]InventoryCategory, 0, len(categoryOrder))
+	for _, category := range categoryOrder {
+		raw, err := json.Marshal(details[category])
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory by category: " + err.Error()})
+		}
+		categories = append(categories, InventoryCategory{Category: category, TotalQuantity: totals[category], TotalValue: values[category], Details: raw})
+	}
+
+	return c.JSON(fiber.Map{"categories": categories, "as_of": asOf})
+}
+
+// บันทึก snapshot ทันที (ไม่ต้องรอ scheduler)
+func RunInventorySnapshot(db *gorm.DB, c *fiber.Ctx) error {
+	if err := TakeInventorySnapshot(db); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to take inventory snapshot: " + err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Inventory snapshot taken"})
+}
+
+// ดู snapshot ของวันที่ที่ต้องการ (กรองตามสาขาได้)
+func LookInventorySnapshots(db *gorm.DB, c *fiber.Ctx) error {
+	date, err := parseDate(c.Query("date", time.Now().Format("2006-01-02")))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date"})
+	}
+
+	query := db.Where("snapshot_date = ?", date.Format("2006-01-02"))
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var snapshots []Models.InventorySnapshot
+	if err := query.Find(&snapshots).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch inventory snapshots"})
+	}
+	return c.JSON(fiber.Map{"data": snapshots})
+}
+
+func SnapshotRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/inventory-snapshots", func(c *fiber.Ctx) error {
+		return LookInventorySnapshots(db, c)
+	})
+
+	app.Post("/inventory-snapshots", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RunInventorySnapshot(auditDB(db, c), c)
+	})
+}
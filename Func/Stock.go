@@ -0,0 +1,107 @@
+package Func
+
+import (
+	"Api/Models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InsufficientStockError สต็อกไม่พอสำหรับการตัดจ่าย
+type InsufficientStockError struct {
+	InventoryID string
+	OnHand      int
+	Requested   int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for inventory %s: on hand %d, requested %d", e.InventoryID, e.OnHand, e.Requested)
+}
+
+// StockChange การเปลี่ยนแปลงจำนวนของ Inventory หนึ่งรายการ
+type StockChange struct {
+	InventoryID  string
+	Delta        int // บวก = รับเข้า, ลบ = จ่ายออก
+	MovementType string
+	DocumentType string
+	DocumentID   string
+	Note         string
+	CreatedBy    string
+	IgnoreFreeze bool // ใช้ตอนลงผลตรวจนับ ซึ่งทำระหว่างที่ Inventory ถูก freeze อยู่
+}
+
+// สาขาที่อนุญาตให้สต็อกติดลบได้
+func branchAllowsNegativeStock(tx *gorm.DB, branchID string) bool {
+	var branch Models.Branches
+	if err := tx.Unscoped().Select("branch_id, allow_negative_stock").Where("branch_id = ?", branchID).First(&branch).Error; err != nil {
+		return false
+	}
+	return branch.AllowNegativeStock
+}
+
+// เปลี่ยนจำนวน Inventory (ทุกเส้นทางที่แก้ยอดสต็อกต้องผ่านฟังก์ชันนี้)
+// ล็อกแถวก่อนอ่านยอด ห้ามติดลบยกเว้นสาขาที่อนุญาต และบันทึก movement ทุกครั้ง
+func ApplyStockChange(tx *gorm.DB, change StockChange) (Models.Inventory, error) {
+	var inventory Models.Inventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_id = ?", change.InventoryID).
+		First(&inventory).Error; err != nil {
+		return inventory, fmt.Errorf("failed to find inventory: %s", change.InventoryID)
+	}
+
+	if change.Delta == 0 {
+		return inventory, nil
+	}
+
+	if !change.IgnoreFreeze {
+		if err := ensureNotFrozen(tx, inventory.InventoryID); err != nil {
+			return inventory, err
+		}
+	}
+
+	if inventory.Quantity+change.Delta < 0 && !branchAllowsNegativeStock(tx, inventory.BranchID) {
+		return inventory, &InsufficientStockError{InventoryID: inventory.InventoryID, OnHand: inventory.Quantity, Requested: -change.Delta}
+	}
+
+	inventory.Quantity += change.Delta
+	inventory.Version++
+	inventory.UpdatedAt = time.Now()
+	if err := tx.Model(&Models.Inventory{}).
+		Where("inventory_id = ?", inventory.InventoryID).
+		UpdateColumns(map[string]interface{}{"quantity": inventory.Quantity, "version": inventory.Version, "updated_at": inventory.UpdatedAt}).Error; err != nil {
+		return inventory, err
+	}
+
+	if err := recordMovement(tx, inventory, change.Delta, change.MovementType, change.DocumentType, change.DocumentID, change.Note, change.CreatedBy); err != nil {
+		return inventory, err
+	}
+	return inventory, nil
+}
+
+// สร้าง trigger กันสต็อกติดลบที่ระดับฐานข้อมูล (ตรงกับเงื่อนไขใน ApplyStockChange)
+// ใช้ trigger แทน CHECK (quantity >= 0) เพราะสาขาที่เปิด allow_negative_stock ต้องติดลบได้ ซึ่ง CHECK อ้างอิงตารางอื่นไม่ได้
+func EnsureStockGuards(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION inventory_quantity_guard() RETURNS trigger AS $$
+BEGIN
+	IF NEW.quantity < 0 AND NOT COALESCE(
+		(SELECT b.allow_negative_stock FROM "Branches" b WHERE b.branch_id::text = NEW.branch_id::text), false) THEN
+		RAISE EXCEPTION 'inventory % quantity % would be negative', NEW.inventory_id, NEW.quantity
+			USING ERRCODE = 'check_violation';
+	END IF;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS inventory_quantity_guard ON "Inventory"`,
+		`CREATE TRIGGER inventory_quantity_guard BEFORE INSERT OR UPDATE OF quantity, branch_id ON "Inventory"
+	FOR EACH ROW EXECUTE FUNCTION inventory_quantity_guard()`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package Func
+
+import (
+	"Api/Models"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// เปิดฐานข้อมูลทดสอบใน schema ชั่วคราว (ต้องตั้ง WAREHOUSE_TEST_DSN เช่น
+// "host=localhost port=5432 user=Admin password=1234 dbname=WarehouseDB sslmode=disable")
+func openStockTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("WAREHOUSE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("WAREHOUSE_TEST_DSN is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	schema := fmt.Sprintf("stock_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect to schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(&Models.Branches{}, &Models.Inventory{}, &Models.InventoryMovement{}, &Models.StockCount{}, &Models.StockCountLine{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := EnsureStockGuards(db); err != nil {
+		t.Fatalf("stock guards: %v", err)
+	}
+	return db
+}
+
+// สร้างสาขาและ Inventory ตั้งต้น
+func createStockTestInventory(t *testing.T, db *gorm.DB, quantity int, allowNegative bool) Models.Inventory {
+	t.Helper()
+	branch := Models.Branches{BName: "Test", Location: "Test", AllowNegativeStock: allowNegative}
+	if err := db.Create(&branch).Error; err != nil {
+		t.Fatalf("create branch: %v", err)
+	}
+	inventory := Models.Inventory{
+		ProductID: "00000000-0000-0000-0000-000000000001",
+		BranchID:  branch.BranchID.String(),
+		Quantity:  quantity,
+		Version:   1,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.Create(&inventory).Error; err != nil {
+		t.Fatalf("create inventory: %v", err)
+	}
+	return inventory
+}
+
+func stockTestQuantity(t *testing.T, db *gorm.DB, inventoryID string) int {
+	t.Helper()
+	var inventory Models.Inventory
+	if err := db.Where("inventory_id = ?", inventoryID).First(&inventory).Error; err != nil {
+		t.Fatalf("reload inventory: %v", err)
+	}
+	return inventory.Quantity
+}
+
+func stockTestMovementTotal(t *testing.T, db *gorm.DB, inventoryID string) (int64, int) {
+	t.Helper()
+	var result struct {
+		Count int64
+		Total int
+	}
+	if err := db.Model(&Models.InventoryMovement{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(quantity), 0) AS total").
+		Where("inventory_id = ?", inventoryID).
+		Scan(&result).Error; err != nil {
+		t.Fatalf("sum movements: %v", err)
+	}
+	return result.Count, result.Total
+}
+
+// รับเข้าและจ่ายออกพร้อมกันหลาย transaction ยอดสุดท้ายต้องตรงทุกหน่วย
+func TestApplyStockChangeConcurrentNoLostUpdates(t *testing.T) {
+	db := openStockTestDB(t)
+	inventory := createStockTestInventory(t, db, 100, false)
+
+	const workers = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers; i++ {
+		for _, delta := range []int{3, -2} {
+			wg.Add(1)
+			go func(delta int) {
+				defer wg.Done()
+				errs <- db.Transaction(func(tx *gorm.DB) error {
+					_, err := ApplyStockChange(tx, StockChange{
+						InventoryID:  inventory.InventoryID,
+						Delta:        delta,
+						MovementType: "Test",
+						DocumentType: "Test",
+						DocumentID:   inventory.InventoryID,
+					})
+					return err
+				})
+			}(delta)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("stock change failed: %v", err)
+		}
+	}
+
+	if got, want := stockTestQuantity(t, db, inventory.InventoryID), 100+workers*3-workers*2; got != want {
+		t.Fatalf("final quantity = %d, want %d", got, want)
+	}
+	if count, total := stockTestMovementTotal(t, db, inventory.InventoryID); count != workers*2 || total != workers {
+		t.Fatalf("movements = %d totalling %d, want %d totalling %d", count, total, workers*2, workers)
+	}
+}
+
+// ตัดจ่ายพร้อมกันเกินยอดคงเหลือ ต้องสำเร็จเท่าที่มีของและไม่ติดลบ
+func TestApplyStockChangeConcurrentNeverNegative(t *testing.T) {
+	db := openStockTestDB(t)
+	inventory := createStockTestInventory(t, db, 10, false)
+
+	const workers = 30
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, insufficient := 0, 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := ApplyStockChange(tx, StockChange{
+					InventoryID:  inventory.InventoryID,
+					Delta:        -1,
+					MovementType: "Test",
+					DocumentType: "Test",
+					DocumentID:   inventory.InventoryID,
+				})
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			var stockErr *InsufficientStockError
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.As(err, &stockErr):
+				insufficient++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 10 || insufficient != workers-10 {
+		t.Fatalf("succeeded = %d, insufficient = %d, want 10 and %d", succeeded, insufficient, workers-10)
+	}
+	if got := stockTestQuantity(t, db, inventory.InventoryID); got != 0 {
+		t.Fatalf("final quantity = %d, want 0", got)
+	}
+	if _, total := stockTestMovementTotal(t, db, inventory.InventoryID); total != -10 {
+		t.Fatalf("movement total = %d, want -10", total)
+	}
+}
+
+// PUT /Inventory/:id พร้อมกันด้วย version เดียวกัน ต้องสำเร็จเพียงครั้งเดียวและ movement ตรงกับยอด
+func TestUpdateInventoryConcurrentSingleWinner(t *testing.T) {
+	db := openStockTestDB(t)
+	inventory := createStockTestInventory(t, db, 50, false)
+
+	app := fiber.New()
+	app.Put("/Inventory/:id", func(c *fiber.Ctx) error {
+		return UpdateInventory(db, c)
+	})
+
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := map[int]int{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(quantity int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"product_id":%q,"branch_id":%q,"quantity":%d}`, inventory.ProductID, inventory.BranchID, quantity)
+			req := httptest.NewRequest(fiber.MethodPut, "/Inventory/"+inventory.InventoryID, strings.NewReader(body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			req.Header.Set(fiber.HeaderIfMatch, `"1"`)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Errorf("request: %v", err)
+				return
+			}
+			mu.Lock()
+			statuses[resp.StatusCode]++
+			mu.Unlock()
+		}(60 + i)
+	}
+	wg.Wait()
+
+	if statuses[fiber.StatusOK] != 1 || statuses[fiber.StatusPreconditionFailed] != workers-1 {
+		t.Fatalf("statuses = %v, want one 200 and %d 412", statuses, workers-1)
+	}
+	quantity := stockTestQuantity(t, db, inventory.InventoryID)
+	if quantity < 60 || quantity >= 60+workers {
+		t.Fatalf("final quantity = %d, want one of the submitted quantities", quantity)
+	}
+	if count, total := stockTestMovementTotal(t, db, inventory.InventoryID); count != 1 || total != quantity-50 {
+		t.Fatalf("movements = %d totalling %d, want 1 totalling %d", count, total, quantity-50)
+	}
+}
+
+// trigger ในฐานข้อมูลต้องกันการเขียนยอดติดลบที่ไม่ผ่าน ApplyStockChange ด้วย
+func TestInventoryQuantityGuard(t *testing.T) {
+	db := openStockTestDB(t)
+	strict := createStockTestInventory(t, db, 5, false)
+	lenient := createStockTestInventory(t, db, 5, true)
+
+	if err := db.Model(&Models.Inventory{}).Where("inventory_id = ?", strict.InventoryID).Update("quantity", -1).Error; err == nil {
+		t.Fatal("negative quantity was accepted on a branch that does not allow it")
+	}
+	if err := db.Model(&Models.Inventory{}).Where("inventory_id = ?", lenient.InventoryID).Update("quantity", -1).Error; err != nil {
+		t.Fatalf("negative quantity rejected on a branch that allows it: %v", err)
+	}
+
+	if _, err := ApplyStockChange(db, StockChange{InventoryID: lenient.InventoryID, Delta: -4, MovementType: "Test"}); err != nil {
+		t.Fatalf("stock change on a branch that allows negative stock: %v", err)
+	}
+	if got := stockTestQuantity(t, db, lenient.InventoryID); got != -5 {
+		t.Fatalf("final quantity = %d, want -5", got)
+	}
+}
@@ -0,0 +1,553 @@
+package Func
+
+import (
+	"Api/Authentication"
+	"Api/Models"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ต้นทุนต่อหน่วยปัจจุบันของสินค้าในสาขา (ใช้คำนวณมูลค่าส่วนต่าง ถ้าไม่มีราคาใช้ 0)
+func currentUnitCost(db *gorm.DB, productID, branchID string) float64 {
+	price, err := CurrentProductPrice(db, productID, branchID, "", time.Now())
+	if err != nil {
+		return 0
+	}
+	return price.CostPrice.InexactFloat64()
+}
+
+// สรุปผลการนับของรอบนับ
+func stockCountSummary(lines []Models.StockCountLine) fiber.Map {
+	summary := fiber.Map{}
+	statuses := map[string]int{}
+	varianceQty, varianceValue := 0, 0.0
+	for _, line := range lines {
+		statuses[line.Status]++
+		varianceQty += line.Variance
+		varianceValue += line.VarianceValue
+	}
+	summary["lines"] = len(lines)
+	summary["statuses"] = statuses
+	summary["variance_qty"] = varianceQty
+	summary["variance_value"] = varianceValue
+	return summary
+}
+
+// สร้างรอบตรวจนับ พร้อมบันทึกจำนวนที่คาดไว้ ณ เวลาเปิดรอบ
+func AddStockCount(db *gorm.DB, c *fiber.Ctx) error {
+	var req struct {
+		BranchID   string `json:"branch_id"`
+		CountType  string `json:"count_type"`
+		Category   string `json:"category"`
+		LocationID string `json:"location_id"`
+		Freeze     bool   `json:"freeze"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	if req.BranchID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "branch_id is required"})
+	}
+	if req.CountType == "" {
+		req.CountType = "Full"
+		if req.Category != "" || req.LocationID != "" {
+			req.CountType = "Cycle"
+		}
+	}
+	if req.CountType != "Full" && req.CountType != "Cycle" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "count_type must be Full or Cycle"})
+	}
+
+	var count Models.StockCount
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		countNumber, err := NextDocumentNumber(tx, "StockCount", req.BranchID, now)
+		if err != nil {
+			return err
+		}
+		count = Models.StockCount{
+			CountNumber: countNumber,
+			BranchID:    req.BranchID,
+			CountType:   req.CountType,
+			Category:    optionalString(req.Category),
+			LocationID:  optionalString(req.LocationID),
+			Freeze:      req.Freeze,
+			Status:      "Open",
+			CreatedBy:   currentUsername(c),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Create(&count).Error; err != nil {
+			return err
+		}
+
+		// รายการที่ต้องนับ: ตาม Bin ถ้าระบุ location ไม่เช่นนั้นนับทั้ง Inventory ของสาขา
+		var rows []struct {
+			InventoryID string
+			ProductID   string
+			Quantity    int
+		}
+		var query *gorm.DB
+		if req.LocationID != "" {
+			query = tx.Table(`"LocationStock" ls`).
+				Select("ls.inventory_id, ls.product_id, ls.quantity").
+				Joins(`JOIN "Product" p ON p.product_id = ls.product_id::uuid AND p.deleted_at IS NULL`).
+				Where("ls.location_id = ? AND ls.branch_id = ?", req.LocationID, req.BranchID)
+		} else {
+			query = tx.Table(`"Inventory" i`).
+				Select("i.inventory_id, i.product_id, i.quantity").
+				Joins(`JOIN "Product" p ON p.product_id = i.product_id::uuid AND p.deleted_at IS NULL`).
+				Where("i.branch_id = ?", req.BranchID)
+		}
+		if req.Category != "" {
+			query = query.Where("LOWER(p.description) = LOWER(?)", req.Category)
+		}
+		if err := query.Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "no inventory found for this count")
+		}
+
+		for _, row := range rows {
+			line := Models.StockCountLine{
+				CountID:     count.CountID,
+				InventoryID: row.InventoryID,
+				ProductID:   row.ProductID,
+				ExpectedQty: row.Quantity,
+				UnitCost:    currentUnitCost(tx, row.ProductID, req.BranchID),
+				Round:       1,
+				Status:      "Pending",
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			if err := tx.Create(&line).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to create stock count: " + err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "Stock count created successfully", "count_id": count.CountID, "count_number": count.CountNumber})
+}
+
+// ดูรอบตรวจนับทั้งหมด (กรองตามสาขา/สถานะได้)
+func LookStockCounts(db *gorm.DB, c *fiber.Ctx) error {
+	query := db.Model(&Models.StockCount{})
+	if branchID := c.Query("branch_id"); branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var counts []Models.StockCount
+	if err := query.Order("created_at DESC").Find(&counts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch stock counts"})
+	}
+	return c.JSON(fiber.Map{"data": counts})
+}
+
+// ดูรอบตรวจนับพร้อมรายการ ส่วนต่าง และมูลค่าผลกระทบ
+func FindStockCount(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var count Models.StockCount
+	if err := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("created_at")
+	}).Where("count_id = ?", id).First(&count).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Stock count not found"})
+	}
+
+	return c.JSON(fiber.Map{"data": count, "summary": stockCountSummary(count.Lines)})
+}
+
+// ดูเฉพาะรายการที่มีส่วนต่าง
+func GetStockCountVariances(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var lines []Models.StockCountLine
+	if err := db.Where("count_id = ? AND counted_qty IS NOT NULL AND variance <> 0", id).
+		Order("ABS(variance_value) DESC").
+		Find(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch variances"})
+	}
+
+	return c.JSON(fiber.Map{"variances": lines, "summary": stockCountSummary(lines)})
+}
+
+// โหลดรอบตรวจนับที่ยังเปิดอยู่ภายใน Transaction
+func openStockCount(tx *gorm.DB, id string) (Models.StockCount, error) {
+	var count Models.StockCount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("count_id = ?", id).First(&count).Error; err != nil {
+		return count, fiber.NewError(fiber.StatusNotFound, "stock count not found")
+	}
+	if count.Status != "Open" {
+		return count, fiber.NewError(fiber.StatusBadRequest, "stock count is not open")
+	}
+	return count, nil
+}
+
+// รับ/จ่ายสุทธิของรายการตรวจนับตั้งแต่เปิดรอบจนถึงเวลาที่นับ (รอบที่ freeze ไม่มีการเปลี่ยนยอดระหว่างนับ)
+// นับตาม Bin ใช้ประวัติ LocationMove ของ Bin นั้น ไม่เช่นนั้นใช้ movement ของ Inventory
+func stockCountDrift(tx *gorm.DB, count Models.StockCount, line Models.StockCountLine, countedAt time.Time) (int, error) {
+	if count.Freeze {
+		return 0, nil
+	}
+
+	var drift int
+	if count.LocationID != nil {
+		err := tx.Model(&Models.LocationMove{}).
+			Select(`COALESCE(SUM(CASE WHEN to_location_id = ? THEN quantity ELSE 0 END), 0)
+				- COALESCE(SUM(CASE WHEN from_location_id = ? THEN quantity ELSE 0 END), 0)`, *count.LocationID, *count.LocationID).
+			Where("inventory_id = ? AND created_at > ? AND created_at <= ?", line.InventoryID, count.CreatedAt, countedAt).
+			Scan(&drift).Error
+		return drift, err
+	}
+
+	err := tx.Model(&Models.InventoryMovement{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("inventory_id = ? AND created_at > ? AND created_at <= ?", line.InventoryID, count.CreatedAt, countedAt).
+		Where("NOT (document_type = ? AND document_id = ?)", "StockCount", count.CountID).
+		Scan(&drift).Error
+	return drift, err
+}
+
+// ประเมินผลการนับในรอบปัจจุบัน: ผู้นับหลายคนได้ค่าไม่ตรงกันต้องนับใหม่
+// ส่วนต่างเทียบกับยอดที่คาดไว้ ณ เวลาที่นับ (ยอดตอนเปิดรอบ + รับ/จ่ายระหว่างนั้น) เพราะตอน apply ส่วนต่างถูกบวกกับยอดปัจจุบัน
+func evaluateStockCountLine(tx *gorm.DB, count Models.StockCount, line *Models.StockCountLine) error {
+	var entries []Models.StockCountEntry
+	if err := tx.Where("line_id = ? AND round = ?", line.LineID, line.Round).Find(&entries).Error; err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	counted := entries[0].CountedQty
+	countedAt := entries[0].CreatedAt
+	for _, entry := range entries[1:] {
+		if entry.CreatedAt.After(countedAt) {
+			countedAt = entry.CreatedAt
+		}
+		if entry.CountedQty != counted {
+			line.Round++
+			line.CountedQty = nil
+			line.MovedQty = 0
+			line.Variance = 0
+			line.VarianceValue = 0
+			line.Status = "Recount"
+			line.UpdatedAt = time.Now()
+			return tx.Save(line).Error
+		}
+	}
+
+	drift, err := stockCountDrift(tx, count, *line, countedAt)
+	if err != nil {
+		return err
+	}
+	line.CountedQty = &counted
+	line.MovedQty = drift
+	line.Variance = counted - (line.ExpectedQty + drift)
+	line.VarianceValue = float64(line.Variance) * line.UnitCost
+	line.Status = "Counted"
+	line.UpdatedAt = time.Now()
+	return tx.Save(line).Error
+}
+
+// บันทึกผลการนับ (ผู้นับหลายคนส่งได้ ถ้าค่าไม่ตรงกันรายการจะถูกตั้งเป็น Recount)
+func RecordStockCountEntries(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req struct {
+		Entries []struct {
+			InventoryID string `json:"inventory_id"`
+			CountedQty  int    `json:"counted_qty"`
+		} `json:"entries"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.Entries) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "entries is required"})
+	}
+
+	counter := currentUsername(c)
+	var lines []Models.StockCountLine
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := openStockCount(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range req.Entries {
+			if entry.CountedQty < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "counted_qty must be greater or equal to 0")
+			}
+
+			var line Models.StockCountLine
+			if err := tx.Where("count_id = ? AND inventory_id = ?", id, entry.InventoryID).First(&line).Error; err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "inventory is not part of this count: "+entry.InventoryID)
+			}
+			if line.Status != "Pending" && line.Status != "Counted" && line.Status != "Recount" {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("line for inventory %s is already %s", entry.InventoryID, line.Status))
+			}
+
+			// ผู้นับคนเดิมส่งซ้ำในรอบเดียวกัน ให้แทนที่ค่าเดิม
+			if err := tx.Where("line_id = ? AND round = ? AND counted_by = ?", line.LineID, line.Round, counter).
+				Delete(&Models.StockCountEntry{}).Error; err != nil {
+				return err
+			}
+			record := Models.StockCountEntry{
+				LineID:     line.LineID,
+				CountedQty: entry.CountedQty,
+				CountedBy:  counter,
+				Round:      line.Round,
+				CreatedAt:  time.Now(),
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+
+			if err := evaluateStockCountLine(tx, count, &line); err != nil {
+				return err
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to record counts: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Counts recorded", "lines": lines})
+}
+
+// สั่งนับใหม่สำหรับรายการที่เลือก
+func RecountStockCountLines(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req struct {
+		LineIDs []string `json:"line_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if len(req.LineIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "line_ids is required"})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := openStockCount(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&Models.StockCountLine{}).
+			Where("count_id = ? AND line_id IN ? AND status IN ?", id, req.LineIDs, []string{"Counted", "Rejected"}).
+			Updates(map[string]interface{}{
+				"round":          gorm.Expr("round + 1"),
+				"counted_qty":    nil,
+				"moved_qty":      0,
+				"variance":       0,
+				"variance_value": 0,
+				"status":         "Recount",
+				"updated_at":     time.Now(),
+			}).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to request recount: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Recount requested"})
+}
+
+// อนุมัติหรือปฏิเสธส่วนต่างของรายการที่นับแล้ว
+func ReviewStockCountLines(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req struct {
+		LineIDs []string `json:"line_ids"`
+		Approve bool     `json:"approve"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+
+	status := "Rejected"
+	if req.Approve {
+		status = "Approved"
+	}
+
+	var reviewed int64
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := openStockCount(tx, id); err != nil {
+			return err
+		}
+		query := tx.Model(&Models.StockCountLine{}).Where("count_id = ? AND status = ?", id, "Counted")
+		if len(req.LineIDs) > 0 {
+			query = query.Where("line_id IN ?", req.LineIDs)
+		}
+		result := query.Updates(map[string]interface{}{"status": status, "updated_at": time.Now()})
+		reviewed = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to review lines: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Lines reviewed", "status": status, "reviewed": reviewed})
+}
+
+// ปรับยอด Inventory ตามส่วนต่างที่อนุมัติแล้ว และปิดรอบตรวจนับ
+// ส่วนต่างถูกนำไปบวก/ลบกับยอดปัจจุบัน จึงไม่ทับรายการรับ/จ่ายที่เกิดขึ้นระหว่างนับ
+func ApplyStockCount(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+	username := currentUsername(c)
+
+	var applied []Models.StockCountLine
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := openStockCount(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var lines []Models.StockCountLine
+		if err := tx.Where("count_id = ?", id).Find(&lines).Error; err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if line.Status == "Recount" {
+				return fiber.NewError(fiber.StatusBadRequest, "some lines still need to be recounted")
+			}
+			if line.Status == "Counted" && line.Variance != 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "some variances have not been reviewed yet")
+			}
+		}
+
+		now := time.Now()
+		for _, line := range lines {
+			switch {
+			case line.Status == "Pending":
+				line.Status = "Skipped"
+			case line.Status == "Approved" && line.Variance != 0:
+				// Inventory ยังถูก freeze โดยการตรวจนับนี้ จึงข้ามการตรวจ freeze
+				inventory, err := ApplyStockChange(tx, StockChange{
+					InventoryID:  line.InventoryID,
+					Delta:        line.Variance,
+					MovementType: "Stocktake",
+					DocumentType: "StockCount",
+					DocumentID:   count.CountID,
+					Note:         count.CountNumber,
+					CreatedBy:    username,
+					IgnoreFreeze: true,
+				})
+				if err != nil {
+					return err
+				}
+				if count.LocationID != nil {
+					if err := changeLocationStock(tx, *count.LocationID, inventory, line.Variance); err != nil {
+						return err
+					}
+				}
+				if line.Variance > 0 {
+					err = receiveCost(tx, inventory, line.Variance, line.UnitCost, "StockCount", count.CountID)
+				} else {
+					_, err = consumeCost(tx, inventory, -line.Variance, "StockCount", count.CountID)
+				}
+				if err != nil {
+					return err
+				}
+				// ของหายตัดล็อตแบบ FEFO และ (ถ้านับทั้งสาขา) เอาออกจาก Bin ที่เกินยอดคงเหลือ
+				if line.Variance < 0 {
+					if err := reduceLotsFEFO(tx, inventory.InventoryID, -line.Variance); err != nil {
+						return err
+					}
+					if count.LocationID == nil {
+						if err := releaseLocations(tx, inventory, count.CountID, username); err != nil {
+							return err
+						}
+					}
+				}
+				line.Status = "Applied"
+				applied = append(applied, line)
+			case line.Status == "Approved" || line.Status == "Counted":
+				line.Status = "Applied"
+			}
+			line.UpdatedAt = now
+			if err := tx.Save(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		count.Status = "Applied"
+		count.ApprovedBy = username
+		count.AppliedAt = &now
+		count.UpdatedAt = now
+		return tx.Save(&count).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to apply stock count: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Stock count applied", "adjusted": applied, "summary": stockCountSummary(applied)})
+}
+
+// ยกเลิกรอบตรวจนับ (ปลด freeze โดยไม่ปรับยอด)
+func CancelStockCount(db *gorm.DB, c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := openStockCount(tx, id)
+		if err != nil {
+			return err
+		}
+		count.Status = "Cancelled"
+		count.UpdatedAt = time.Now()
+		return tx.Save(&count).Error
+	}); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusInternalServerError)).JSON(fiber.Map{"error": "Failed to cancel stock count: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"message": "Stock count cancelled"})
+}
+
+func StocktakeRoutes(app *fiber.App, db *gorm.DB) {
+	app.Get("/Stocktakes", func(c *fiber.Ctx) error {
+		return LookStockCounts(db, c)
+	})
+
+	app.Get("/Stocktakes/:id", func(c *fiber.Ctx) error {
+		return FindStockCount(db, c)
+	})
+
+	app.Get("/Stocktakes/:id/variances", func(c *fiber.Ctx) error {
+		return GetStockCountVariances(db, c)
+	})
+
+	app.Post("/Stocktakes", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return AddStockCount(auditDB(db, c), c)
+	})
+
+	app.Post("/Stocktakes/:id/counts", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RecordStockCountEntries(auditDB(db, c), c)
+	})
+
+	app.Post("/Stocktakes/:id/recount", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RecountStockCountLines(auditDB(db, c), c)
+	})
+
+	app.Post("/Stocktakes/:id/review", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return ReviewStockCountLines(auditDB(db, c), c)
+	})
+
+	app.Post("/Stocktakes/:id/apply", Authentication.AuthMiddleware, Authentication.RequireRole("Manager", "God"), func(c *fiber.Ctx) error {
+		return ApplyStockCount(auditDB(db, c), c)
+	})
+
+	app.Post("/Stocktakes/:id/cancel", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return CancelStockCount(auditDB(db, c), c)
+	})
+}
@@ -1,91 +1,124 @@
 package Func
 
 import (
+	"Api/Authentication"
 	"Api/Models"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
-// ✅ ฟังก์ชันเพิ่ม Supplier พร้อมบันทึกลง ProductSupplier
-func AddSupplier(db *gorm.DB, c *fiber.Ctx) error {
-	// รับข้อมูลจาก JSON Request
-	type SupplierRequest struct {
-		Name        string  `json:"name"`
-		PricePallet float64 `json:"pricepallet"`
-		ProductID   string  `json:"productid"`
-	}
-
-	var req SupplierRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
-	}
+// SupplierRequest ข้อมูล Supplier ที่รับจากผู้ใช้
+type SupplierRequest struct {
+	Name            string  `json:"name"`
+	ContactName     string  `json:"contact_name"`
+	Email           string  `json:"email"`
+	Phone           string  `json:"phone"`
+	Address         string  `json:"address"`
+	TaxID           string  `json:"tax_id"`
+	PaymentTerms    string  `json:"payment_terms"`
+	PaymentTermDays int     `json:"payment_term_days"`
+	LeadTimeDays    int     `json:"lead_time_days"`
+	Currency        string  `json:"currency"`
+	PricePallet     float64 `json:"pricepallet"`
+	ProductID       string  `json:"productid"`
+}
 
-	// ✅ ตรวจสอบค่าที่จำเป็นต้องมี
+// ตรวจสอบข้อมูล Supplier และคัดลอกลง model
+func applySupplierRequest(supplier *Models.Supplier, req SupplierRequest) error {
 	if req.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Supplier name is required"})
+		return fiber.NewError(fiber.StatusBadRequest, "Supplier name is required")
 	}
-	if req.PricePallet <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Price pallet must be greater than zero"})
+	if req.PricePallet < 0 || req.PaymentTermDays < 0 || req.LeadTimeDays < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "pricepallet, payment_term_days and lead_time_days must be greater or equal to 0")
 	}
-
-	// ✅ สร้าง UUID ใหม่ให้ Supplier (ใช้ค่าเดียวกันทุกที่)
-	supplierUUID := uuid.New()
-
-	// ✅ ตรวจสอบ `ProductID` ถ้ามีค่าต้องเป็น UUID ที่ถูกต้อง
-	var productUUID *uuid.UUID
 	if req.ProductID != "" {
-		parsedUUID, err := uuid.Parse(req.ProductID)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product UUID format"})
+		if _, err := uuid.Parse(req.ProductID); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid product UUID format")
 		}
-		productUUID = &parsedUUID
 	}
 
-	// ✅ สร้าง Supplier ในฐานข้อมูล
-	supplier := Models.Supplier{
-		SupplierID:  supplierUUID.String(), // ✅ ใช้ UUID ที่เพิ่งสร้าง
-		Name:        req.Name,
-		PricePallet: req.PricePallet,
+	supplier.Name = req.Name
+	supplier.ContactName = req.ContactName
+	supplier.Email = req.Email
+	supplier.Phone = req.Phone
+	supplier.Address = req.Address
+	supplier.TaxID = req.TaxID
+	supplier.PaymentTerms = req.PaymentTerms
+	supplier.PaymentTermDays = req.PaymentTermDays
+	supplier.LeadTimeDays = req.LeadTimeDays
+	supplier.Currency = req.Currency
+	if supplier.Currency == "" {
+		supplier.Currency = defaultCurrency
 	}
+	supplier.PricePallet = req.PricePallet
+	supplier.ProductID = req.ProductID
+	return nil
+}
 
-	// ✅ ถ้ามี `ProductID` ให้ใส่ค่าลงไป
-	if productUUID != nil {
-		supplier.ProductID = productUUID.String()
+// สินค้าที่ส่งมากับ Supplier แบบเดิม (productid + pricepallet) บันทึกลงแคตตาล็อกตามหน่วยใน ProductUnit ของสินค้า
+func legacySupplierProduct(tx *gorm.DB, supplier Models.Supplier) error {
+	if supplier.ProductID == "" {
+		return nil
+	}
+	var existing int64
+	if err := tx.Model(&Models.SupplierProduct{}).
+		Where("supplier_id = ? AND product_id = ?", supplier.SupplierID, supplier.ProductID).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
 	}
+	unit, conversRate := productUnitRate(tx, supplier.ProductID)
+	_, err := saveSupplierProduct(tx, supplier.SupplierID, SupplierProductRequest{
+		ProductID:    supplier.ProductID,
+		Unit:         unit,
+		ConversRate:  conversRate,
+		PricePerUnit: decimal.NewFromFloat(supplier.PricePallet),
+	})
+	return err
+}
 
-	if err := db.Create(&supplier).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create supplier: " + err.Error()})
+// ✅ ฟังก์ชันเพิ่ม Supplier (ถ้าส่ง productid มาด้วยจะเพิ่มลงแคตตาล็อก SupplierProduct)
+func AddSupplier(db *gorm.DB, c *fiber.Ctx) error {
+	var req SupplierRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
 	}
 
-	// ✅ ถ้ามี `ProductID` ให้บันทึกลง `ProductSupplier` (ใช้ `supplierUUID` ที่ถูกต้อง)
-	if productUUID != nil {
-		productSupplier := Models.ProductSupplier{
-			SupplierID: supplierUUID, // ✅ ใช้ UUID ที่สร้างตอนแรก
-			ProductID:  *productUUID, // ✅ UUID ของ Product
-		}
+	supplier := Models.Supplier{SupplierID: uuid.New().String()}
+	if err := applySupplierRequest(&supplier, req); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
 
-		if err := db.Create(&productSupplier).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to link product with supplier: " + err.Error()})
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		create := tx
+		if supplier.ProductID == "" {
+			create = tx.Omit("ProductID")
+		}
+		if err := create.Create(&supplier).Error; err != nil {
+			return err
 		}
+		return legacySupplierProduct(tx, supplier)
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create supplier: " + err.Error()})
 	}
 
 	// ✅ ตอบกลับข้อมูลที่สร้างสำเร็จ
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Supplier created successfully",
-		"data": fiber.Map{
-			"supplier_id": supplierUUID.String(), // ✅ ใช้ค่า supplierUUID ที่ถูกต้อง
-			"name":        supplier.Name,
-			"pricepallet": supplier.PricePallet,
-		},
+		"data":    supplier,
 	})
 }
 
 // ดูข้อมูล Supplier
 func LookSuppliers(db *gorm.DB, c *fiber.Ctx) error {
 	var suppliers []Models.Supplier
-	if err := db.Find(&suppliers).Error; err != nil {
+	if err := withArchived(db, c).Find(&suppliers).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch suppliers: " + err.Error()})
 	}
 	return c.JSON(fiber.Map{"data": suppliers})
@@ -98,6 +131,7 @@ func FindSupplier(db *gorm.DB, c *fiber.Ctx) error {
 	if err := db.Where("supplier_id = ?", id).First(&supplier).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
 	}
+	setETag(c, supplier.Version)
 	return c.JSON(fiber.Map{"data": supplier})
 }
 
@@ -109,10 +143,12 @@ func UpdateSupplier(db *gorm.DB, c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
 	}
 
-	type SupplierRequest struct {
-		Name        string  `json:"name"`
-		PricePallet float64 `json:"pricepallet"`
-		ProductID   string  `gorm:"foreignKey:ProductID" json:"productid"`
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if supplier.Version != version {
+		return preconditionFailed(c, "data", supplier, supplier.Version)
 	}
 
 	body := make(map[string]interface{})
@@ -121,9 +157,18 @@ func UpdateSupplier(db *gorm.DB, c *fiber.Ctx) error {
 	}
 
 	allowedFields := map[string]bool{
-		"name":        true,
-		"pricepallet": true,
-		"productid":   true,
+		"name":              true,
+		"contact_name":      true,
+		"email":             true,
+		"phone":             true,
+		"address":           true,
+		"tax_id":            true,
+		"payment_terms":     true,
+		"payment_term_days": true,
+		"lead_time_days":    true,
+		"currency":          true,
+		"pricepallet":       true,
+		"productid":         true,
 	}
 
 	for key := range body {
@@ -136,30 +181,83 @@ func UpdateSupplier(db *gorm.DB, c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
 	}
+	if err := applySupplierRequest(&supplier, req); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	supplier.Name = req.Name
-	supplier.PricePallet = req.PricePallet
-	supplier.ProductID = req.ProductID
-
-	if err := db.Save(&supplier).Error; err != nil {
+	var productID interface{}
+	if supplier.ProductID != "" {
+		productID = supplier.ProductID
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := updateVersioned(tx, &Models.Supplier{}, "supplier_id", id, version, map[string]interface{}{
+			"name":              supplier.Name,
+			"contact_name":      supplier.ContactName,
+			"email":             supplier.Email,
+			"phone":             supplier.Phone,
+			"address":           supplier.Address,
+			"tax_id":            supplier.TaxID,
+			"payment_terms":     supplier.PaymentTerms,
+			"payment_term_days": supplier.PaymentTermDays,
+			"lead_time_days":    supplier.LeadTimeDays,
+			"currency":          supplier.Currency,
+			"price_pallet":      supplier.PricePallet,
+			"product_id":        productID,
+		}); err != nil {
+			return err
+		}
+		return legacySupplierProduct(tx, supplier)
+	}); errors.Is(err, errStaleVersion) {
+		db.Where("supplier_id = ?", id).First(&supplier)
+		return preconditionFailed(c, "data", supplier, supplier.Version)
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update supplier: " + err.Error()})
 	}
+	supplier.Version = version + 1
+
+	setETag(c, supplier.Version)
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Supplier updated successfully", "data": supplier})
 }
 
-// ลบข้อมูล Supplier ตาม ID
+// ลบข้อมูล Supplier ตาม ID (เก็บถาวร หรือ ลบถาวรเมื่อส่ง hard=true และไม่มีข้อมูลอ้างอิง)
 func DeleteSupplier(db *gorm.DB, c *fiber.Ctx) error {
 	id := c.Params("id")
+	hard := c.QueryBool("hard")
+
+	query := db
+	if hard {
+		query = db.Unscoped()
+	}
+
 	var supplier Models.Supplier
-	if err := db.Where("supplier_id = ?", id).First(&supplier).Error; err != nil {
+	if err := query.Where("supplier_id = ?", id).First(&supplier).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
 	}
-	if err := db.Delete(&supplier).Error; err != nil {
+
+	if hard {
+		return hardDeleteRecord(db, c, &supplier, func(tx *gorm.DB) (map[string]int64, error) {
+			return supplierDependencies(tx, supplier.SupplierID)
+		})
+	}
+
+	if err := archiveRecord(db, c, &supplier); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete supplier: " + err.Error()})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Supplier deleted successfully"})
 }
 
+// กู้คืน Supplier ที่ถูกเก็บถาวร
+func RestoreSupplier(db *gorm.DB, c *fiber.Ctx) error {
+	restored, err := restoreRecord(db, &Models.Supplier{}, "supplier_id", c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore supplier: " + err.Error()})
+	}
+	if !restored {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Archived supplier not found"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Supplier restored successfully"})
+}
+
 func SupplierRoutes(app *fiber.App, db *gorm.DB) {
 	app.Get("/Supplier", func(c *fiber.Ctx) error {
 		return LookSuppliers(db, c)
@@ -170,14 +268,42 @@ func SupplierRoutes(app *fiber.App, db *gorm.DB) {
 	})
 
 	app.Post("/Supplier", func(c *fiber.Ctx) error {
-		return AddSupplier(db, c)
+		return AddSupplier(auditDB(db, c), c)
 	})
 
 	app.Put("/Supplier/:id", func(c *fiber.Ctx) error {
-		return UpdateSupplier(db, c)
+		return UpdateSupplier(auditDB(db, c), c)
+	})
+
+	app.Get("/Supplier/:id/scorecard", func(c *fiber.Ctx) error {
+		return GetSupplierScorecard(db, c)
+	})
+
+	app.Get("/reports/supplier-ranking", func(c *fiber.Ctx) error {
+		return GetSupplierRanking(db, c)
+	})
+
+	app.Get("/Supplier/:id/products", func(c *fiber.Ctx) error {
+		return LookSupplierProducts(db, c)
+	})
+
+	app.Post("/Supplier/:id/products", func(c *fiber.Ctx) error {
+		return SaveSupplierProduct(auditDB(db, c), c)
+	})
+
+	app.Put("/Supplier/:id/products/:productId", func(c *fiber.Ctx) error {
+		return SaveSupplierProduct(auditDB(db, c), c)
+	})
+
+	app.Delete("/Supplier/:id/products/:productId", func(c *fiber.Ctx) error {
+		return DeleteSupplierProduct(auditDB(db, c), c)
+	})
+
+	app.Delete("/Supplier/:id", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return DeleteSupplier(auditDB(db, c), c)
 	})
 
-	app.Delete("/Supplier/:id", func(c *fiber.Ctx) error {
-		return DeleteSupplier(db, c)
+	app.Put("/Supplier/:id/restore", Authentication.AuthMiddleware, func(c *fiber.Ctx) error {
+		return RestoreSupplier(auditDB(db, c), c)
 	})
 }
@@ -0,0 +1,172 @@
+package Func
+
+import (
+	"Api/Models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SupplierProductRequest ข้อมูลสินค้าในแคตตาล็อกของ Supplier
+type SupplierProductRequest struct {
+	ProductID    string          `json:"product_id"`
+	SupplierSKU  string          `json:"supplier_sku"`
+	Unit         string          `json:"unit"`
+	ConversRate  int             `json:"convers_rate"`
+	PricePerUnit decimal.Decimal `json:"price_per_unit"`
+	MOQ          int             `json:"moq"`
+	Preferred    bool            `json:"preferred"`
+}
+
+// ย้ายข้อมูลเดิมเข้าแคตตาล็อก SupplierProduct (รันซ้ำได้ รายการที่มีแล้วจะถูกข้าม)
+// ราคาเดิมเป็นราคาต่อหน่วยของสินค้า จึงใช้หน่วยและ ConversRate จาก ProductUnit ของสินค้านั้น
+func MigrateSupplierProducts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO "SupplierProduct" (supplier_product_id, supplier_id, product_id, unit, convers_rate, price_per_unit, moq, preferred, created_at, updated_at)
+			SELECT gen_random_uuid(), ps.supplier_id, ps.product_id, COALESCE(pu.type, 'Pieces'), COALESCE(pu.convers_rate, 1), COALESCE(s.price_pallet, 0), 0, ps.preferred, NOW(), NOW()
+			FROM "ProductSupplier" ps
+			LEFT JOIN "Supplier" s ON s.supplier_id = ps.supplier_id
+			LEFT JOIN LATERAL (
+				SELECT u.type, u.convers_rate FROM "ProductUnit" u
+				WHERE u.product_id::text = ps.product_id::text AND u.convers_rate > 0
+				ORDER BY u.created_at LIMIT 1
+			) pu ON true
+			ON CONFLICT (supplier_id, product_id) DO NOTHING`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+			INSERT INTO "SupplierProduct" (supplier_product_id, supplier_id, product_id, unit, convers_rate, price_per_unit, moq, preferred, created_at, updated_at)
+			SELECT gen_random_uuid(), s.supplier_id, s.product_id, COALESCE(pu.type, 'Pieces'), COALESCE(pu.convers_rate, 1), s.price_pallet, 0, false, NOW(), NOW()
+			FROM "Supplier" s
+			LEFT JOIN LATERAL (
+				SELECT u.type, u.convers_rate FROM "ProductUnit" u
+				WHERE u.product_id::text = s.product_id::text AND u.convers_rate > 0
+				ORDER BY u.created_at LIMIT 1
+			) pu ON true
+			WHERE s.product_id IS NOT NULL
+			ON CONFLICT (supplier_id, product_id) DO NOTHING`).Error
+	})
+}
+
+// ตรวจสอบข้อมูลสินค้าในแคตตาล็อก
+func validateSupplierProduct(req SupplierProductRequest) error {
+	if req.ConversRate < 0 || req.MOQ < 0 || req.PricePerUnit.IsNegative() {
+		return fiber.NewError(fiber.StatusBadRequest, "convers_rate, moq and price_per_unit must be greater or equal to 0")
+	}
+	return nil
+}
+
+// บันทึกสินค้าในแคตตาล็อกของ Supplier (มีอยู่แล้วจะแก้ไข) และตั้ง preferred ให้เหลือ Supplier เดียวต่อสินค้า
+func saveSupplierProduct(tx *gorm.DB, supplierID string, req SupplierProductRequest) (Models.SupplierProduct, error) {
+	now := time.Now()
+	item := Models.SupplierProduct{
+		SupplierID:   supplierID,
+		ProductID:    req.ProductID,
+		SupplierSKU:  req.SupplierSKU,
+		Unit:         req.Unit,
+		ConversRate:  req.ConversRate,
+		PricePerUnit: req.PricePerUnit,
+		MOQ:          req.MOQ,
+		Preferred:    req.Preferred,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if item.Unit == "" {
+		item.Unit = "Pieces"
+	}
+	if item.ConversRate == 0 {
+		item.ConversRate = 1
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "supplier_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"supplier_sku", "unit", "convers_rate", "price_per_unit", "moq", "preferred", "updated_at"}),
+	}).Create(&item).Error; err != nil {
+		return item, err
+	}
+
+	if item.Preferred {
+		if err := tx.Model(&Models.SupplierProduct{}).
+			Where("product_id = ? AND supplier_id <> ?", item.ProductID, supplierID).
+			Update("preferred", false).Error; err != nil {
+			return item, err
+		}
+	}
+
+	if err := tx.Where("supplier_id = ? AND product_id = ?", supplierID, item.ProductID).First(&item).Error; err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// ดูแคตตาล็อกสินค้าของ Supplier
+func LookSupplierProducts(db *gorm.DB, c *fiber.Ctx) error {
+	type catalogRow struct {
+		Models.SupplierProduct
+		ProductName string `json:"product_name"`
+		SKU         string `json:"sku"`
+	}
+
+	var rows []catalogRow
+	if err := db.Table(`"SupplierProduct" sp`).
+		Select(`sp.*, p.product_name, p.sku`).
+		Joins(`JOIN "Product" p ON p.product_id = sp.product_id`).
+		Where("sp.supplier_id = ?", c.Params("id")).
+		Order("p.product_name").
+		Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch supplier products: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rows})
+}
+
+// เพิ่มหรือแก้ไขสินค้าในแคตตาล็อกของ Supplier
+func SaveSupplierProduct(db *gorm.DB, c *fiber.Ctx) error {
+	supplierID := c.Params("id")
+	if err := db.Where("supplier_id = ?", supplierID).First(&Models.Supplier{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier not found"})
+	}
+
+	var req SupplierProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid JSON format: " + err.Error()})
+	}
+	if productID := c.Params("productId"); productID != "" {
+		req.ProductID = productID
+	}
+	if _, err := uuid.Parse(req.ProductID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product_id"})
+	}
+	if err := db.Where("product_id = ?", req.ProductID).First(&Models.Product{}).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Product not found"})
+	}
+	if err := validateSupplierProduct(req); err != nil {
+		return c.Status(errorStatus(err, fiber.StatusBadRequest)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var item Models.SupplierProduct
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		item, err = saveSupplierProduct(tx, supplierID, req)
+		return err
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save supplier product: " + err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Supplier product saved successfully", "data": item})
+}
+
+// ลบสินค้าออกจากแคตตาล็อกของ Supplier
+func DeleteSupplierProduct(db *gorm.DB, c *fiber.Ctx) error {
+	result := db.Where("supplier_id = ? AND product_id = ?", c.Params("id"), c.Params("productId")).Delete(&Models.SupplierProduct{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete supplier product: " + result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Supplier product not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Supplier product deleted successfully"})
+}
@@ -0,0 +1,54 @@
+package Func
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// version ในฐานข้อมูลไม่ตรงกับที่ผู้ใช้ส่งมา (มีคนแก้ไขไปก่อนแล้ว)
+var errStaleVersion = fiber.NewError(fiber.StatusPreconditionFailed, "Record has been modified by another request")
+
+// อ่าน version จาก header If-Match (รับทั้ง "3", 3 และ W/"3")
+func ifMatchVersion(c *fiber.Ctx) (int, error) {
+	value := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if value == "" {
+		return 0, fiber.NewError(fiber.StatusPreconditionRequired, "If-Match header is required")
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid If-Match header")
+	}
+	return version, nil
+}
+
+// ส่ง version ปัจจุบันกลับเป็น ETag
+func setETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`"%d"`, version))
+}
+
+// ตอบ 412 พร้อมข้อมูลล่าสุด ให้ผู้ใช้โหลดไปแก้ไขใหม่
+func preconditionFailed(c *fiber.Ctx, key string, current interface{}, version int) error {
+	setETag(c, version)
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error": errStaleVersion.Message,
+		key:     current,
+	})
+}
+
+// อัปเดตเฉพาะเมื่อ version ยังตรงกับที่ผู้ใช้อ่านไป และเพิ่ม version ขึ้น 1
+func updateVersioned(tx *gorm.DB, model interface{}, column, id string, version int, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+	result := tx.Model(model).Where(column+" = ? AND version = ?", id, version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errStaleVersion
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package Models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -12,9 +14,20 @@ type Branches struct {
 	BranchID uuid.UUID `gorm:"type:uuid;primaryKey" json:"branch_id"`
 	BName    string    `json:"b_name"`
 	Location string    `json:"location"`
+	Code     string    `json:"code"` // รหัสสาขาสั้นๆ ใช้ในเลขที่เอกสาร เช่น BKK
+
+	// อนุญาตให้สต็อกของสาขานี้ติดลบได้ (ค่าเริ่มต้นไม่อนุญาต)
+	AllowNegativeStock bool `gorm:"default:false" json:"allow_negative_stock"`
+
+	// เลข version สำหรับป้องกันการแก้ไขทับกัน (ส่งกลับเป็น ETag)
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	// Soft delete (เก็บประวัติสาขาไว้ให้ Order/Shipment ที่อ้างอิง)
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy *string        `gorm:"type:uuid" json:"deleted_by"`
 
 	// ไม่ต้องมี Foreign Key ไปยัง Employees
-	Employees []Employees `gorm:"foreignKey:BranchID;constraint:OnDelete:CASCADE" json:"employees"`
+	Employees []Employees `gorm:"foreignKey:BranchID;constraint:OnDelete:RESTRICT" json:"employees"`
 }
 
 func (Branches) TableName() string {
@@ -36,8 +49,11 @@ type Employees struct {
 	Salary      float64   `json:"salary"`
 	CreatedAt   time.Time `json:"created_at"`
 
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy *string        `gorm:"type:uuid" json:"deleted_by"`
+
 	BranchID uuid.UUID `gorm:"type:uuid;not null" json:"branch_id"`
-	Branch   Branches  `gorm:"foreignKey:BranchID;references:BranchID;constraint:OnDelete:CASCADE" json:"branch"`
+	Branch   Branches  `gorm:"foreignKey:BranchID;references:BranchID;constraint:OnDelete:RESTRICT" json:"branch"`
 }
 
 func (Employees) TableName() string {
@@ -46,13 +62,18 @@ func (Employees) TableName() string {
 
 // Product model
 type Product struct {
-	ProductID   string        `gorm:"type:uuid;primaryKey" json:"product_id"`
-	ProductName string        `json:"product_name"`
-	Description string        `json:"description"`
-	Image       []byte        `json:"image"`
-	CreatedAt   time.Time     `json:"created_at"`
-	Inventory   []Inventory   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"inventory"`
-	ProductUnit []ProductUnit `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"product_unit"`
+	ProductID   string         `gorm:"type:uuid;primaryKey" json:"product_id"`
+	ProductName string         `json:"product_name"`
+	SKU         string         `gorm:"column:sku" json:"sku"`
+	Description string         `json:"description"`
+	Serialized  bool           `gorm:"column:serialized;default:false" json:"serialized"`
+	Image       []byte         `json:"image"`
+	Version     int            `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time      `json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy   *string        `gorm:"type:uuid" json:"deleted_by"`
+	Inventory   []Inventory    `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"inventory"`
+	ProductUnit []ProductUnit  `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"product_unit"`
 }
 
 func (Product) TableName() string {
@@ -91,6 +112,7 @@ type Inventory struct {
 	BranchID    string    `gorm:"column:branch_id" json:"branch_id"`
 	Quantity    int       `gorm:"column:quantity" json:"quantity"`
 	Price       float64   `gorm:"column:price" json:"price"`
+	Version     int       `gorm:"column:version;not null;default:1" json:"version"`
 	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
@@ -106,10 +128,28 @@ func (s *Inventory) BeforeCreate(tx *gorm.DB) (err error) {
 
 // Supplier model
 type Supplier struct {
-	SupplierID  string  `gorm:"type:uuid;primaryKey" json:"supplier_id"`
-	Name        string  `json:"name"`
+	SupplierID string `gorm:"type:uuid;primaryKey" json:"supplier_id"`
+	Name       string `json:"name"`
+
+	// ข้อมูลติดต่อและเงื่อนไขการค้า
+	ContactName     string `json:"contact_name"`
+	Email           string `json:"email"`
+	Phone           string `json:"phone"`
+	Address         string `json:"address"`
+	TaxID           string `json:"tax_id"`
+	PaymentTerms    string `json:"payment_terms"` // เช่น COD, NET30
+	PaymentTermDays int    `gorm:"default:0" json:"payment_term_days"`
+	LeadTimeDays    int    `gorm:"default:0" json:"lead_time_days"`
+	Currency        string `gorm:"default:THB" json:"currency"`
+
+	// ข้อมูลเดิม (สินค้าเดียวต่อ Supplier) ใช้ SupplierProduct แทน
 	ProductID   string  `gorm:"type:uuid" json:"product_id"`
 	PricePallet float64 `json:"price_pallet"`
+
+	Version int `gorm:"not null;default:1" json:"version"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy *string        `gorm:"type:uuid" json:"deleted_by"`
 }
 
 func (Supplier) TableName() string {
@@ -117,14 +157,28 @@ func (Supplier) TableName() string {
 }
 
 type Order struct {
-	OrderID     string     `gorm:"type:uuid;primaryKey" json:"order_id"`
-	OrderNumber string     `json:"order_number"`
-	Status      string     `json:"status"`
-	SupplierID  uuid.UUID  `gorm:"type:uuid" json:"supplier_id"`
-	EmployeesID *uuid.UUID `gorm:"type:uuid" json:"employees_id"`
-	TotalAmount float64    `json:"total_amount"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	OrderID      string     `gorm:"type:uuid;primaryKey" json:"order_id"`
+	OrderNumber  string     `json:"order_number"`
+	Status       string     `json:"status"` // Draft, Submitted, Approved, PartiallyReceived, Received, Closed, Cancelled, Amended
+	SupplierID   uuid.UUID  `gorm:"type:uuid" json:"supplier_id"`
+	BranchID     *string    `gorm:"type:uuid" json:"branch_id"` // สาขาปลายทางที่รับสินค้า
+	PromisedDate *time.Time `json:"promised_date"`              // วันที่ Supplier สัญญาว่าจะส่งของ
+	EmployeesID  *uuid.UUID `gorm:"type:uuid" json:"employees_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// การยกเลิก/แก้ไข (เอกสารเดิมไม่ถูกลบ แต่ลงรายการกลับสต็อกและชี้ไปยังฉบับแก้ไข)
+	CancelReason  string     `json:"cancel_reason"`
+	CancelledBy   string     `json:"cancelled_by"`
+	CancelledAt   *time.Time `json:"cancelled_at"`
+	AmendedFromID *string    `gorm:"type:uuid" json:"amended_from_id"` // Order เดิมที่ฉบับนี้แก้ไข
+	AmendedToID   *string    `gorm:"type:uuid" json:"amended_to_id"`   // Order ฉบับแก้ไขของ Order นี้
+
+	// ยอดเงินของ Order (คำนวณจาก OrderItem ด้วยทศนิยมแบบ decimal)
+	Subtotal    decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"subtotal"`
+	Tax         decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"tax"`
+	GrandTotal  decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"grand_total"`
+	TotalAmount decimal.Decimal `gorm:"type:numeric(18,4)" json:"total_amount"` // เท่ากับ GrandTotal (คงไว้ให้ระบบเดิม)
 
 	// Relationships
 	OrderItems []OrderItem `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE" json:"order_items"` // OnDelete:CASCADE
@@ -146,8 +200,17 @@ type OrderItem struct {
 	ProductID   string    `gorm:"type:uuid;not null" json:"product_id"`
 	Quantity    int       `json:"quantity"`
 	ConversRate float64   `json:"convers_rate"`
+	ReceivedQty int       `gorm:"default:0" json:"received_qty"`
+	DamagedQty  int       `gorm:"default:0" json:"damaged_qty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// ราคา (UnitPrice ต่อหน่วยสั่งซื้อ = ConversRate หน่วยย่อย, Discount เป็นจำนวนเงินของทั้งรายการ, TaxRate เป็น %)
+	UnitPrice decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"unit_price"`
+	Currency  string          `gorm:"default:THB" json:"currency"`
+	Discount  decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"discount"`
+	TaxRate   decimal.Decimal `gorm:"type:numeric(7,4);default:0" json:"tax_rate"`
+	LineTotal decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"line_total"` // ยอดหลังหักส่วนลด ก่อนภาษี
 }
 
 func (OrderItem) TableName() string {
@@ -165,11 +228,21 @@ type Shipment struct {
 	ShipmentNumber string    `json:"shipment_number"`
 	FromBranchID   string    `json:"from_branch_id"`
 	ToBranchID     string    `json:"to_branch_id"`
-	Status         string    `json:"status"`
+	Status         string    `json:"status"` // Pending, Approved, Completed, Rejected, Cancelled, Amended
 	ShipmentDate   time.Time `json:"shipment_date"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 
+	// การยกเลิก/แก้ไข (เอกสารเดิมไม่ถูกลบ แต่ลงรายการกลับสต็อกและชี้ไปยังฉบับแก้ไข)
+	CancelReason  string     `json:"cancel_reason"`
+	CancelledBy   string     `json:"cancelled_by"`
+	CancelledAt   *time.Time `json:"cancelled_at"`
+	AmendedFromID *string    `gorm:"type:uuid" json:"amended_from_id"` // Shipment เดิมที่ฉบับนี้แก้ไข
+	AmendedToID   *string    `gorm:"type:uuid" json:"amended_to_id"`   // Shipment ฉบับแก้ไขของ Shipment นี้
+
+	// เหตุผลที่ต้องมีคนตรวจสอบ (เช่น POS รับสินค้าก่อนอนุมัติครบ หรือซิงค์กับ POS ไม่สำเร็จ)
+	ReviewNote string `json:"review_note"`
+
 	// Relationships
 	ShipmentItems []ShipmentItem `gorm:"foreignKey:ShipmentID;constraint:OnDelete:CASCADE" json:"shipment_items"`
 }
@@ -208,8 +281,885 @@ func (s *ShipmentItem) BeforeCreate(tx *gorm.DB) (err error) {
 type ProductSupplier struct {
 	SupplierID uuid.UUID `gorm:"type:uuid;primaryKey" json:"supplier_id"`
 	ProductID  uuid.UUID `gorm:"type:uuid;primaryKey" json:"product_id"`
+	Preferred  bool      `gorm:"default:false" json:"preferred"`
 }
 
 func (ProductSupplier) TableName() string {
 	return "ProductSupplier"
 }
+
+// SupplierProduct model (แคตตาล็อกสินค้าของ Supplier แทน ProductSupplier เดิม)
+type SupplierProduct struct {
+	SupplierProductID string          `gorm:"type:uuid;primaryKey" json:"supplier_product_id"`
+	SupplierID        string          `gorm:"type:uuid;uniqueIndex:idx_supplier_product" json:"supplier_id"`
+	ProductID         string          `gorm:"type:uuid;uniqueIndex:idx_supplier_product;index" json:"product_id"`
+	SupplierSKU       string          `gorm:"column:supplier_sku" json:"supplier_sku"`
+	Unit              string          `json:"unit"`                                     // หน่วยที่ซื้อ เช่น Pallet, Box, Pieces
+	ConversRate       int             `gorm:"default:1" json:"convers_rate"`            // จำนวนหน่วยย่อยต่อหน่วยที่ซื้อ
+	PricePerUnit      decimal.Decimal `gorm:"type:numeric(18,4)" json:"price_per_unit"` // ราคาต่อหน่วยที่ซื้อ
+	MOQ               int             `gorm:"column:moq;default:0" json:"moq"`          // จำนวนสั่งซื้อขั้นต่ำ (หน่วยที่ซื้อ)
+	Preferred         bool            `gorm:"default:false" json:"preferred"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+func (SupplierProduct) TableName() string {
+	return "SupplierProduct"
+}
+
+func (s *SupplierProduct) BeforeCreate(tx *gorm.DB) (err error) {
+	s.SupplierProductID = uuid.New().String()
+	return
+}
+
+// PriceList model (รายการราคา เช่น ราคาขายปลีก/ขายส่ง)
+type PriceList struct {
+	PriceListID string    `gorm:"type:uuid;primaryKey" json:"price_list_id"`
+	Name        string    `json:"name"`
+	Currency    string    `json:"currency"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (PriceList) TableName() string {
+	return "PriceList"
+}
+
+func (s *PriceList) BeforeCreate(tx *gorm.DB) (err error) {
+	s.PriceListID = uuid.New().String()
+	return
+}
+
+// ProductPrice model (ราคาทุน/ราคาขาย ตามสาขาหรือรายการราคา พร้อมช่วงวันที่มีผล)
+type ProductPrice struct {
+	ProductPriceID string          `gorm:"type:uuid;primaryKey" json:"product_price_id"`
+	ProductID      string          `gorm:"type:uuid;not null;index" json:"product_id"`
+	BranchID       *string         `gorm:"type:uuid" json:"branch_id"`
+	PriceListID    *string         `gorm:"type:uuid" json:"price_list_id"`
+	CostPrice      decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"cost_price"`
+	SellingPrice   decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"selling_price"`
+	EffectiveFrom  time.Time       `json:"effective_from"`
+	EffectiveTo    *time.Time      `json:"effective_to"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func (ProductPrice) TableName() string {
+	return "ProductPrice"
+}
+
+func (s *ProductPrice) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ProductPriceID = uuid.New().String()
+	return
+}
+
+// PriceHistory model (ประวัติการเปลี่ยนราคา)
+type PriceHistory struct {
+	PriceHistoryID  string          `gorm:"type:uuid;primaryKey" json:"price_history_id"`
+	ProductID       string          `gorm:"type:uuid;not null;index" json:"product_id"`
+	BranchID        *string         `gorm:"type:uuid" json:"branch_id"`
+	PriceListID     *string         `gorm:"type:uuid" json:"price_list_id"`
+	OldCostPrice    decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"old_cost_price"`
+	NewCostPrice    decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"new_cost_price"`
+	OldSellingPrice decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"old_selling_price"`
+	NewSellingPrice decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"new_selling_price"`
+	EffectiveFrom   time.Time       `json:"effective_from"`
+	ChangedBy       string          `json:"changed_by"`
+	ChangedAt       time.Time       `json:"changed_at"`
+}
+
+func (PriceHistory) TableName() string {
+	return "PriceHistory"
+}
+
+func (s *PriceHistory) BeforeCreate(tx *gorm.DB) (err error) {
+	s.PriceHistoryID = uuid.New().String()
+	return
+}
+
+// ProductPosMapping model (จับคู่ Product ของ Warehouse กับ Products ของ POS)
+type ProductPosMapping struct {
+	ProductID    string    `gorm:"type:uuid;primaryKey" json:"product_id"`
+	PosProductID string    `gorm:"type:uuid;not null" json:"pos_product_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (ProductPosMapping) TableName() string {
+	return "ProductPosMapping"
+}
+
+// InventoryLot model (ล็อตสินค้าภายใต้ Inventory พร้อมวันรับเข้าและวันหมดอายุ)
+type InventoryLot struct {
+	LotID        string     `gorm:"type:uuid;primaryKey" json:"lot_id"`
+	InventoryID  string     `gorm:"column:inventory_id;index" json:"inventory_id"`
+	ProductID    string     `gorm:"column:product_id;index" json:"product_id"`
+	BranchID     string     `gorm:"column:branch_id;index" json:"branch_id"`
+	LotNumber    string     `gorm:"column:lot_number" json:"lot_number"`
+	OrderID      *string    `gorm:"type:uuid" json:"order_id"`
+	ReceivedDate time.Time  `json:"received_date"`
+	ExpiryDate   *time.Time `gorm:"index" json:"expiry_date"`
+	Quantity     int        `json:"quantity"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (InventoryLot) TableName() string {
+	return "InventoryLot"
+}
+
+func (s *InventoryLot) BeforeCreate(tx *gorm.DB) (err error) {
+	s.LotID = uuid.New().String()
+	return
+}
+
+// ShipmentLotPick model (ล็อตที่ถูกหยิบออกไปกับ ShipmentItem แบบ FEFO)
+type ShipmentLotPick struct {
+	PickID         string     `gorm:"type:uuid;primaryKey" json:"pick_id"`
+	ShipmentID     string     `gorm:"type:uuid;index" json:"shipment_id"`
+	ShipmentListID string     `gorm:"type:uuid" json:"shipment_list_id"`
+	LotID          string     `gorm:"type:uuid" json:"lot_id"`
+	LotNumber      string     `json:"lot_number"`
+	ExpiryDate     *time.Time `json:"expiry_date"`
+	Quantity       int        `json:"quantity"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (ShipmentLotPick) TableName() string {
+	return "ShipmentLotPick"
+}
+
+func (s *ShipmentLotPick) BeforeCreate(tx *gorm.DB) (err error) {
+	s.PickID = uuid.New().String()
+	return
+}
+
+// SerialNumber model (หมายเลขซีเรียลของสินค้าแต่ละชิ้น พร้อมสาขาและสถานะปัจจุบัน)
+type SerialNumber struct {
+	SerialID    string    `gorm:"type:uuid;primaryKey" json:"serial_id"`
+	SerialNo    string    `gorm:"column:serial_no;uniqueIndex" json:"serial_no"`
+	ProductID   string    `gorm:"column:product_id;index" json:"product_id"`
+	BranchID    string    `gorm:"column:branch_id;index" json:"branch_id"`
+	InventoryID string    `gorm:"column:inventory_id" json:"inventory_id"`
+	Status      string    `json:"status"` // InStock, InTransit, Delivered, ReturnedToVendor, WrittenOff, Cancelled
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (SerialNumber) TableName() string {
+	return "SerialNumber"
+}
+
+func (s *SerialNumber) BeforeCreate(tx *gorm.DB) (err error) {
+	s.SerialID = uuid.New().String()
+	return
+}
+
+// SerialMovement model (ประวัติการเคลื่อนไหวของหมายเลขซีเรียล)
+type SerialMovement struct {
+	MovementID   string    `gorm:"type:uuid;primaryKey" json:"movement_id"`
+	SerialID     string    `gorm:"type:uuid;index" json:"serial_id"`
+	SerialNo     string    `gorm:"column:serial_no;index" json:"serial_no"`
+	ProductID    string    `gorm:"column:product_id" json:"product_id"`
+	FromBranchID string    `json:"from_branch_id"`
+	ToBranchID   string    `json:"to_branch_id"`
+	Status       string    `json:"status"`
+	DocumentType string    `json:"document_type"` // Order, Shipment
+	DocumentID   string    `json:"document_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (SerialMovement) TableName() string {
+	return "SerialMovement"
+}
+
+func (s *SerialMovement) BeforeCreate(tx *gorm.DB) (err error) {
+	s.MovementID = uuid.New().String()
+	return
+}
+
+// Location model (ตำแหน่งจัดเก็บภายในสาขา: Zone → Aisle → Rack → Bin)
+type Location struct {
+	LocationID string    `gorm:"type:uuid;primaryKey" json:"location_id"`
+	BranchID   string    `gorm:"column:branch_id;index;uniqueIndex:idx_location_branch_code" json:"branch_id"`
+	ParentID   *string   `gorm:"type:uuid;index" json:"parent_id"`
+	Type       string    `json:"type"` // Zone, Aisle, Rack, Bin
+	Code       string    `gorm:"uniqueIndex:idx_location_branch_code" json:"code"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	Children []Location `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+}
+
+func (Location) TableName() string {
+	return "Location"
+}
+
+func (s *Location) BeforeCreate(tx *gorm.DB) (err error) {
+	s.LocationID = uuid.New().String()
+	return
+}
+
+// LocationStock model (จำนวนสินค้าในแต่ละ Bin ผลรวมต้องไม่เกิน Inventory.Quantity)
+type LocationStock struct {
+	LocationStockID string    `gorm:"type:uuid;primaryKey" json:"location_stock_id"`
+	LocationID      string    `gorm:"type:uuid;uniqueIndex:idx_location_stock" json:"location_id"`
+	InventoryID     string    `gorm:"column:inventory_id;uniqueIndex:idx_location_stock" json:"inventory_id"`
+	ProductID       string    `gorm:"column:product_id;index" json:"product_id"`
+	BranchID        string    `gorm:"column:branch_id" json:"branch_id"`
+	Quantity        int       `json:"quantity"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (LocationStock) TableName() string {
+	return "LocationStock"
+}
+
+func (s *LocationStock) BeforeCreate(tx *gorm.DB) (err error) {
+	s.LocationStockID = uuid.New().String()
+	return
+}
+
+// LocationMove model (ประวัติการจัดเก็บ/ย้าย/หยิบสินค้าระหว่าง Bin)
+type LocationMove struct {
+	MoveID         string    `gorm:"type:uuid;primaryKey" json:"move_id"`
+	InventoryID    string    `gorm:"column:inventory_id;index" json:"inventory_id"`
+	ProductID      string    `gorm:"column:product_id" json:"product_id"`
+	BranchID       string    `gorm:"column:branch_id" json:"branch_id"`
+	FromLocationID *string   `gorm:"type:uuid" json:"from_location_id"`
+	ToLocationID   *string   `gorm:"type:uuid" json:"to_location_id"`
+	Quantity       int       `json:"quantity"`
+	MoveType       string    `json:"move_type"` // Putaway, Move, Pick, Reversal
+	DocumentID     *string   `gorm:"type:uuid" json:"document_id"`
+	MovedBy        string    `json:"moved_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (LocationMove) TableName() string {
+	return "LocationMove"
+}
+
+func (s *LocationMove) BeforeCreate(tx *gorm.DB) (err error) {
+	s.MoveID = uuid.New().String()
+	return
+}
+
+// ReorderSetting model (จุดสั่งซื้อ/ขั้นต่ำ/ขั้นสูง ของสินค้าแต่ละสาขา)
+type ReorderSetting struct {
+	ReorderSettingID string    `gorm:"type:uuid;primaryKey" json:"reorder_setting_id"`
+	ProductID        string    `gorm:"type:uuid;uniqueIndex:idx_reorder_product_branch" json:"product_id"`
+	BranchID         string    `gorm:"column:branch_id;uniqueIndex:idx_reorder_product_branch" json:"branch_id"`
+	MinQty           int       `json:"min_qty"`
+	MaxQty           int       `json:"max_qty"`
+	ReorderPoint     int       `json:"reorder_point"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (ReorderSetting) TableName() string {
+	return "ReorderSetting"
+}
+
+func (s *ReorderSetting) BeforeCreate(tx *gorm.DB) (err error) {
+	s.ReorderSettingID = uuid.New().String()
+	return
+}
+
+// ReorderSuggestion model (รายการแนะนำให้สั่งซื้อ คำนวณจากสต็อกและเอกสารที่ยังเปิดอยู่)
+type ReorderSuggestion struct {
+	SuggestionID string    `gorm:"type:uuid;primaryKey" json:"suggestion_id"`
+	ProductID    string    `gorm:"type:uuid;index" json:"product_id"`
+	BranchID     string    `gorm:"column:branch_id;index" json:"branch_id"`
+	SupplierID   *string   `gorm:"type:uuid" json:"supplier_id"`
+	OnHand       int       `json:"on_hand"`
+	OnOrder      int       `json:"on_order"`
+	InboundQty   int       `json:"inbound_qty"`
+	OutboundQty  int       `json:"outbound_qty"`
+	SuggestedQty int       `json:"suggested_qty"`
+	Status       string    `json:"status"` // Open, Converted, Resolved
+	OrderID      *string   `gorm:"type:uuid" json:"order_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ReorderSuggestion) TableName() string {
+	return "ReorderSuggestion"
+}
+
+func (s *ReorderSuggestion) BeforeCreate(tx *gorm.DB) (err error) {
+	s.SuggestionID = uuid.New().String()
+	return
+}
+
+// ReplenishmentTarget model (ระดับสต็อกเป้าหมายของสินค้าในสาขา POS)
+type ReplenishmentTarget struct {
+	TargetID  string    `gorm:"type:uuid;primaryKey" json:"target_id"`
+	BranchID  string    `gorm:"column:branch_id;uniqueIndex:idx_replenishment_branch_product" json:"branch_id"` // สาขา POS
+	ProductID string    `gorm:"type:uuid;uniqueIndex:idx_replenishment_branch_product" json:"product_id"`       // สินค้าฝั่ง Warehouse
+	MinQty    int       `json:"min_qty"`
+	TargetQty int       `json:"target_qty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ReplenishmentTarget) TableName() string {
+	return "ReplenishmentTarget"
+}
+
+func (t *ReplenishmentTarget) BeforeCreate(tx *gorm.DB) (err error) {
+	t.TargetID = uuid.New().String()
+	return
+}
+
+// ReplenishmentProposal model (ข้อเสนอส่งสินค้าจากคลังไปเติมสาขา POS)
+type ReplenishmentProposal struct {
+	ProposalID           string    `gorm:"type:uuid;primaryKey" json:"proposal_id"`
+	ProductID            string    `gorm:"type:uuid;index" json:"product_id"`
+	FromBranchID         string    `json:"from_branch_id"`
+	ToBranchID           string    `gorm:"index" json:"to_branch_id"`
+	WarehouseInventoryID string    `json:"warehouse_inventory_id"`
+	PosInventoryID       string    `json:"pos_inventory_id"`
+	PosQuantity          int       `json:"pos_quantity"`
+	InboundQty           int       `json:"inbound_qty"`
+	TargetQty            int       `json:"target_qty"`
+	ProposedQty          int       `json:"proposed_qty"`
+	Status               string    `json:"status"` // Open, Approved, Dismissed, Resolved
+	ShipmentID           *string   `gorm:"type:uuid" json:"shipment_id"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+func (ReplenishmentProposal) TableName() string {
+	return "ReplenishmentProposal"
+}
+
+func (p *ReplenishmentProposal) BeforeCreate(tx *gorm.DB) (err error) {
+	p.ProposalID = uuid.New().String()
+	return
+}
+
+// InventoryMovement model (ประวัติการเปลี่ยนแปลงจำนวนสินค้าใน Inventory)
+type InventoryMovement struct {
+	MovementID   string    `gorm:"type:uuid;primaryKey" json:"movement_id"`
+	InventoryID  string    `gorm:"index" json:"inventory_id"`
+	ProductID    string    `gorm:"index" json:"product_id"`
+	BranchID     string    `gorm:"index" json:"branch_id"`
+	Quantity     int       `json:"quantity"` // บวก = รับเข้า, ลบ = จ่ายออก
+	BalanceAfter int       `json:"balance_after"`
+	MovementType string    `json:"movement_type"`
+	DocumentType string    `json:"document_type"`
+	DocumentID   string    `gorm:"index" json:"document_id"`
+	Note         string    `json:"note"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+func (InventoryMovement) TableName() string {
+	return "InventoryMovement"
+}
+
+func (m *InventoryMovement) BeforeCreate(tx *gorm.DB) (err error) {
+	m.MovementID = uuid.New().String()
+	return
+}
+
+// StockCount model (รอบการตรวจนับสต็อก)
+type StockCount struct {
+	CountID     string     `gorm:"type:uuid;primaryKey" json:"count_id"`
+	CountNumber string     `json:"count_number"`
+	BranchID    string     `gorm:"index" json:"branch_id"`
+	CountType   string     `json:"count_type"` // Full, Cycle
+	Category    *string    `json:"category"`
+	LocationID  *string    `gorm:"type:uuid" json:"location_id"`
+	Freeze      bool       `json:"freeze"`
+	Status      string     `json:"status"` // Open, Applied, Cancelled
+	CreatedBy   string     `json:"created_by"`
+	ApprovedBy  string     `json:"approved_by"`
+	AppliedAt   *time.Time `json:"applied_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	Lines []StockCountLine `gorm:"foreignKey:CountID;constraint:OnDelete:CASCADE" json:"lines"`
+}
+
+func (StockCount) TableName() string {
+	return "StockCount"
+}
+
+func (s *StockCount) BeforeCreate(tx *gorm.DB) (err error) {
+	s.CountID = uuid.New().String()
+	return
+}
+
+// StockCountLine model (สินค้าที่ต้องนับ พร้อมจำนวนที่คาดไว้ ณ เวลาเปิดรอบนับ)
+type StockCountLine struct {
+	LineID        string    `gorm:"type:uuid;primaryKey" json:"line_id"`
+	CountID       string    `gorm:"type:uuid;index" json:"count_id"`
+	InventoryID   string    `json:"inventory_id"`
+	ProductID     string    `json:"product_id"`
+	ExpectedQty   int       `json:"expected_qty"`
+	MovedQty      int       `json:"moved_qty"` // รับ/จ่ายสุทธิระหว่างเปิดรอบจนถึงเวลานับ (รอบที่ไม่ freeze)
+	CountedQty    *int      `json:"counted_qty"`
+	Variance      int       `json:"variance"`
+	UnitCost      float64   `json:"unit_cost"`
+	VarianceValue float64   `json:"variance_value"`
+	Round         int       `gorm:"default:1" json:"round"`
+	Status        string    `json:"status"` // Pending, Counted, Recount, Approved, Rejected, Applied, Skipped
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (StockCountLine) TableName() string {
+	return "StockCountLine"
+}
+
+func (l *StockCountLine) BeforeCreate(tx *gorm.DB) (err error) {
+	l.LineID = uuid.New().String()
+	return
+}
+
+// StockCountEntry model (ผลการนับของผู้นับแต่ละคนในแต่ละรอบ)
+type StockCountEntry struct {
+	EntryID    string    `gorm:"type:uuid;primaryKey" json:"entry_id"`
+	LineID     string    `gorm:"type:uuid;index" json:"line_id"`
+	CountedQty int       `json:"counted_qty"`
+	CountedBy  string    `json:"counted_by"`
+	Round      int       `json:"round"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (StockCountEntry) TableName() string {
+	return "StockCountEntry"
+}
+
+func (e *StockCountEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	e.EntryID = uuid.New().String()
+	return
+}
+
+// StockReservation model (จองสต็อกให้ ShipmentItem ที่ยังไม่อนุมัติ)
+type StockReservation struct {
+	ReservationID  string    `gorm:"type:uuid;primaryKey" json:"reservation_id"`
+	InventoryID    string    `gorm:"index" json:"inventory_id"`
+	ShipmentID     string    `gorm:"type:uuid;index" json:"shipment_id"`
+	ShipmentListID string    `gorm:"type:uuid" json:"shipment_list_id"`
+	Quantity       int       `json:"quantity"`
+	Status         string    `gorm:"index" json:"status"` // Active, Released, Consumed
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (StockReservation) TableName() string {
+	return "StockReservation"
+}
+
+func (r *StockReservation) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ReservationID = uuid.New().String()
+	return
+}
+
+// CostLayer model (ชั้นต้นทุนของสินค้าที่รับเข้า ใช้คำนวณมูลค่าแบบ FIFO/ถัวเฉลี่ย)
+type CostLayer struct {
+	LayerID          string    `gorm:"type:uuid;primaryKey" json:"layer_id"`
+	InventoryID      string    `gorm:"index" json:"inventory_id"`
+	ProductID        string    `gorm:"index" json:"product_id"`
+	BranchID         string    `gorm:"index" json:"branch_id"`
+	SourceType       string    `json:"source_type"` // GoodsReceipt, StockCount, Adjustment, Shipment (คืนต้นทุนตอนลงรายการกลับ)
+	SourceID         string    `json:"source_id"`
+	Quantity         int       `json:"quantity"`
+	Remaining        int       `json:"remaining"`
+	UnitCost         float64   `json:"unit_cost"`
+	AverageCostAfter float64   `json:"average_cost_after"` // ต้นทุนถัวเฉลี่ยของ Inventory หลังรับชั้นนี้
+	ReceivedAt       time.Time `gorm:"index" json:"received_at"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (CostLayer) TableName() string {
+	return "CostLayer"
+}
+
+func (l *CostLayer) BeforeCreate(tx *gorm.DB) (err error) {
+	l.LayerID = uuid.New().String()
+	return
+}
+
+// CostConsumption model (ต้นทุนของสินค้าที่จ่ายออก)
+type CostConsumption struct {
+	ConsumptionID string    `gorm:"type:uuid;primaryKey" json:"consumption_id"`
+	LayerID       *string   `gorm:"type:uuid;index" json:"layer_id"`
+	InventoryID   string    `gorm:"index" json:"inventory_id"`
+	ProductID     string    `json:"product_id"`
+	BranchID      string    `gorm:"index" json:"branch_id"`
+	DocumentType  string    `json:"document_type"`
+	DocumentID    string    `gorm:"index" json:"document_id"`
+	Method        string    `json:"method"`
+	Quantity      int       `json:"quantity"`
+	UnitCost      float64   `json:"unit_cost"`
+	TotalCost     float64   `json:"total_cost"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+func (CostConsumption) TableName() string {
+	return "CostConsumption"
+}
+
+func (c *CostConsumption) BeforeCreate(tx *gorm.DB) (err error) {
+	c.ConsumptionID = uuid.New().String()
+	return
+}
+
+// InventoryCost model (ต้นทุนถัวเฉลี่ยเคลื่อนที่ปัจจุบันของ Inventory)
+type InventoryCost struct {
+	InventoryID string    `gorm:"primaryKey" json:"inventory_id"`
+	AverageCost float64   `json:"average_cost"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (InventoryCost) TableName() string {
+	return "InventoryCost"
+}
+
+// InventorySnapshot model (ยอดคงเหลือและมูลค่าของ Inventory ณ เวลาที่บันทึกประจำวัน)
+type InventorySnapshot struct {
+	SnapshotID   string    `gorm:"type:uuid;primaryKey" json:"snapshot_id"`
+	SnapshotDate time.Time `gorm:"type:date;uniqueIndex:idx_snapshot_date_inventory" json:"snapshot_date"`
+	InventoryID  string    `gorm:"uniqueIndex:idx_snapshot_date_inventory" json:"inventory_id"`
+	ProductID    string    `gorm:"index" json:"product_id"`
+	BranchID     string    `gorm:"index" json:"branch_id"`
+	Quantity     int       `json:"quantity"`
+	UnitCost     float64   `json:"unit_cost"`
+	Value        float64   `json:"value"`
+	TakenAt      time.Time `gorm:"index" json:"taken_at"`
+}
+
+func (InventorySnapshot) TableName() string {
+	return "InventorySnapshot"
+}
+
+func (s *InventorySnapshot) BeforeCreate(tx *gorm.DB) (err error) {
+	s.SnapshotID = uuid.New().String()
+	return
+}
+
+// AdjustmentDocument model (เอกสารปรับปรุงสต็อก เช่น ตัดชำรุด สูญหาย หมดอายุ หรือพบเพิ่ม)
+type AdjustmentDocument struct {
+	AdjustmentID     string     `gorm:"type:uuid;primaryKey" json:"adjustment_id"`
+	AdjustmentNumber string     `json:"adjustment_number"`
+	BranchID         string     `gorm:"index" json:"branch_id"`
+	Status           string     `json:"status"` // Draft, Submitted, Posted, Rejected
+	Note             string     `json:"note"`
+	TotalValue       float64    `json:"total_value"`
+	CreatedBy        string     `json:"created_by"`
+	SubmittedAt      *time.Time `json:"submitted_at"`
+	ApprovedBy       string     `json:"approved_by"`
+	PostedAt         *time.Time `json:"posted_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	// Relationships
+	Lines []AdjustmentLine `gorm:"foreignKey:AdjustmentID;constraint:OnDelete:CASCADE" json:"lines"`
+}
+
+func (AdjustmentDocument) TableName() string {
+	return "AdjustmentDocument"
+}
+
+func (a *AdjustmentDocument) BeforeCreate(tx *gorm.DB) (err error) {
+	a.AdjustmentID = uuid.New().String()
+	return
+}
+
+// AdjustmentLine model (รายการปรับปรุงสต็อก พร้อมเหตุผลและรูปประกอบ)
+type AdjustmentLine struct {
+	LineID       string  `gorm:"type:uuid;primaryKey" json:"line_id"`
+	AdjustmentID string  `gorm:"type:uuid;index" json:"adjustment_id"`
+	InventoryID  string  `json:"inventory_id"`
+	ProductID    string  `json:"product_id"`
+	Delta        int     `json:"delta"`
+	ReasonCode   string  `json:"reason_code"` // Damage, Theft, Expiry, Found, Other
+	Note         string  `json:"note"`
+	UnitCost     float64 `json:"unit_cost"`
+	Value        float64 `json:"value"`
+
+	// ล็อต: ลดสต็อก = ล็อตที่ตัด (ไม่ระบุ = FEFO), เพิ่มสต็อก = ล็อตที่สร้างตอนลงบัญชี
+	LotID      *string    `gorm:"type:uuid" json:"lot_id"`
+	LotNumber  string     `json:"lot_number"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+
+	// หมายเลขซีเรียลของสินค้า serialized (ต้องมีเท่ากับจำนวนที่ปรับ)
+	SerialNumbers []string `gorm:"serializer:json;type:text" json:"serial_numbers"`
+
+	Photo     []byte    `json:"-"`
+	PhotoType string    `json:"photo_type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AdjustmentLine) TableName() string {
+	return "AdjustmentLine"
+}
+
+func (l *AdjustmentLine) BeforeCreate(tx *gorm.DB) (err error) {
+	l.LineID = uuid.New().String()
+	return
+}
+
+// GoodsReceipt model (เอกสารรับสินค้าตาม Order เข้าสาขาปลายทาง รับได้หลายครั้งต่อ Order)
+type GoodsReceipt struct {
+	ReceiptID     string    `gorm:"type:uuid;primaryKey" json:"receipt_id"`
+	ReceiptNumber string    `json:"receipt_number"`
+	OrderID       string    `gorm:"type:uuid;index" json:"order_id"`
+	BranchID      string    `gorm:"type:uuid" json:"branch_id"`
+	Note          string    `json:"note"`
+	ReceivedBy    string    `json:"received_by"`
+	ReceivedAt    time.Time `json:"received_at"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Relationships
+	Lines []GoodsReceiptLine `gorm:"foreignKey:ReceiptID;constraint:OnDelete:CASCADE" json:"lines"`
+}
+
+func (GoodsReceipt) TableName() string {
+	return "GoodsReceipt"
+}
+
+func (r *GoodsReceipt) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ReceiptID = uuid.New().String()
+	return
+}
+
+// GoodsReceiptLine model (จำนวนที่รับเข้าสต็อกและจำนวนที่ชำรุดของแต่ละรายการใน Order)
+type GoodsReceiptLine struct {
+	LineID      string    `gorm:"type:uuid;primaryKey" json:"line_id"`
+	ReceiptID   string    `gorm:"type:uuid;index" json:"receipt_id"`
+	OrderItemID string    `gorm:"type:uuid;index" json:"order_item_id"`
+	ProductID   string    `gorm:"type:uuid" json:"product_id"`
+	InventoryID string    `json:"inventory_id"`
+	ReceivedQty int       `json:"received_qty"`
+	DamagedQty  int       `json:"damaged_qty"`
+	UnitCost    float64   `json:"unit_cost"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (GoodsReceiptLine) TableName() string {
+	return "GoodsReceiptLine"
+}
+
+func (l *GoodsReceiptLine) BeforeCreate(tx *gorm.DB) (err error) {
+	l.LineID = uuid.New().String()
+	return
+}
+
+// DocumentNumbering model (รูปแบบเลขที่เอกสารของแต่ละประเภท เช่น PO-BKK-2026-000123)
+type DocumentNumbering struct {
+	DocumentType string    `gorm:"primaryKey" json:"document_type"` // PurchaseOrder, Shipment, GoodsReceipt, StockCount, Adjustment
+	Prefix       string    `json:"prefix"`
+	Padding      int       `gorm:"default:6" json:"padding"`
+	PerBranch    bool      `gorm:"default:true" json:"per_branch"` // แยกลำดับตามสาขา
+	Yearly       bool      `gorm:"default:true" json:"yearly"`     // เริ่มลำดับใหม่ทุกปี
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (DocumentNumbering) TableName() string {
+	return "DocumentNumbering"
+}
+
+// DocumentSequence model (เลขล่าสุดที่ออกไปแล้วของแต่ละประเภทเอกสาร/สาขา/ปี)
+type DocumentSequence struct {
+	SequenceID   string    `gorm:"type:uuid;primaryKey" json:"sequence_id"`
+	DocumentType string    `gorm:"uniqueIndex:idx_document_sequence" json:"document_type"`
+	BranchCode   string    `gorm:"uniqueIndex:idx_document_sequence" json:"branch_code"` // ว่าง = ไม่แยกสาขา
+	Year         int       `gorm:"uniqueIndex:idx_document_sequence" json:"year"`        // 0 = ไม่แยกปี
+	LastValue    int64     `json:"last_value"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (DocumentSequence) TableName() string {
+	return "DocumentSequence"
+}
+
+func (s *DocumentSequence) BeforeCreate(tx *gorm.DB) (err error) {
+	s.SequenceID = uuid.New().String()
+	return
+}
+
+// ReturnToVendor model (เอกสารส่งคืนสินค้าให้ Supplier อ้างอิง Order เดิม พร้อมติดตามใบลดหนี้)
+type ReturnToVendor struct {
+	ReturnID       string          `gorm:"type:uuid;primaryKey" json:"return_id"`
+	ReturnNumber   string          `json:"return_number"`
+	OrderID        string          `gorm:"type:uuid;index" json:"order_id"`
+	SupplierID     string          `gorm:"type:uuid;index" json:"supplier_id"`
+	BranchID       string          `gorm:"type:uuid" json:"branch_id"`           // สาขาที่ส่งคืนสินค้า
+	Status         string          `json:"status"`                               // Draft, Submitted, Approved, Dispatched, Closed, Rejected, Cancelled
+	CreditStatus   string          `gorm:"default:Pending" json:"credit_status"` // Pending, Partial, Received
+	Note           string          `json:"note"`
+	CreditExpected decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"credit_expected"`
+	CreditReceived decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"credit_received"`
+	Currency       string          `gorm:"default:THB" json:"currency"`
+	CreatedBy      string          `json:"created_by"`
+	ApprovedBy     string          `json:"approved_by"`
+	DispatchedBy   string          `json:"dispatched_by"`
+	SubmittedAt    *time.Time      `json:"submitted_at"`
+	ApprovedAt     *time.Time      `json:"approved_at"`
+	DispatchedAt   *time.Time      `json:"dispatched_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+
+	// Relationships
+	Lines       []ReturnToVendorLine `gorm:"foreignKey:ReturnID;constraint:OnDelete:CASCADE" json:"lines"`
+	CreditNotes []VendorCreditNote   `gorm:"foreignKey:ReturnID;constraint:OnDelete:CASCADE" json:"credit_notes"`
+}
+
+func (ReturnToVendor) TableName() string {
+	return "ReturnToVendor"
+}
+
+func (r *ReturnToVendor) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ReturnID = uuid.New().String()
+	return
+}
+
+// ReturnToVendorLine model (รายการที่ส่งคืน อ้างอิงรายการใน Order เดิม)
+type ReturnToVendorLine struct {
+	LineID       string          `gorm:"type:uuid;primaryKey" json:"line_id"`
+	ReturnID     string          `gorm:"type:uuid;index" json:"return_id"`
+	OrderItemID  string          `gorm:"type:uuid;index" json:"order_item_id"`
+	ProductID    string          `gorm:"type:uuid" json:"product_id"`
+	InventoryID  string          `json:"inventory_id"`
+	Quantity     int             `json:"quantity"`                          // หน่วยย่อย
+	FromDamaged  bool            `gorm:"default:false" json:"from_damaged"` // คืนของที่ชำรุดตอนรับ (ไม่ได้เข้าสต็อก จึงไม่ตัดสต็อก)
+	Reason       string          `json:"reason"`                            // Damaged, WrongItem, Expired, Quality, Excess, Other
+	Note         string          `json:"note"`
+	UnitCredit   decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"unit_credit"`
+	CreditAmount decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"credit_amount"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+func (ReturnToVendorLine) TableName() string {
+	return "ReturnToVendorLine"
+}
+
+func (l *ReturnToVendorLine) BeforeCreate(tx *gorm.DB) (err error) {
+	l.LineID = uuid.New().String()
+	return
+}
+
+// VendorCreditNote model (ใบลดหนี้ที่ได้รับจาก Supplier สำหรับสินค้าที่ส่งคืน)
+type VendorCreditNote struct {
+	CreditNoteID     string          `gorm:"type:uuid;primaryKey" json:"credit_note_id"`
+	ReturnID         string          `gorm:"type:uuid;index" json:"return_id"`
+	CreditNoteNumber string          `json:"credit_note_number"` // เลขที่ใบลดหนี้ของ Supplier
+	Amount           decimal.Decimal `gorm:"type:numeric(18,4)" json:"amount"`
+	ReceivedAt       time.Time       `json:"received_at"`
+	Note             string          `json:"note"`
+	RecordedBy       string          `json:"recorded_by"`
+	CreatedAt        time.Time       `json:"created_at"`
+}
+
+func (VendorCreditNote) TableName() string {
+	return "VendorCreditNote"
+}
+
+func (n *VendorCreditNote) BeforeCreate(tx *gorm.DB) (err error) {
+	n.CreditNoteID = uuid.New().String()
+	return
+}
+
+// ApprovalRule model (กฎการอนุมัติตามประเภทเอกสาร สาขา และยอดเงินขั้นต่ำ)
+type ApprovalRule struct {
+	RuleID       string          `gorm:"type:uuid;primaryKey" json:"rule_id"`
+	DocumentType string          `gorm:"index" json:"document_type"` // PurchaseOrder, Shipment, Adjustment, ReturnToVendor
+	BranchID     *string         `gorm:"type:uuid" json:"branch_id"` // nil = ทุกสาขา
+	MinAmount    decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"min_amount"`
+	Steps        string          `json:"steps"` // Role ที่ต้องอนุมัติตามลำดับ คั่นด้วย , (หลาย Role ในขั้นเดียวคั่นด้วย |) เช่น Account,Manager
+	Active       bool            `gorm:"default:true" json:"active"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+func (ApprovalRule) TableName() string {
+	return "ApprovalRule"
+}
+
+func (r *ApprovalRule) BeforeCreate(tx *gorm.DB) (err error) {
+	r.RuleID = uuid.New().String()
+	return
+}
+
+// ApprovalRequest model (คำขออนุมัติของเอกสารหนึ่งครั้ง ส่งใหม่จะสร้างคำขอใหม่ จึงเป็นประวัติการอนุมัติของเอกสาร)
+type ApprovalRequest struct {
+	RequestID    string          `gorm:"type:uuid;primaryKey" json:"request_id"`
+	DocumentType string          `gorm:"index:idx_approval_document" json:"document_type"`
+	DocumentID   string          `gorm:"type:uuid;index:idx_approval_document" json:"document_id"`
+	BranchID     string          `json:"branch_id"`
+	Amount       decimal.Decimal `gorm:"type:numeric(18,4);default:0" json:"amount"`
+	RuleID       *string         `gorm:"type:uuid" json:"rule_id"` // nil = ใช้กฎเริ่มต้น
+	Status       string          `json:"status"`                   // Pending, Approved, Rejected, Cancelled
+	CurrentStep  int             `json:"current_step"`
+	RequestedBy  string          `json:"requested_by"`
+	CreatedAt    time.Time       `json:"created_at"`
+	CompletedAt  *time.Time      `json:"completed_at"`
+
+	// Relationships
+	Tasks []ApprovalTask `gorm:"foreignKey:RequestID;constraint:OnDelete:CASCADE" json:"tasks"`
+}
+
+func (ApprovalRequest) TableName() string {
+	return "ApprovalRequest"
+}
+
+func (r *ApprovalRequest) BeforeCreate(tx *gorm.DB) (err error) {
+	r.RequestID = uuid.New().String()
+	return
+}
+
+// ApprovalTask model (งานอนุมัติแต่ละขั้น มอบหมายให้ Role)
+type ApprovalTask struct {
+	TaskID      string     `gorm:"type:uuid;primaryKey" json:"task_id"`
+	RequestID   string     `gorm:"type:uuid;index" json:"request_id"`
+	Step        int        `json:"step"`
+	Roles       string     `json:"roles"`  // Role ที่อนุมัติขั้นนี้ได้ คั่นด้วย |
+	Status      string     `json:"status"` // Waiting, Pending, Approved, Rejected, Cancelled
+	DecidedBy   string     `json:"decided_by"`
+	DecidedByID *string    `gorm:"type:uuid" json:"decided_by_id"`
+	Comment     string     `json:"comment"`
+	DecidedAt   *time.Time `json:"decided_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (ApprovalTask) TableName() string {
+	return "ApprovalTask"
+}
+
+func (t *ApprovalTask) BeforeCreate(tx *gorm.DB) (err error) {
+	t.TaskID = uuid.New().String()
+	return
+}
+
+// AuditLog model (ประวัติการสร้าง/แก้ไข/ลบข้อมูลจาก API พร้อมผู้กระทำ)
+type AuditLog struct {
+	AuditID    string          `gorm:"type:uuid;primaryKey" json:"audit_id"`
+	EmployeeID *string         `gorm:"type:uuid;index" json:"employee_id"`
+	Username   string          `json:"username"`
+	Role       string          `json:"role"`
+	Action     string          `gorm:"index" json:"action"` // Create, Update, Delete
+	EntityType string          `gorm:"index:idx_audit_entity" json:"entity_type"`
+	EntityID   string          `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After      json.RawMessage `gorm:"type:jsonb" json:"after"`
+	Changes    json.RawMessage `gorm:"type:jsonb" json:"changes"` // {"column": {"from": ..., "to": ...}}
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "AuditLog"
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	a.AuditID = uuid.New().String()
+	return
+}
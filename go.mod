@@ -6,6 +6,8 @@ require (
 	github.com/gofiber/fiber/v2 v2.52.6
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/shopspring/decimal v1.4.0
 	gorm.io/driver/postgres v1.5.11
 	gorm.io/gorm v1.25.12
 )
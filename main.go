@@ -8,12 +8,15 @@ import (
 
 	"Api/Authentication"
 	"Api/Func"
+	"Api/Models"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -26,6 +29,59 @@ func connectToDatabase(host string, port int, user, password, dbname string) (*g
 	})
 }
 
+// addMissingColumns เพิ่มคอลัมน์ที่ยังไม่มีในตารางเดิม โดยไม่สร้าง constraint ใหม่
+// (ใช้ ADD COLUMN IF NOT EXISTS เพราะบางตารางตั้งชื่อแบบมีเครื่องหมายคำพูด)
+func addMissingColumns(db *gorm.DB, model interface{}, fields ...string) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	for _, name := range fields {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil {
+			return fmt.Errorf("field %s not found in %s", name, stmt.Schema.Name)
+		}
+		if err := db.Exec("ALTER TABLE ? ADD COLUMN IF NOT EXISTS ? "+db.Migrator().FullDataTypeOf(field).SQL,
+			clause.Table{Name: stmt.Table}, clause.Column{Name: field.DBName}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restrictEmployeeBranchFK เปลี่ยน foreign key Employees → Branches เดิมที่เป็น ON DELETE CASCADE ให้เป็น RESTRICT
+// (ตาราง Employees/Branches ไม่ได้ใช้ AutoMigrate แท็ก constraint ใน struct จึงไม่มีผลกับฐานข้อมูลเดิม)
+func restrictEmployeeBranchFK(db *gorm.DB) error {
+	var constraints []struct {
+		Name       string
+		DeleteType string
+	}
+	if err := db.Raw(`SELECT conname AS name, confdeltype::text AS delete_type FROM pg_constraint
+		WHERE contype = 'f' AND conrelid = '"Employees"'::regclass AND confrelid = '"Branches"'::regclass`).
+		Scan(&constraints).Error; err != nil {
+		return err
+	}
+
+	// ยังไม่มี foreign key เลย: สร้างใหม่แบบ NOT VALID เพื่อไม่ให้ข้อมูลเก่าที่กำพร้าทำให้เริ่มระบบไม่ได้
+	if len(constraints) == 0 {
+		return db.Exec(`ALTER TABLE "Employees" ADD CONSTRAINT "fk_Branches_Employees"
+			FOREIGN KEY (branch_id) REFERENCES "Branches"(branch_id) ON DELETE RESTRICT NOT VALID`).Error
+	}
+
+	for _, constraint := range constraints {
+		if constraint.DeleteType == "r" {
+			continue
+		}
+		if err := db.Exec(`ALTER TABLE "Employees" DROP CONSTRAINT ?, ADD CONSTRAINT ?
+			FOREIGN KEY (branch_id) REFERENCES "Branches"(branch_id) ON DELETE RESTRICT NOT VALID`,
+			clause.Column{Name: constraint.Name}, clause.Column{Name: constraint.Name}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
@@ -58,14 +114,18 @@ func main() {
 	}
 	log.Println("Connected to POS database!")
 
+	// ส่งค่าเงิน (decimal) ใน JSON เป็นตัวเลขเหมือน float เดิม
+	decimal.MarshalJSONWithoutQuotes = true
+
 	go Func.StartSyncScheduler(db, posDB)
 	app := fiber.New()
 
 	// Middleware
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:3000", // Allow frontend domain
-		AllowMethods: "GET,POST,PUT,DELETE",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowOrigins:  "http://localhost:3000", // Allow frontend domain
+		AllowMethods:  "GET,POST,PUT,DELETE",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, If-Match",
+		ExposeHeaders: "ETag",
 	}))
 	app.Use(func(c *fiber.Ctx) error {
 		c.Locals("db", db)
@@ -80,22 +140,145 @@ func main() {
 	// ✅ Migration สำหรับตารางที่เหลือ
 	log.Println("🚀 Migrating related tables...")
 	err = db.AutoMigrate(
-	// &Models.Product{},
-	// &Models.Inventory{},
-	// &Models.ProductUnit{},
-	// &Models.Supplier{},
-	// &Models.Order{},
-	// &Models.OrderItem{},
-	// &Models.Shipment{},
-	// &Models.ShipmentItem{},
-	// &Models.ProductSupplier{},
+		// &Models.Product{},
+		// &Models.Inventory{},
+		// &Models.ProductUnit{},
+		// &Models.Supplier{},
+		// &Models.Order{},
+		// &Models.OrderItem{},
+		// &Models.Shipment{},
+		// &Models.ShipmentItem{},
+		// &Models.ProductSupplier{},
+		&Models.PriceList{},
+		&Models.ProductPrice{},
+		&Models.PriceHistory{},
+		&Models.ProductPosMapping{},
+		&Models.InventoryLot{},
+		&Models.ShipmentLotPick{},
+		&Models.SerialNumber{},
+		&Models.SerialMovement{},
+		&Models.Location{},
+		&Models.LocationStock{},
+		&Models.LocationMove{},
+		&Models.ReorderSetting{},
+		&Models.ReorderSuggestion{},
+		&Models.ReplenishmentTarget{},
+		&Models.ReplenishmentProposal{},
+		&Models.InventoryMovement{},
+		&Models.StockCount{},
+		&Models.StockCountLine{},
+		&Models.StockCountEntry{},
+		&Models.StockReservation{},
+		&Models.CostLayer{},
+		&Models.CostConsumption{},
+		&Models.InventoryCost{},
+		&Models.InventorySnapshot{},
+		&Models.AdjustmentDocument{},
+		&Models.AdjustmentLine{},
+		&Models.GoodsReceipt{},
+		&Models.GoodsReceiptLine{},
+		&Models.SupplierProduct{},
+		&Models.DocumentNumbering{},
+		&Models.DocumentSequence{},
+		&Models.ReturnToVendor{},
+		&Models.ReturnToVendorLine{},
+		&Models.VendorCreditNote{},
+		&Models.ApprovalRule{},
+		&Models.ApprovalRequest{},
+		&Models.ApprovalTask{},
+		&Models.AuditLog{},
 	)
 	if err != nil {
 		log.Fatal("❌ Failed to migrate related tables:", err)
 	}
 
+	// ✅ เพิ่มคอลัมน์ใหม่ให้ตารางเดิมที่ไม่ได้ใช้ AutoMigrate
+	missingColumns := []struct {
+		model  interface{}
+		fields []string
+	}{
+		{&Models.Product{}, []string{"SKU", "Serialized", "DeletedAt", "DeletedBy", "Version"}},
+		{&Models.Supplier{}, []string{"DeletedAt", "DeletedBy", "Version", "ContactName", "Email", "Phone", "Address", "TaxID", "PaymentTerms", "PaymentTermDays", "LeadTimeDays", "Currency"}},
+		{&Models.Inventory{}, []string{"Version"}},
+		{&Models.Branches{}, []string{"DeletedAt", "DeletedBy", "AllowNegativeStock", "Version", "Code"}},
+		{&Models.Employees{}, []string{"DeletedAt", "DeletedBy"}},
+		{&Models.ProductSupplier{}, []string{"Preferred"}},
+		{&Models.Order{}, []string{"BranchID", "Subtotal", "Tax", "GrandTotal", "PromisedDate", "CancelReason", "CancelledBy", "CancelledAt", "AmendedFromID", "AmendedToID"}},
+		{&Models.Shipment{}, []string{"CancelReason", "CancelledBy", "CancelledAt", "AmendedFromID", "AmendedToID", "ReviewNote"}},
+		{&Models.OrderItem{}, []string{"UnitPrice", "ReceivedQty", "DamagedQty", "Currency", "Discount", "TaxRate", "LineTotal"}},
+	}
+	legacyOrders := !db.Migrator().HasColumn(&Models.OrderItem{}, "ReceivedQty")
+	for _, table := range missingColumns {
+		if err := addMissingColumns(db, table.model, table.fields...); err != nil {
+			log.Fatal("❌ Failed to add missing columns:", err)
+		}
+	}
+
+	// ✅ index ของ deleted_at ในตารางเดิม (ทุก query กรอง deleted_at IS NULL)
+	for _, statement := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_product_deleted_at ON "Product" (deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_deleted_at ON "Supplier" (deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_branches_deleted_at ON "Branches" (deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_employees_deleted_at ON "Employees" (deleted_at)`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Fatal("❌ Failed to create soft delete indexes:", err)
+		}
+	}
+
+	// ✅ ลบสาขาที่ยังมีพนักงานไม่ได้ (เดิม cascade ลบพนักงานทิ้ง)
+	if err := restrictEmployeeBranchFK(db); err != nil {
+		log.Fatal("❌ Failed to restrict employee branch foreign key:", err)
+	}
+
+	// ✅ เปลี่ยนคอลัมน์เงินเดิมจาก float เป็น numeric
+	for _, statement := range []string{
+		`ALTER TABLE "OrderItem" ALTER COLUMN unit_price TYPE numeric(18,4)`,
+		`ALTER TABLE "Order" ALTER COLUMN total_amount TYPE numeric(18,4)`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Fatal("❌ Failed to convert money columns:", err)
+		}
+	}
+
+	// ✅ ชั้นต้นทุนจากการตรวจนับเดิมบันทึกด้วย source_type "Stocktake" ให้ตรงกับ document_type ของ movement
+	if err := db.Exec(`UPDATE "CostLayer" SET source_type = ? WHERE source_type = ?`, "StockCount", "Stocktake").Error; err != nil {
+		log.Fatal("❌ Failed to migrate stock count cost layers:", err)
+	}
+
+	// ✅ ย้ายข้อมูล ProductSupplier และสินค้าเดิมของ Supplier เข้าแคตตาล็อก SupplierProduct
+	if err := Func.MigrateSupplierProducts(db); err != nil {
+		log.Fatal("❌ Failed to migrate supplier products:", err)
+	}
+
+	// ✅ แปลงสถานะ Order แบบเดิมเป็น PO lifecycle (ครั้งแรกที่เพิ่มคอลัมน์ received_qty)
+	if legacyOrders {
+		if err := Func.MigrateLegacyOrders(db); err != nil {
+			log.Fatal("❌ Failed to migrate legacy orders:", err)
+		}
+	}
+
+	// ✅ รับซีเรียลที่ค้าง InTransit ของ Shipment ที่เคยถูกปิดอัตโนมัติ
+	if err := Func.SettleCompletedShipmentSerials(db); err != nil {
+		log.Fatal("❌ Failed to settle serials of completed shipments:", err)
+	}
+
+	// ✅ กันสต็อกติดลบที่ระดับฐานข้อมูล (นอกจากสาขาที่อนุญาต)
+	if err := Func.EnsureStockGuards(db); err != nil {
+		log.Fatal("❌ Failed to create stock guards:", err)
+	}
+
+	if err := Func.EnsureProductSearchIndexes(db); err != nil {
+		log.Println("⚠️ Failed to create product search indexes:", err)
+	}
+
 	log.Println("✅ Migration completed successfully!")
 
+	// ✅ บันทึก AuditLog ทุกการสร้าง/แก้ไข/ลบข้อมูลจาก API
+	if err := Func.RegisterAuditCallbacks(db); err != nil {
+		log.Fatal("❌ Failed to register audit callbacks:", err)
+	}
+
 	app.Post("/login", Authentication.Login)
 
 	// Protected Routes Example
@@ -117,6 +300,20 @@ func main() {
 	Func.OrderItemRoutes(app, db)
 	Func.ShipmentRoutes(app, db, posDB)
 	Func.ShipmentItemRoutes(app, db)
+	Func.PricingRoutes(app, db, posDB)
+	Func.LotRoutes(app, db)
+	Func.SerialRoutes(app, db)
+	Func.LocationRoutes(app, db)
+	Func.ReorderRoutes(app, db)
+	Func.ReplenishmentRoutes(app, db, posDB)
+	Func.StocktakeRoutes(app, db)
+	Func.CostingRoutes(app, db)
+	Func.SnapshotRoutes(app, db)
+	Func.AdjustmentRoutes(app, db)
+	Func.DocumentRoutes(app, db)
+	Func.ReturnToVendorRoutes(app, db)
+	Func.ApprovalRoutes(app, db)
+	Func.AuditRoutes(app, db)
 
 	// Start server
 	log.Println("Starting server on port 5050...")